@@ -0,0 +1,105 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"github.com/ProtonMail/proton-bridge/internal/users/credentials"
+	"github.com/ProtonMail/proton-bridge/pkg/config"
+	"github.com/ProtonMail/proton-bridge/pkg/keychain"
+	dockerCredentials "github.com/docker/docker-credential-helpers/credentials"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// keychainPassphraseEnv is the environment variable with passphrase of the file keychain.
+const keychainPassphraseEnv = "PROTONMAIL_BRIDGE_KEYCHAIN_PASSPHRASE"
+
+// newCredentialsStore creates the credentials store with the keychain chosen
+// by command line flags and moves credentials from the other keychain to it
+// when asked to. Passphrase of the file keychain is read only once; when the
+// keychain cannot be opened, the store opens it again later with the same
+// passphrase, without asking for it and without migrating.
+func newCredentialsStore(context *cli.Context, cfg *config.Config) (*credentials.Store, error) {
+	name := context.GlobalString("keychain")
+	sourceName := context.GlobalString("keychain-migrate-from")
+	if sourceName != "" && sourceName == name {
+		return credentials.NewStoreWithKeychain(nil), errors.New("cannot migrate keychain to itself")
+	}
+
+	var passphrase []byte
+	if name == keychain.HelperFile || sourceName == keychain.HelperFile {
+		var err error
+		if passphrase, err = keychain.ReadPassphrase(context.GlobalInt("keychain-passphrase-fd"), keychainPassphraseEnv); err != nil {
+			return credentials.NewStoreWithKeychain(nil), err
+		}
+	}
+
+	openKeychain := func() (*keychain.Access, error) {
+		return newKeychain(cfg, name, passphrase)
+	}
+
+	secrets, err := openKeychain()
+	if err != nil {
+		if sourceName != "" {
+			log.WithField("from", sourceName).Warn("Keychain is not available, credentials are not migrated")
+		}
+		return credentials.NewStoreWithOpener(nil, openKeychain), err
+	}
+
+	if sourceName != "" {
+		if err := migrateKeychain(cfg, secrets, sourceName, passphrase); err != nil {
+			return credentials.NewStoreWithKeychain(nil), err
+		}
+	}
+
+	return credentials.NewStoreWithOpener(secrets, openKeychain), nil
+}
+
+// migrateKeychain moves credentials from the keychain called `sourceName` to `secrets`.
+func migrateKeychain(cfg *config.Config, secrets *keychain.Access, sourceName string, passphrase []byte) error {
+	source, err := newKeychain(cfg, sourceName, passphrase)
+	if err != nil {
+		return errors.Wrap(err, "cannot open keychain to migrate from")
+	}
+
+	migrated, err := secrets.MigrateFrom(source)
+	if err != nil {
+		return errors.Wrap(err, "cannot migrate keychain")
+	}
+
+	log.WithField("from", sourceName).WithField("count", migrated).Info("Credentials migrated")
+	return nil
+}
+
+// newKeychain opens the keychain called `name`. The file keychain is
+// encrypted by `passphrase`.
+func newKeychain(cfg *config.Config, name string, passphrase []byte) (*keychain.Access, error) {
+	helper, err := newKeychainHelper(cfg, name, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return keychain.NewAccessWithHelper("bridge", helper), nil
+}
+
+func newKeychainHelper(cfg *config.Config, name string, passphrase []byte) (dockerCredentials.Helper, error) {
+	if name != keychain.HelperFile {
+		return keychain.NewHelper(name)
+	}
+
+	return keychain.NewFileHelper(cfg.GetKeychainFilePath(), passphrase)
+}
@@ -0,0 +1,314 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ProtonMail/proton-bridge/internal/bridge"
+	"github.com/ProtonMail/proton-bridge/internal/events"
+	"github.com/ProtonMail/proton-bridge/internal/preferences"
+	"github.com/ProtonMail/proton-bridge/internal/users"
+	"github.com/ProtonMail/proton-bridge/pkg/config"
+	"github.com/ProtonMail/proton-bridge/pkg/constants"
+	"github.com/ProtonMail/proton-bridge/pkg/fido2"
+	"github.com/ProtonMail/proton-bridge/pkg/keychain"
+	"github.com/ProtonMail/proton-bridge/pkg/listener"
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/ProtonMail/proton-bridge/pkg/totp"
+	"github.com/allan-simon/go-singleinstance"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+// Exit codes of the login command. Code 3 is shared with the main command.
+const (
+	exitLoginBridgeRunning   = 3
+	exitLoginInvalidInput    = 10
+	exitLoginKeychain        = 11
+	exitLoginNoInternet      = 12
+	exitLoginUpgrade         = 13
+	exitLoginWrongPassword   = 14
+	exitLoginTwoFactor       = 15
+	exitLoginMailboxPassword = 16
+	exitLoginFailed          = 17
+	exitLoginPanic           = 18
+
+	// maxLoginInputSize limits how much is read from the credentials input.
+	maxLoginInputSize = 64 * 1024
+)
+
+// loginInput is read as JSON from stdin or the given file descriptor.
+type loginInput struct {
+	Username, Password string
+	TOTP               string // Required only for accounts with 2FA.
+	MailboxPassword    string // Required only for accounts in two password mode.
+
+	// TOTPSecret generates the TOTP code when it is not given. With
+	// AutoReauth, it is stored with the password in the keychain to log
+	// in again when the session expires.
+	TOTPSecret string
+	AutoReauth bool
+
+	// APIURL, NoPinning and NoProxy select another API host and dialer
+	// for the account.
+	APIURL             string
+	NoPinning, NoProxy bool
+}
+
+func (input loginInput) hostSettings() pmapi.HostSettings {
+	return pmapi.HostSettings{URL: input.APIURL, NoPinning: input.NoPinning, NoProxy: input.NoProxy}
+}
+
+type loginAddress struct {
+	Address, BridgePassword string
+}
+
+// loginOutput is printed as JSON to stdout after a successful login.
+type loginOutput struct {
+	UserID, Username string
+	AddressMode      string
+	AutoReauth       bool
+	BridgePassword   string
+	Addresses        []loginAddress
+}
+
+func loginCommand() cli.Command {
+	return cli.Command{
+		Name:  "login",
+		Usage: "Add account noninteractively and print its bridge password as JSON",
+		Description: "Reads JSON object with Username, Password, TOTP and MailboxPassword\n" +
+			"   from stdin (or --credentials-fd). Bridge must not be running.\n" +
+			"   Security key is used when neither TOTP nor TOTPSecret is given.\n" +
+			"   TOTPSecret can be given instead of TOTP. With \"AutoReauth\": true, the\n" +
+			"   password and TOTPSecret are stored to log in again when the session expires.\n" +
+			"   APIURL, NoPinning and NoProxy select another API host and dialer for the account;\n" +
+			"   APIURL requires NoPinning.\n\n" +
+			"   Exit codes:\n" +
+			fmt.Sprintf("     %d bridge is running\n", exitLoginBridgeRunning) +
+			fmt.Sprintf("     %d invalid input\n", exitLoginInvalidInput) +
+			fmt.Sprintf("     %d keychain is not available\n", exitLoginKeychain) +
+			fmt.Sprintf("     %d server is not reachable\n", exitLoginNoInternet) +
+			fmt.Sprintf("     %d application upgrade is required\n", exitLoginUpgrade) +
+			fmt.Sprintf("     %d wrong username or password\n", exitLoginWrongPassword) +
+			fmt.Sprintf("     %d two factor code is missing or wrong\n", exitLoginTwoFactor) +
+			fmt.Sprintf("     %d mailbox password is missing or wrong\n", exitLoginMailboxPassword) +
+			fmt.Sprintf("     %d account could not be added\n", exitLoginFailed) +
+			fmt.Sprintf("     %d unexpected crash\n", exitLoginPanic),
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "credentials-fd",
+				Usage: "Read the login JSON from the file descriptor instead of stdin"},
+		},
+		Action: login,
+	}
+}
+
+func login(context *cli.Context) error {
+	cfg := config.New(constants.AppShortName, constants.Version, constants.Revision, cacheVersion)
+	if err := cfg.CreateDirs(); err != nil {
+		return cli.NewExitError(fmt.Sprint("Cannot create necessary folders: ", err), exitLoginFailed)
+	}
+
+	config.SetupLog(cfg, context.GlobalString("log-level"))
+	// Stdout is reserved for the result.
+	if logrus.StandardLogger().Out == os.Stdout {
+		logrus.SetOutput(os.Stderr)
+	}
+
+	panicHandler := &loginPanicHandler{cfg: cfg}
+	defer panicHandler.HandlePanic()
+
+	input, err := readLoginInput(context.Int("credentials-fd"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), exitLoginInvalidInput)
+	}
+
+	lock, err := singleinstance.CreateLockFile(cfg.GetLockPath())
+	if err != nil {
+		return cli.NewExitError("Bridge is already running.", exitLoginBridgeRunning)
+	}
+	defer lock.Close() //nolint[errcheck]
+
+	credentialsStore, err := newCredentialsStore(context, cfg)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprint("Keychain is not available: ", err), exitLoginKeychain)
+	}
+
+	pref := preferences.New(cfg)
+	eventListener := listener.New()
+	events.SetupEvents(eventListener)
+
+	cm := pmapi.NewClientManager(cfg.GetAPIConfig())
+	cm.SetRoundTripper(cfg.GetRoundTripper(cm, eventListener))
+	cm.SetRoundTripperFactory(cfg.GetRoundTripperFactory(cm, eventListener))
+
+	bridgeInstance := bridge.New(cfg, pref, panicHandler, eventListener, cm, credentialsStore)
+
+	user, err := loginUser(bridgeInstance, input)
+	if err != nil {
+		return err
+	}
+
+	return printLoginOutput(user)
+}
+
+func readLoginInput(fd int) (input loginInput, err error) {
+	reader := os.Stdin
+	if fd > 0 {
+		reader = os.NewFile(uintptr(fd), "credentials")
+		defer reader.Close() //nolint[errcheck]
+	}
+
+	if err = json.NewDecoder(io.LimitReader(reader, maxLoginInputSize)).Decode(&input); err != nil {
+		return input, errors.Wrap(err, "cannot read login JSON")
+	}
+
+	if input.Username == "" || input.Password == "" {
+		return input, errors.New("username and password are required")
+	}
+
+	if err = input.hostSettings().Validate(); err != nil {
+		return input, err
+	}
+
+	return input, nil
+}
+
+// loginUser does the same steps as the interactive login but maps each
+// failure to its own exit code.
+func loginUser(b *bridge.Bridge, input loginInput) (*users.User, error) {
+	client, auth, err := b.LoginWithHost(input.Username, input.Password, input.hostSettings())
+	if err != nil {
+		return nil, loginExitError("Authentication failed", err, exitLoginWrongPassword)
+	}
+
+	if auth.HasTwoFactor() && auth.HasFIDO2() && input.TOTP == "" && input.TOTPSecret == "" {
+		authenticator, err := fido2.ForAuth(auth)
+		if err != nil {
+			return nil, cli.NewExitError(err.Error(), exitLoginTwoFactor)
+		}
+		if authenticator == nil {
+			return nil, cli.NewExitError("Two factor code is required", exitLoginTwoFactor)
+		}
+		if _, err = client.Auth2FAFIDO2(authenticator, auth); err != nil {
+			return nil, loginExitError("Security key authentication failed", err, exitLoginTwoFactor)
+		}
+	} else if auth.HasTwoFactor() {
+		if input.TOTP == "" && input.TOTPSecret != "" {
+			if input.TOTP, err = totp.Generate(input.TOTPSecret, time.Now()); err != nil {
+				return nil, cli.NewExitError(err.Error(), exitLoginTwoFactor)
+			}
+		}
+		if input.TOTP == "" {
+			return nil, cli.NewExitError("Two factor code is required", exitLoginTwoFactor)
+		}
+		if _, err = client.Auth2FA(input.TOTP, auth); err != nil {
+			return nil, loginExitError("Two factor authentication failed", err, exitLoginTwoFactor)
+		}
+	}
+
+	mailboxPassword := input.Password
+	if auth.HasMailboxPassword() {
+		if input.MailboxPassword == "" {
+			return nil, cli.NewExitError("Mailbox password is required", exitLoginMailboxPassword)
+		}
+		mailboxPassword = input.MailboxPassword
+	}
+
+	user, err := b.FinishLogin(client, auth, mailboxPassword)
+	if err != nil {
+		if err == users.ErrWrongMailboxPassword {
+			return nil, cli.NewExitError("Wrong mailbox password", exitLoginMailboxPassword)
+		}
+		return nil, loginExitError("Adding account failed", err, exitLoginFailed)
+	}
+
+	if input.AutoReauth {
+		if err := user.EnableAutoReauth(input.Password, input.TOTPSecret); err != nil {
+			return nil, loginExitError("Enabling automatic re-authentication failed", err, exitLoginFailed)
+		}
+	}
+
+	return user, nil
+}
+
+// loginExitError uses the given exit code unless the error is one of
+// the common API or keychain errors.
+func loginExitError(msg string, err error, code int) error {
+	switch errors.Cause(err) {
+	case pmapi.ErrAPINotReachable, pmapi.ErrConnectionSlow:
+		code = exitLoginNoInternet
+	case pmapi.ErrUpgradeApplication:
+		code = exitLoginUpgrade
+	case keychain.ErrNoKeychainInstalled:
+		code = exitLoginKeychain
+	}
+
+	return cli.NewExitError(fmt.Sprintf("%s: %v", msg, err), code)
+}
+
+func printLoginOutput(user *users.User) error {
+	output := loginOutput{
+		UserID:         user.ID(),
+		Username:       user.Username(),
+		AddressMode:    "split",
+		AutoReauth:     user.HasAutoReauth(),
+		BridgePassword: user.GetBridgePassword(),
+	}
+
+	if user.IsCombinedAddressMode() {
+		output.AddressMode = "combined"
+	}
+
+	for _, address := range user.GetAddresses() {
+		output.Addresses = append(output.Addresses, loginAddress{
+			Address:        address,
+			BridgePassword: user.GetAddressBridgePassword(address),
+		})
+	}
+
+	b, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return cli.NewExitError(err.Error(), exitLoginFailed)
+	}
+
+	fmt.Println(string(b))
+	return nil
+}
+
+// loginPanicHandler reports the panic and exits without restarting the app.
+type loginPanicHandler struct {
+	cfg *config.Config
+}
+
+func (ph *loginPanicHandler) HandlePanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	config.HandlePanic(ph.cfg, fmt.Sprintf("Recover: %v", r))
+	fmt.Fprintln(os.Stderr, "Login failed with panic:", r)
+	os.Exit(exitLoginPanic)
+}
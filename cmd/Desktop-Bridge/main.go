@@ -49,13 +49,14 @@ import (
 	"github.com/ProtonMail/proton-bridge/internal/bridge"
 	"github.com/ProtonMail/proton-bridge/internal/events"
 	"github.com/ProtonMail/proton-bridge/internal/frontend"
+	"github.com/ProtonMail/proton-bridge/internal/hooks"
 	"github.com/ProtonMail/proton-bridge/internal/imap"
 	"github.com/ProtonMail/proton-bridge/internal/preferences"
 	"github.com/ProtonMail/proton-bridge/internal/smtp"
-	"github.com/ProtonMail/proton-bridge/internal/users/credentials"
 	"github.com/ProtonMail/proton-bridge/pkg/args"
 	"github.com/ProtonMail/proton-bridge/pkg/config"
 	"github.com/ProtonMail/proton-bridge/pkg/constants"
+	"github.com/ProtonMail/proton-bridge/pkg/keychain"
 	"github.com/ProtonMail/proton-bridge/pkg/listener"
 	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
 	"github.com/ProtonMail/proton-bridge/pkg/updates"
@@ -113,9 +114,20 @@ func main() {
 		cli.BoolFlag{
 			Name:  "cpu-prof, p",
 			Usage: "Generate CPU profile"},
+		cli.StringFlag{
+			Name:  "keychain",
+			Usage: "Use keychain (one of " + strings.Join(keychain.Helpers(), ", ") + ") instead of the system default"},
+		cli.StringFlag{
+			Name:  "keychain-migrate-from",
+			Usage: "Move stored credentials from the given keychain to the used one"},
+		cli.IntFlag{
+			Name:  "keychain-passphrase-fd",
+			Value: -1,
+			Usage: "Read passphrase of the file keychain from the file descriptor (otherwise from " + keychainPassphraseEnv + " or terminal)"},
 	}
 	app.Usage = "ProtonMail IMAP and SMTP Bridge"
 	app.Action = run
+	app.Commands = []cli.Command{loginCommand(), dryRunMigrationsCommand()}
 
 	// Always log the basic info about current bridge.
 	logrus.SetLevel(logrus.InfoLevel)
@@ -260,8 +272,11 @@ func run(context *cli.Context) (contextError error) { // nolint[funlen]
 	log.Debug("Initializing bridge...")
 	eventListener := listener.New()
 	events.SetupEvents(eventListener)
+	hooks.New(panicHandler, eventListener, cfg.GetHooksPath()).Start()
 
-	credentialsStore, credentialsError := credentials.NewStore("bridge")
+	// When the keychain is not available, bridge starts with accounts locked
+	// and the credentials store tries to open the keychain again later.
+	credentialsStore, credentialsError := newCredentialsStore(context, cfg)
 	if credentialsError != nil {
 		log.Error("Could not get credentials store: ", credentialsError)
 	}
@@ -272,10 +287,11 @@ func run(context *cli.Context) (contextError error) { // nolint[funlen]
 	// TLS fingerprint checks in production builds). GetRoundTripper has a different
 	// implementation depending on whether build flag pmapi_prod is used or not.
 	cm.SetRoundTripper(cfg.GetRoundTripper(cm, eventListener))
+	cm.SetRoundTripperFactory(cfg.GetRoundTripperFactory(cm, eventListener))
 
 	bridgeInstance := bridge.New(cfg, pref, panicHandler, eventListener, cm, credentialsStore)
 	imapBackend := imap.NewIMAPBackend(panicHandler, eventListener, cfg, bridgeInstance)
-	smtpBackend := smtp.NewSMTPBackend(panicHandler, eventListener, pref, bridgeInstance)
+	smtpBackend := smtp.NewSMTPBackend(panicHandler, eventListener, cfg, pref, bridgeInstance)
 
 	go func() {
 		defer panicHandler.HandlePanic()
@@ -290,13 +306,28 @@ func run(context *cli.Context) (contextError error) { // nolint[funlen]
 		imapServer.ListenAndServe()
 	}()
 
-	go func() {
-		defer panicHandler.HandlePanic()
-		smtpPort := pref.GetInt(preferences.SMTPPortKey)
-		useSSL := pref.GetBool(preferences.SMTPSSLKey)
-		smtpServer := smtp.NewSMTPServer(debugClient || debugServer, smtpPort, useSSL, tls, smtpBackend, eventListener)
-		smtpServer.ListenAndServe()
-	}()
+	// Both SMTP listeners run at the same time so every client can use
+	// the security it supports.
+	smtpListeners := []struct {
+		enabledKey, portKey string
+		useSSL              bool
+	}{
+		{preferences.SMTPStartTLSEnabledKey, preferences.SMTPPortKey, false},
+		{preferences.SMTPSSLEnabledKey, preferences.SMTPSSLPortKey, true},
+	}
+	for _, smtpListener := range smtpListeners {
+		if !pref.GetBool(smtpListener.enabledKey) {
+			continue
+		}
+
+		smtpPort := pref.GetInt(smtpListener.portKey)
+		useSSL := smtpListener.useSSL
+		go func() {
+			defer panicHandler.HandlePanic()
+			smtpServer := smtp.NewSMTPServer(debugClient || debugServer, smtpPort, useSSL, tls, smtpBackend, eventListener)
+			smtpServer.ListenAndServe()
+		}()
+	}
 
 	// Decide about frontend mode before initializing rest of bridge.
 	var frontendMode string
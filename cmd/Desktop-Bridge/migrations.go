@@ -0,0 +1,81 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/ProtonMail/proton-bridge/internal/store"
+	"github.com/ProtonMail/proton-bridge/pkg/config"
+	"github.com/ProtonMail/proton-bridge/pkg/constants"
+	"github.com/allan-simon/go-singleinstance"
+	"github.com/urfave/cli"
+)
+
+func dryRunMigrationsCommand() cli.Command {
+	return cli.Command{
+		Name:  "dry-run-migrations",
+		Usage: "Print store database migrations applied at the next start without changing anything",
+		Description: "Runs all pending migrations of every account cache in a transaction\n" +
+			"   which is rolled back. Bridge must not be running.",
+		Action: dryRunMigrations,
+	}
+}
+
+func dryRunMigrations(context *cli.Context) error {
+	cfg := config.New(constants.AppShortName, constants.Version, constants.Revision, cacheVersion)
+	config.SetupLog(cfg, context.GlobalString("log-level"))
+
+	lock, err := singleinstance.CreateLockFile(cfg.GetLockPath())
+	if err != nil {
+		return cli.NewExitError("Bridge is already running.", 3)
+	}
+	defer lock.Close() //nolint[errcheck]
+
+	paths, err := filepath.Glob(filepath.Join(cfg.GetDBDir(), "mailbox-*.db"))
+	if err != nil {
+		return cli.NewExitError(fmt.Sprint("Cannot list account caches: ", err), 1)
+	}
+
+	failed := false
+	for _, path := range paths {
+		applied, err := store.DryRunMigrations(path)
+		if err != nil {
+			fmt.Printf("%s: migration would fail: %v\n", path, err)
+			failed = true
+			continue
+		}
+
+		if len(applied) == 0 {
+			fmt.Printf("%s: up to date\n", path)
+			continue
+		}
+
+		fmt.Printf("%s:\n", path)
+		for _, description := range applied {
+			fmt.Printf("  - %s\n", description)
+		}
+	}
+
+	if failed {
+		return cli.NewExitError("Some account caches cannot be migrated.", 1)
+	}
+
+	return nil
+}
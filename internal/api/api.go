@@ -19,6 +19,7 @@
 //
 // API endpoints:
 //  * /focus, see focusHandler
+//  * /poll, see pollHandler
 package api
 
 import (
@@ -64,6 +65,7 @@ func NewAPIServer(pref *config.Preferences, tls *tls.Config, certPath, keyPath s
 func (api *apiServer) ListenAndServe() {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/focus", wrapper(api, focusHandler))
+	mux.HandleFunc("/poll", wrapper(api, pollHandler))
 
 	addr := api.getAddress()
 	server := &http.Server{
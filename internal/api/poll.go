@@ -0,0 +1,35 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"fmt"
+
+	"github.com/ProtonMail/proton-bridge/internal/events"
+)
+
+// pollHandler should be called by push notification sources to fetch new
+// events right away. Optional `user` query parameter (user ID or address)
+// limits the polling to one account.
+func pollHandler(ctx handlerContext) error {
+	user := ctx.req.URL.Query().Get("user")
+	log.WithField("user", user).Debug("Poll events request")
+	ctx.eventListener.Emit(events.PollEventsEvent, user)
+	fmt.Fprintf(ctx.resp, "OK")
+	return nil
+}
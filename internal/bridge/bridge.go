@@ -22,6 +22,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/ProtonMail/proton-bridge/internal/events"
 	"github.com/ProtonMail/proton-bridge/internal/metrics"
 	"github.com/ProtonMail/proton-bridge/internal/preferences"
 	"github.com/ProtonMail/proton-bridge/internal/users"
@@ -73,10 +74,37 @@ func New(
 	}
 
 	go b.heartbeat()
+	go b.watchKnownAccounts(eventListener)
+	go b.watchPollRequests(eventListener)
 
 	return b
 }
 
+// watchPollRequests wakes the event loops when a push source announces new
+// events. The event data is the user ID or address; empty means all users.
+func (b *Bridge) watchPollRequests(eventListener listener.Listener) {
+	ch := make(chan string)
+	eventListener.Add(events.PollEventsEvent, ch)
+
+	for query := range ch {
+		targets := b.GetUsers()
+		if query != "" {
+			user, err := b.GetUser(query)
+			if err != nil {
+				log.WithError(err).Warn("Cannot poll events of unknown user")
+				continue
+			}
+			targets = []*users.User{user}
+		}
+
+		for _, user := range targets {
+			if store := user.GetStore(); store != nil {
+				store.WakeEventLoop()
+			}
+		}
+	}
+}
+
 // heartbeat sends a heartbeat signal once a day.
 func (b *Bridge) heartbeat() {
 	ticker := time.NewTicker(1 * time.Minute)
@@ -0,0 +1,91 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package bridge
+
+import (
+	"encoding/json"
+
+	"github.com/ProtonMail/proton-bridge/internal/events"
+	"github.com/ProtonMail/proton-bridge/internal/preferences"
+	"github.com/ProtonMail/proton-bridge/pkg/listener"
+)
+
+// GetLockedAccounts returns names of accounts which cannot be loaded because
+// the keychain is not available. They are remembered from the last time
+// the keychain was available; accounts loaded before it was locked are not included.
+func (b *Bridge) GetLockedAccounts() []string {
+	if b.GetKeychainError() == nil {
+		return nil
+	}
+
+	known := []string{}
+	if err := json.Unmarshal([]byte(b.pref.Get(preferences.KnownAccountsKey)), &known); err != nil {
+		log.WithError(err).Debug("No known accounts")
+	}
+
+	loaded := map[string]bool{}
+	for _, user := range b.GetUsers() {
+		loaded[user.Username()] = true
+	}
+
+	names := []string{}
+	for _, name := range known {
+		if !loaded[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// DeleteUser deletes the user and forgets it from known accounts.
+func (b *Bridge) DeleteUser(userID string, clearStore bool) error {
+	err := b.Users.DeleteUser(userID, clearStore)
+	b.updateKnownAccounts()
+	return err
+}
+
+func (b *Bridge) watchKnownAccounts(eventListener listener.Listener) {
+	b.updateKnownAccounts()
+
+	ch := make(chan string)
+	eventListener.Add(events.UserRefreshEvent, ch)
+
+	for range ch {
+		b.updateKnownAccounts()
+	}
+}
+
+// updateKnownAccounts remembers names of loaded accounts. Nothing is changed
+// while the keychain is not available so locked accounts are not forgotten.
+func (b *Bridge) updateKnownAccounts() {
+	if b.GetKeychainError() != nil {
+		return
+	}
+
+	names := []string{}
+	for _, user := range b.GetUsers() {
+		names = append(names, user.Username())
+	}
+
+	raw, err := json.Marshal(names)
+	if err != nil {
+		log.WithError(err).Error("Cannot encode known accounts")
+		return
+	}
+	b.pref.Set(preferences.KnownAccountsKey, string(raw))
+}
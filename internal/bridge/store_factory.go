@@ -19,6 +19,7 @@ package bridge
 
 import (
 	"fmt"
+	"io"
 	"path/filepath"
 
 	"github.com/ProtonMail/proton-bridge/internal/store"
@@ -62,6 +63,13 @@ func (f *storeFactory) Remove(userID string) error {
 	return store.RemoveStore(f.storeCache, storePath, userID)
 }
 
+// Import loads the exported store archive for given user.
+// It must be called before the store of the user is created.
+func (f *storeFactory) Import(userID string, archive io.Reader, passphrase []byte) error {
+	storePath := getUserStorePath(f.config.GetDBDir(), userID)
+	return store.ImportStore(f.storeCache, storePath, userID, archive, passphrase)
+}
+
 // getUserStorePath returns the file path of the store database for the given userID.
 func getUserStorePath(storeDir string, userID string) (path string) {
 	fileName := fmt.Sprintf("mailbox-%v.db", userID)
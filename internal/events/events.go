@@ -19,6 +19,7 @@
 package events
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/ProtonMail/proton-bridge/pkg/listener"
@@ -28,6 +29,8 @@ import (
 const (
 	ErrorEvent                   = "error"
 	CloseConnectionEvent         = "closeConnection"
+	RevokedAppPasswordEvent      = "revokedAppPassword"
+	RotatedBridgePasswordEvent   = "rotatedBridgePassword"
 	LogoutEvent                  = "logout"
 	AddressChangedEvent          = "addressChanged"
 	AddressChangedLogoutEvent    = "addressChangedLogout"
@@ -40,11 +43,80 @@ const (
 	NoActiveKeyForRecipientEvent = "noActiveKeyForRecipient"
 	UpgradeApplicationEvent      = "upgradeApplication"
 	TLSCertIssue                 = "tlsCertPinningIssue"
+	NewMessageEvent              = "newMessage"
+	SyncFinishedEvent            = "syncFinished"
+	SendFailedEvent              = "sendFailed"
+	KeychainLockedEvent          = "keychainLocked"
+	KeychainUnlockedEvent        = "keychainUnlocked"
+	PollEventsEvent              = "pollEvents"
 
 	// LogoutEventTimeout is the minimum time to permit between logout events being sent.
 	LogoutEventTimeout = 3 * time.Minute
 )
 
+// MessageEventData is the data of NewMessageEvent and SendFailedEvent
+// encoded by String.
+type MessageEventData struct {
+	UserID    string `json:"userID"`
+	Address   string `json:"address,omitempty"`
+	MessageID string `json:"messageID,omitempty"`
+	Sender    string `json:"sender,omitempty"`
+	Subject   string `json:"subject,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// String returns the data as JSON to be emitted by the event listener.
+func (data MessageEventData) String() string {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return ""
+	}
+	return string(raw)
+}
+
+// AppPasswordEventData is the data of RevokedAppPasswordEvent encoded by String.
+type AppPasswordEventData struct {
+	UserID string `json:"userID"`
+	Name   string `json:"name"`
+}
+
+// String returns the data as JSON to be emitted by the event listener.
+func (data AppPasswordEventData) String() string {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return ""
+	}
+	return string(raw)
+}
+
+// ParseAppPasswordEventData decodes the data of RevokedAppPasswordEvent.
+func ParseAppPasswordEventData(raw string) (data AppPasswordEventData, err error) {
+	err = json.Unmarshal([]byte(raw), &data)
+	return
+}
+
+// BridgePasswordEventData is the data of RotatedBridgePasswordEvent encoded
+// by String. Empty address means the shared password of all addresses.
+type BridgePasswordEventData struct {
+	UserID  string `json:"userID"`
+	Address string `json:"address,omitempty"`
+}
+
+// String returns the data as JSON to be emitted by the event listener.
+func (data BridgePasswordEventData) String() string {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return ""
+	}
+	return string(raw)
+}
+
+// ParseBridgePasswordEventData decodes the data of RotatedBridgePasswordEvent.
+func ParseBridgePasswordEventData(raw string) (data BridgePasswordEventData, err error) {
+	err = json.Unmarshal([]byte(raw), &data)
+	return
+}
+
 // SetupEvents specific to event type and data.
 func SetupEvents(listener listener.Listener) {
 	listener.SetLimit(LogoutEvent, LogoutEventTimeout)
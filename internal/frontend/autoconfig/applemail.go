@@ -71,7 +71,7 @@ func (c *appleMail) Configure(imapPort, smtpPort int, imapSSL, smtpSSL bool, use
 			Port:     imapPort,
 			Tls:      imapSSL,
 			Username: displayName,
-			Password: user.GetBridgePassword(),
+			Password: user.GetAddressBridgePassword(displayName),
 		},
 		Smtp: &mobileconfig.Smtp{
 			Hostname: bridge.Host,
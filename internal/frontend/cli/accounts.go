@@ -18,11 +18,15 @@
 package cli
 
 import (
+	"io"
+	"os"
 	"strings"
 
 	"github.com/ProtonMail/proton-bridge/internal/bridge"
 	"github.com/ProtonMail/proton-bridge/internal/frontend/types"
 	"github.com/ProtonMail/proton-bridge/internal/preferences"
+	"github.com/ProtonMail/proton-bridge/pkg/fido2"
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
 	"github.com/abiosoft/ishell"
 )
 
@@ -40,6 +44,9 @@ func (f *frontendCLI) listAccounts(c *ishell.Context) {
 		}
 		f.Printf(spacing, idx, user.Username(), connected, mode)
 	}
+	for _, name := range f.bridge.GetLockedAccounts() {
+		f.Printf("%-2s: %-20s (%-15s, %-15s)\n", "-", name, "locked", "-")
+	}
 	f.Println()
 }
 
@@ -54,6 +61,14 @@ func (f *frontendCLI) showAccountInfo(c *ishell.Context) {
 		return
 	}
 
+	if host := user.GetHostSettings(); !host.IsDefault() {
+		apiURL := host.URL
+		if apiURL == "" {
+			apiURL = "default"
+		}
+		f.Printf("API host:  %s (pinning disabled: %t, proxy disabled: %t)\n\n", apiURL, host.NoPinning, host.NoProxy)
+	}
+
 	if user.IsCombinedAddressMode() {
 		f.showAccountAddressInfo(user, user.GetPrimaryAddress())
 	} else {
@@ -64,30 +79,38 @@ func (f *frontendCLI) showAccountInfo(c *ishell.Context) {
 }
 
 func (f *frontendCLI) showAccountAddressInfo(user types.BridgeUser, address string) {
-	smtpSecurity := "STARTTLS"
-	if f.preferences.GetBool(preferences.SMTPSSLKey) {
-		smtpSecurity = "SSL"
-	}
 	f.Println(bold("Configuration for " + address))
 	f.Printf("IMAP Settings\nAddress:   %s\nIMAP port: %d\nUsername:  %s\nPassword:  %s\nSecurity:  %s\n",
 		bridge.Host,
 		f.preferences.GetInt(preferences.IMAPPortKey),
 		address,
-		user.GetBridgePassword(),
+		user.GetAddressBridgePassword(address),
 		"STARTTLS",
 	)
 	f.Println("")
-	f.Printf("SMTP Settings\nAddress:   %s\nIMAP port: %d\nUsername:  %s\nPassword:  %s\nSecurity:  %s\n",
-		bridge.Host,
-		f.preferences.GetInt(preferences.SMTPPortKey),
-		address,
-		user.GetBridgePassword(),
-		smtpSecurity,
-	)
-	f.Println("")
+
+	smtpListeners := []struct {
+		enabledKey, portKey, security string
+	}{
+		{preferences.SMTPStartTLSEnabledKey, preferences.SMTPPortKey, "STARTTLS"},
+		{preferences.SMTPSSLEnabledKey, preferences.SMTPSSLPortKey, "SSL"},
+	}
+	for _, smtpListener := range smtpListeners {
+		if !f.preferences.GetBool(smtpListener.enabledKey) {
+			continue
+		}
+		f.Printf("SMTP Settings\nAddress:   %s\nSMTP port: %d\nUsername:  %s\nPassword:  %s\nSecurity:  %s\n",
+			bridge.Host,
+			f.preferences.GetInt(smtpListener.portKey),
+			address,
+			user.GetAddressBridgePassword(address),
+			smtpListener.security,
+		)
+		f.Println("")
+	}
 }
 
-func (f *frontendCLI) loginAccount(c *ishell.Context) { // nolint[funlen]
+func (f *frontendCLI) loginAccount(c *ishell.Context) {
 	f.ShowPrompt(false)
 	defer f.ShowPrompt(true)
 
@@ -99,6 +122,99 @@ func (f *frontendCLI) loginAccount(c *ishell.Context) { // nolint[funlen]
 		}
 	}
 
+	f.login(c, loginName, nil, pmapi.HostSettings{})
+}
+
+func (f *frontendCLI) loginAccountWithHost(c *ishell.Context) {
+	f.ShowPrompt(false)
+	defer f.ShowPrompt(true)
+
+	if len(c.Args) == 0 {
+		f.Println("Please provide API URL, e.g. https://api.example.com, or default.")
+		return
+	}
+
+	host := pmapi.HostSettings{}
+	if c.Args[0] != "default" {
+		host.URL = c.Args[0]
+	}
+	for _, option := range c.Args[1:] {
+		switch option {
+		case "no-pinning":
+			host.NoPinning = true
+		case "no-proxy":
+			host.NoProxy = true
+		default:
+			f.Println("Unknown option:", option)
+			return
+		}
+	}
+
+	if err := host.Validate(); err != nil {
+		f.printAndLogError(err)
+		return
+	}
+
+	f.login(c, "", nil, host)
+}
+
+func (f *frontendCLI) importAccount(c *ishell.Context) {
+	f.ShowPrompt(false)
+	defer f.ShowPrompt(true)
+
+	if len(c.Args) == 0 {
+		f.Println("Please provide path to the exported account file.")
+		return
+	}
+
+	archive, err := os.Open(c.Args[0])
+	if err != nil {
+		f.printAndLogError("Cannot open exported account file: ", err)
+		return
+	}
+	defer archive.Close() //nolint[errcheck]
+
+	f.login(c, "", archive, pmapi.HostSettings{})
+}
+
+func (f *frontendCLI) exportAccount(c *ishell.Context) {
+	f.ShowPrompt(false)
+	defer f.ShowPrompt(true)
+
+	user := f.askUserByIndexOrName(c)
+	if user == nil {
+		return
+	}
+
+	path := f.readStringInAttempts("Export to file", c.ReadLine, isNotEmpty)
+	if path == "" {
+		return
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		f.printAndLogError("Cannot create export file: ", err)
+		return
+	}
+
+	err = user.ExportStore(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		f.printAndLogError("Cannot export account: ", err)
+		return
+	}
+
+	f.Printf("Account %s was exported to %s.\n", bold(user.Username()), path)
+	f.Println("Use the import command with this file on another machine to login without full synchronisation.")
+}
+
+// login asks for credentials and adds or connects the account. If `archive`
+// is set, the account cache is loaded from it instead of full synchronisation.
+// The account uses the API host and dialer given by `host`.
+func (f *frontendCLI) login(c *ishell.Context, loginName string, archive io.Reader, host pmapi.HostSettings) { // nolint[funlen]
 	if loginName == "" {
 		loginName = f.readStringInAttempts("Username", c.ReadLine, isNotEmpty)
 		if loginName == "" {
@@ -114,23 +230,14 @@ func (f *frontendCLI) loginAccount(c *ishell.Context) { // nolint[funlen]
 	}
 
 	f.Println("Authenticating ... ")
-	client, auth, err := f.bridge.Login(loginName, password)
+	client, auth, err := f.bridge.LoginWithHost(loginName, password, host)
 	if err != nil {
 		f.processAPIError(err)
 		return
 	}
 
-	if auth.HasTwoFactor() {
-		twoFactor := f.readStringInAttempts("Two factor code", c.ReadLine, isNotEmpty)
-		if twoFactor == "" {
-			return
-		}
-
-		_, err = client.Auth2FA(twoFactor, auth)
-		if err != nil {
-			f.processAPIError(err)
-			return
-		}
+	if auth.HasTwoFactor() && !f.authSecondFactor(c, client, auth) {
+		return
 	}
 
 	mailboxPassword := password
@@ -142,7 +249,7 @@ func (f *frontendCLI) loginAccount(c *ishell.Context) { // nolint[funlen]
 	}
 
 	f.Println("Adding account ...")
-	user, err := f.bridge.FinishLogin(client, auth, mailboxPassword)
+	user, err := f.bridge.FinishLoginWithStore(client, auth, mailboxPassword, archive)
 	if err != nil {
 		log.WithField("username", loginName).WithError(err).Error("Login was unsuccessful")
 		f.Println("Adding account was unsuccessful:", err)
@@ -152,6 +259,37 @@ func (f *frontendCLI) loginAccount(c *ishell.Context) { // nolint[funlen]
 	f.Printf("Account %s was added successfully.\n", bold(user.Username()))
 }
 
+// authSecondFactor uses the security key if the account has one and it is
+// supported; if the account has TOTP too, the user can choose.
+func (f *frontendCLI) authSecondFactor(c *ishell.Context, client pmapi.Client, auth *pmapi.Auth) bool {
+	authenticator, err := fido2.ForAuth(auth)
+	if err != nil {
+		f.printAndLogError("Cannot log in: ", err)
+		return false
+	}
+
+	if authenticator != nil && (!auth.HasTOTP() || f.yesNoQuestion("Use security key")) {
+		f.Println("Touch your security key ...")
+		if _, err := client.Auth2FAFIDO2(authenticator, auth); err != nil {
+			f.processAPIError(err)
+			return false
+		}
+		return true
+	}
+
+	twoFactor := f.readStringInAttempts("Two factor code", c.ReadLine, isNotEmpty)
+	if twoFactor == "" {
+		return false
+	}
+
+	if _, err := client.Auth2FA(twoFactor, auth); err != nil {
+		f.processAPIError(err)
+		return false
+	}
+
+	return true
+}
+
 func (f *frontendCLI) logoutAccount(c *ishell.Context) {
 	f.ShowPrompt(false)
 	defer f.ShowPrompt(true)
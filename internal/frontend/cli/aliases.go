@@ -0,0 +1,121 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"sort"
+
+	"github.com/abiosoft/ishell"
+)
+
+func (f *frontendCLI) listAliases(c *ishell.Context) {
+	spacing := "%-20s %-30s %s\n"
+	f.Printf(bold(spacing), "account", "alias", "sent as")
+
+	empty := true
+	for _, user := range f.bridge.GetUsers() {
+		if !user.IsConnected() {
+			continue
+		}
+
+		aliases, err := user.GetSenderAliases()
+		if err != nil {
+			f.printAndLogError("Cannot list aliases of ", user.Username(), ": ", err)
+			continue
+		}
+
+		names := []string{}
+		for alias := range aliases {
+			names = append(names, alias)
+		}
+		sort.Strings(names)
+
+		for _, alias := range names {
+			empty = false
+			f.Printf(spacing, user.Username(), alias, aliases[alias])
+		}
+	}
+
+	if empty {
+		f.Println("There are no aliases.")
+	}
+}
+
+func (f *frontendCLI) addAlias(c *ishell.Context) {
+	f.ShowPrompt(false)
+	defer f.ShowPrompt(true)
+
+	user := f.askUserByIndexOrName(c)
+	if user == nil {
+		return
+	}
+
+	alias := ""
+	if len(c.Args) > 1 {
+		alias = c.Args[1]
+	} else {
+		alias = f.readStringInAttempts("Alias", c.ReadLine, isNotEmpty)
+	}
+	if alias == "" {
+		return
+	}
+
+	address := ""
+	if len(c.Args) > 2 {
+		address = c.Args[2]
+	} else {
+		address = f.readStringInAttempts("Address of the account", c.ReadLine, isNotEmpty)
+	}
+	if address == "" {
+		return
+	}
+
+	if err := user.SetSenderAlias(alias, address); err != nil {
+		f.printAndLogError("Cannot add alias: ", err)
+		return
+	}
+
+	f.Println("Messages from", bold(alias), "will be sent using", bold(address)+".")
+}
+
+func (f *frontendCLI) removeAlias(c *ishell.Context) {
+	f.ShowPrompt(false)
+	defer f.ShowPrompt(true)
+
+	user := f.askUserByIndexOrName(c)
+	if user == nil {
+		return
+	}
+
+	alias := ""
+	if len(c.Args) > 1 {
+		alias = c.Args[1]
+	} else {
+		alias = f.readStringInAttempts("Alias", c.ReadLine, isNotEmpty)
+	}
+	if alias == "" {
+		return
+	}
+
+	if err := user.DeleteSenderAlias(alias); err != nil {
+		f.printAndLogError("Cannot remove alias: ", err)
+		return
+	}
+
+	f.Println("Alias", alias, "was removed.")
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"time"
+
+	"github.com/abiosoft/ishell"
+)
+
+func formatUnixTime(timestamp int64) string {
+	if timestamp == 0 {
+		return "never"
+	}
+	return time.Unix(timestamp, 0).Format("2006-01-02 15:04")
+}
+
+func (f *frontendCLI) listAppPasswords(c *ishell.Context) {
+	spacing := "%-20s %-20s %-16s %-16s %s\n"
+	f.Printf(bold(spacing), "account", "client", "created", "last used", "revoked")
+
+	empty := true
+	for _, user := range f.bridge.GetUsers() {
+		for _, appPassword := range user.ListAppPasswords() {
+			empty = false
+			revoked := "no"
+			if appPassword.IsRevoked() {
+				revoked = formatUnixTime(appPassword.Revoked)
+			}
+			f.Printf(spacing,
+				user.Username(),
+				appPassword.Name,
+				formatUnixTime(appPassword.Created),
+				formatUnixTime(appPassword.LastUsed),
+				revoked,
+			)
+		}
+	}
+
+	if empty {
+		f.Println("There are no app passwords.")
+	}
+}
+
+func (f *frontendCLI) addAppPassword(c *ishell.Context) {
+	f.ShowPrompt(false)
+	defer f.ShowPrompt(true)
+
+	user := f.askUserByIndexOrName(c)
+	if user == nil {
+		return
+	}
+
+	name := ""
+	if len(c.Args) > 1 {
+		name = c.Args[1]
+	} else {
+		name = f.readStringInAttempts("Name of the client", c.ReadLine, isNotEmpty)
+	}
+	if name == "" {
+		return
+	}
+
+	password, err := user.AddAppPassword(name)
+	if err != nil {
+		f.printAndLogError("Cannot add app password: ", err)
+		return
+	}
+
+	f.Println("Password for", bold(name)+":", password)
+	f.Println("Use it instead of the bridge password. It will not be shown again.")
+}
+
+func (f *frontendCLI) revokeAppPassword(c *ishell.Context) {
+	f.ShowPrompt(false)
+	defer f.ShowPrompt(true)
+
+	user := f.askUserByIndexOrName(c)
+	if user == nil {
+		return
+	}
+
+	name := ""
+	if len(c.Args) > 1 {
+		name = c.Args[1]
+	} else {
+		name = f.readStringInAttempts("Name of the client", c.ReadLine, isNotEmpty)
+	}
+	if name == "" {
+		return
+	}
+
+	if err := user.RevokeAppPassword(name); err != nil {
+		f.printAndLogError("Cannot revoke app password: ", err)
+		return
+	}
+
+	f.Println("Password for", bold(name), "was revoked.")
+}
+
+func (f *frontendCLI) rotateBridgePassword(c *ishell.Context) {
+	f.ShowPrompt(false)
+	defer f.ShowPrompt(true)
+
+	user := f.askUserByIndexOrName(c)
+	if user == nil {
+		return
+	}
+
+	address := user.GetPrimaryAddress()
+	if len(c.Args) > 1 {
+		address = c.Args[1]
+	} else if !user.IsCombinedAddressMode() {
+		address = f.readStringInAttempts("Address", c.ReadLine, isNotEmpty)
+	}
+	if address == "" {
+		return
+	}
+
+	if !f.yesNoQuestion("Are you sure you want to change the bridge password used by " + bold(address) + " and disconnect its clients") {
+		return
+	}
+
+	if err := user.RotateBridgePassword(address); err != nil {
+		f.printAndLogError("Cannot change bridge password: ", err)
+		return
+	}
+
+	f.Println("New bridge password for", bold(address)+":", user.GetAddressBridgePassword(address))
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"github.com/abiosoft/ishell"
+)
+
+func (f *frontendCLI) listAutoReauth(c *ishell.Context) {
+	spacing := "%-20s %s\n"
+	f.Printf(bold(spacing), "account", "automatic re-authentication")
+	for _, user := range f.bridge.GetUsers() {
+		status := "disabled"
+		if user.HasAutoReauth() {
+			status = "enabled"
+		}
+		f.Printf(spacing, user.Username(), status)
+	}
+}
+
+func (f *frontendCLI) enableAutoReauth(c *ishell.Context) {
+	f.ShowPrompt(false)
+	defer f.ShowPrompt(true)
+
+	user := f.askUserByIndexOrName(c)
+	if user == nil {
+		return
+	}
+
+	f.Println("The login password and the TOTP secret will be stored in the keychain.")
+	f.Println("Anyone with access to the keychain will be able to log in to the account.")
+	if !f.yesNoQuestion("Are you sure you want to enable automatic re-authentication for " + bold(user.Username())) {
+		return
+	}
+
+	password := f.readStringInAttempts("Login password", c.ReadPassword, isNotEmpty)
+	if password == "" {
+		return
+	}
+
+	f.Print("TOTP secret or otpauth:// URI (leave empty if 2FA is disabled): ")
+	totpSecret := c.ReadPassword()
+
+	if err := user.EnableAutoReauth(password, totpSecret); err != nil {
+		f.printAndLogError("Cannot enable automatic re-authentication: ", err)
+		return
+	}
+
+	f.Println("Automatic re-authentication was enabled.")
+}
+
+func (f *frontendCLI) disableAutoReauth(c *ishell.Context) {
+	f.ShowPrompt(false)
+	defer f.ShowPrompt(true)
+
+	user := f.askUserByIndexOrName(c)
+	if user == nil {
+		return
+	}
+
+	if err := user.DisableAutoReauth(); err != nil {
+		f.printAndLogError("Cannot disable automatic re-authentication: ", err)
+		return
+	}
+
+	f.Println("Automatic re-authentication was disabled and the stored secrets were removed.")
+}
@@ -41,6 +41,7 @@ type frontendCLI struct {
 	eventListener listener.Listener
 	updates       types.Updater
 	bridge        types.Bridger
+	previewer     types.SendingPreviewer
 
 	appRestart bool
 }
@@ -53,6 +54,7 @@ func New( //nolint[funlen]
 	eventListener listener.Listener,
 	updates types.Updater,
 	bridge types.Bridger,
+	previewer types.SendingPreviewer,
 ) *frontendCLI { //nolint[golint]
 	fe := &frontendCLI{
 		Shell: ishell.New(),
@@ -62,6 +64,7 @@ func New( //nolint[funlen]
 		eventListener: eventListener,
 		updates:       updates,
 		bridge:        bridge,
+		previewer:     previewer,
 
 		appRestart: false,
 	}
@@ -94,8 +97,14 @@ func New( //nolint[funlen]
 		Func:      fe.changeMode,
 		Completer: fe.completeUsernames,
 	})
+	changeCmd.AddCmd(&ishell.Cmd{Name: "password",
+		Help:      "generate new bridge password for account or, in split mode, for one address. Use index or account name and address as parameters. (alias: pass)",
+		Aliases:   []string{"pass"},
+		Func:      fe.noAccountWrapper(fe.rotateBridgePassword),
+		Completer: fe.completeUsernames,
+	})
 	changeCmd.AddCmd(&ishell.Cmd{Name: "port",
-		Help:    "change port numbers of IMAP and SMTP servers. (alias: p)",
+		Help:    "change port numbers of IMAP and both SMTP servers. (alias: p)",
 		Aliases: []string{"p"},
 		Func:    fe.changePort,
 	})
@@ -103,10 +112,10 @@ func New( //nolint[funlen]
 		Help: "allow or disallow bridge to securely connect to proton via a third party when it is being blocked",
 		Func: fe.toggleAllowProxy,
 	})
-	changeCmd.AddCmd(&ishell.Cmd{Name: "smtp-security",
-		Help:    "change port numbers of IMAP and SMTP servers.(alias: ssl, starttls)",
-		Aliases: []string{"ssl", "starttls"},
-		Func:    fe.changeSMTPSecurity,
+	changeCmd.AddCmd(&ishell.Cmd{Name: "smtp-listeners",
+		Help:    "enable or disable SMTP listeners using STARTTLS and SSL. Port numbers are changed by change port. (aliases: smtp, ssl, starttls)",
+		Aliases: []string{"smtp", "ssl", "starttls"},
+		Func:    fe.changeSMTPListeners,
 	})
 	fe.AddCmd(changeCmd)
 
@@ -163,6 +172,19 @@ func New( //nolint[funlen]
 		Aliases:   []string{"add", "a", "con", "connect"},
 		Completer: fe.completeUsernames,
 	})
+	fe.AddCmd(&ishell.Cmd{Name: "login-host",
+		Help: "login procedure using another API host. Use API URL (or default) as parameter, followed by no-pinning (required with API URL) and optionally no-proxy.",
+		Func: fe.loginAccountWithHost,
+	})
+	fe.AddCmd(&ishell.Cmd{Name: "import",
+		Help: "login procedure loading the account cache exported on another machine. Use path to the exported file as parameter.",
+		Func: fe.importAccount,
+	})
+	fe.AddCmd(&ishell.Cmd{Name: "export",
+		Help:      "export the account cache to a file encrypted by the mailbox password. Use index or account name as parameter.",
+		Func:      fe.noAccountWrapper(fe.exportAccount),
+		Completer: fe.completeUsernames,
+	})
 	fe.AddCmd(&ishell.Cmd{Name: "logout",
 		Help:      "disconnect the account. Use index or account name as parameter. (aliases: d, disconnect)",
 		Func:      fe.noAccountWrapper(fe.logoutAccount),
@@ -176,6 +198,116 @@ func New( //nolint[funlen]
 		Completer: fe.completeUsernames,
 	})
 
+	// Outbox commands.
+	outboxCmd := &ishell.Cmd{Name: "outbox",
+		Help:    "manage messages waiting to be sent when the connection is back. (alias: queue)",
+		Aliases: []string{"queue"},
+	}
+	outboxCmd.AddCmd(&ishell.Cmd{Name: "list",
+		Help:    "print messages waiting to be sent. (aliases: l, ls)",
+		Aliases: []string{"l", "ls"},
+		Func:    fe.noAccountWrapper(fe.listOutbox),
+	})
+	outboxCmd.AddCmd(&ishell.Cmd{Name: "cancel",
+		Help:      "cancel sending of the message. Use index or account name and message id as parameters. (aliases: rm, remove)",
+		Aliases:   []string{"rm", "remove"},
+		Func:      fe.noAccountWrapper(fe.cancelOutbox),
+		Completer: fe.completeUsernames,
+	})
+	fe.AddCmd(outboxCmd)
+
+	scheduledCmd := &ishell.Cmd{Name: "scheduled",
+		Help:    "manage messages scheduled to be sent later using the X-PM-Send-At header. (alias: later)",
+		Aliases: []string{"later"},
+	}
+	scheduledCmd.AddCmd(&ishell.Cmd{Name: "list",
+		Help:    "print messages scheduled to be sent. (aliases: l, ls)",
+		Aliases: []string{"l", "ls"},
+		Func:    fe.noAccountWrapper(fe.listScheduled),
+	})
+	scheduledCmd.AddCmd(&ishell.Cmd{Name: "cancel",
+		Help:      "cancel scheduled sending and keep the message in drafts. Use index or account name and message id as parameters. (aliases: rm, remove)",
+		Aliases:   []string{"rm", "remove"},
+		Func:      fe.noAccountWrapper(fe.cancelScheduled),
+		Completer: fe.completeUsernames,
+	})
+	fe.AddCmd(scheduledCmd)
+
+	aliasesCmd := &ishell.Cmd{Name: "aliases",
+		Help: "manage addresses which can be used to send messages besides addresses of the account and their plus variants.",
+	}
+	aliasesCmd.AddCmd(&ishell.Cmd{Name: "list",
+		Help:    "print sender aliases. (aliases: l, ls)",
+		Aliases: []string{"l", "ls"},
+		Func:    fe.noAccountWrapper(fe.listAliases),
+	})
+	aliasesCmd.AddCmd(&ishell.Cmd{Name: "add",
+		Help:      "send messages from alias using address of the account. Use index or account name, alias and address as parameters. (alias: set)",
+		Aliases:   []string{"set"},
+		Func:      fe.noAccountWrapper(fe.addAlias),
+		Completer: fe.completeUsernames,
+	})
+	aliasesCmd.AddCmd(&ishell.Cmd{Name: "remove",
+		Help:      "remove sender alias. Use index or account name and alias as parameters. (aliases: rm, del)",
+		Aliases:   []string{"rm", "del"},
+		Func:      fe.noAccountWrapper(fe.removeAlias),
+		Completer: fe.completeUsernames,
+	})
+	fe.AddCmd(aliasesCmd)
+
+	// App password commands.
+	appPasswordsCmd := &ishell.Cmd{Name: "app-passwords",
+		Help:    "manage passwords of individual mail clients. (alias: apps)",
+		Aliases: []string{"apps"},
+	}
+	appPasswordsCmd.AddCmd(&ishell.Cmd{Name: "list",
+		Help:    "print app passwords of all accounts. (aliases: l, ls)",
+		Aliases: []string{"l", "ls"},
+		Func:    fe.noAccountWrapper(fe.listAppPasswords),
+	})
+	appPasswordsCmd.AddCmd(&ishell.Cmd{Name: "add",
+		Help:      "generate password for a mail client. Use index or account name and name of the client as parameters. (alias: new)",
+		Aliases:   []string{"new"},
+		Func:      fe.noAccountWrapper(fe.addAppPassword),
+		Completer: fe.completeUsernames,
+	})
+	appPasswordsCmd.AddCmd(&ishell.Cmd{Name: "revoke",
+		Help:      "revoke password of a mail client and close its connections. Use index or account name and name of the client as parameters. (aliases: rm, del)",
+		Aliases:   []string{"rm", "del"},
+		Func:      fe.noAccountWrapper(fe.revokeAppPassword),
+		Completer: fe.completeUsernames,
+	})
+	fe.AddCmd(appPasswordsCmd)
+
+	// Automatic re-authentication commands.
+	autoReauthCmd := &ishell.Cmd{Name: "auto-reauth",
+		Help: "log in again automatically with stored login password and TOTP secret when the session expires.",
+	}
+	autoReauthCmd.AddCmd(&ishell.Cmd{Name: "list",
+		Help:    "print which accounts have automatic re-authentication enabled. (aliases: l, ls)",
+		Aliases: []string{"l", "ls"},
+		Func:    fe.noAccountWrapper(fe.listAutoReauth),
+	})
+	autoReauthCmd.AddCmd(&ishell.Cmd{Name: "enable",
+		Help:      "store login password and TOTP secret of account in keychain. Use index or account name as parameter. (alias: on)",
+		Aliases:   []string{"on"},
+		Func:      fe.noAccountWrapper(fe.enableAutoReauth),
+		Completer: fe.completeUsernames,
+	})
+	autoReauthCmd.AddCmd(&ishell.Cmd{Name: "disable",
+		Help:      "remove login password and TOTP secret of account from keychain. Use index or account name as parameter. (alias: off)",
+		Aliases:   []string{"off"},
+		Func:      fe.noAccountWrapper(fe.disableAutoReauth),
+		Completer: fe.completeUsernames,
+	})
+	fe.AddCmd(autoReauthCmd)
+
+	fe.AddCmd(&ishell.Cmd{Name: "dry-run",
+		Help:    "print how a message would be encrypted and signed for each recipient without sending it. Use sender address and recipients as parameters, add --plain for plain text message. (alias: preview)",
+		Aliases: []string{"preview"},
+		Func:    fe.previewSending,
+	})
+
 	// System commands.
 	fe.AddCmd(&ishell.Cmd{Name: "restart",
 		Help: "restart the bridge.",
@@ -199,6 +331,8 @@ func (f *frontendCLI) watchEvents() {
 	addressChangedLogoutCh := f.getEventChannel(events.AddressChangedLogoutEvent)
 	logoutCh := f.getEventChannel(events.LogoutEvent)
 	certIssue := f.getEventChannel(events.TLSCertIssue)
+	keychainLockedCh := f.getEventChannel(events.KeychainLockedEvent)
+	keychainUnlockedCh := f.getEventChannel(events.KeychainUnlockedEvent)
 	for {
 		select {
 		case errorDetails := <-errorCh:
@@ -219,6 +353,10 @@ func (f *frontendCLI) watchEvents() {
 			f.notifyLogout(user.Username())
 		case <-certIssue:
 			f.notifyCertIssue()
+		case <-keychainLockedCh:
+			f.Println("Keychain is locked, accounts which are not loaded yet stay locked until it is unlocked.")
+		case <-keychainUnlockedCh:
+			f.Println("Keychain is available, accounts are unlocked.")
 		}
 	}
 }
@@ -236,9 +374,8 @@ func (f *frontendCLI) IsAppRestarting() bool {
 
 // Loop starts the frontend loop with an interactive shell.
 func (f *frontendCLI) Loop(credentialsError error) error {
-	if credentialsError != nil {
+	if credentialsError != nil || f.bridge.GetKeychainError() != nil {
 		f.notifyCredentialsError()
-		return credentialsError
 	}
 
 	f.preferences.SetBool(preferences.FirstStartKey, false)
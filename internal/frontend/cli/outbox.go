@@ -0,0 +1,157 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"strings"
+
+	"github.com/abiosoft/ishell"
+)
+
+func (f *frontendCLI) listOutbox(c *ishell.Context) {
+	spacing := "%-20s %-4s %-30s %-30s %-8s %s\n"
+	f.Printf(bold(spacing), "account", "id", "subject", "recipients", "attempts", "status")
+
+	empty := true
+	for _, user := range f.bridge.GetUsers() {
+		if !user.IsConnected() {
+			continue
+		}
+
+		msgs, err := user.ListOutgoing()
+		if err != nil {
+			f.printAndLogError("Cannot list outbox of ", user.Username(), ": ", err)
+			continue
+		}
+
+		for _, msg := range msgs {
+			empty = false
+
+			status := "waiting"
+			if msg.Failed {
+				status = "failed: " + msg.LastError
+			} else if msg.LastError != "" {
+				status = "retrying at " + msg.NextAttempt.Format("15:04:05") + ": " + msg.LastError
+			}
+
+			f.Printf(spacing, user.Username(), msg.ID, msg.Subject, strings.Join(msg.To, ", "), msg.Attempts, status)
+		}
+	}
+
+	if empty {
+		f.Println("Outbox is empty.")
+	}
+}
+
+func (f *frontendCLI) cancelOutbox(c *ishell.Context) {
+	f.ShowPrompt(false)
+	defer f.ShowPrompt(true)
+
+	user := f.askUserByIndexOrName(c)
+	if user == nil {
+		return
+	}
+
+	id := ""
+	if len(c.Args) > 1 {
+		id = c.Args[1]
+	} else {
+		id = f.readStringInAttempts("Message id", c.ReadLine, isNotEmpty)
+	}
+	if id == "" {
+		return
+	}
+
+	if !f.yesNoQuestion("Are you sure you want to cancel sending of message " + bold(id)) {
+		return
+	}
+
+	if err := user.CancelOutgoing(id); err != nil {
+		f.printAndLogError("Cannot cancel sending: ", err)
+		return
+	}
+
+	f.Println("Sending of message", id, "was canceled.")
+}
+
+func (f *frontendCLI) listScheduled(c *ishell.Context) {
+	spacing := "%-20s %-20s %-30s %-30s %-20s %s\n"
+	f.Printf(bold(spacing), "account", "send at", "subject", "recipients", "id", "status")
+
+	empty := true
+	for _, user := range f.bridge.GetUsers() {
+		if !user.IsConnected() {
+			continue
+		}
+
+		sends, err := user.ListScheduledSends()
+		if err != nil {
+			f.printAndLogError("Cannot list scheduled messages of ", user.Username(), ": ", err)
+			continue
+		}
+
+		for _, send := range sends {
+			empty = false
+
+			status := "scheduled"
+			if send.Failed {
+				status = "failed: " + send.LastError
+			} else if send.LastError != "" {
+				status = "retrying at " + send.NextAttempt.Format("15:04:05") + ": " + send.LastError
+			}
+
+			sendAt := send.SendAt.Local().Format("2006-01-02 15:04")
+			f.Printf(spacing, user.Username(), sendAt, send.Subject, strings.Join(send.To, ", "), send.ID, status)
+		}
+	}
+
+	if empty {
+		f.Println("There are no scheduled messages.")
+	}
+}
+
+func (f *frontendCLI) cancelScheduled(c *ishell.Context) {
+	f.ShowPrompt(false)
+	defer f.ShowPrompt(true)
+
+	user := f.askUserByIndexOrName(c)
+	if user == nil {
+		return
+	}
+
+	id := ""
+	if len(c.Args) > 1 {
+		id = c.Args[1]
+	} else {
+		id = f.readStringInAttempts("Message id", c.ReadLine, isNotEmpty)
+	}
+	if id == "" {
+		return
+	}
+
+	if !f.yesNoQuestion("Are you sure you want to cancel scheduled sending of message " + bold(id)) {
+		return
+	}
+
+	if err := user.CancelScheduledSend(id); err != nil {
+		f.printAndLogError("Cannot cancel scheduled sending: ", err)
+		return
+	}
+
+	f.Println("Scheduled sending of message", id, "was canceled. The message stays in Drafts.")
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/abiosoft/ishell"
+)
+
+func (f *frontendCLI) previewSending(c *ishell.Context) {
+	composeMode := pmapi.ContentTypeHTML
+	args := []string{}
+	for _, arg := range c.Args {
+		if arg == "--plain" {
+			composeMode = pmapi.ContentTypePlainText
+			continue
+		}
+		args = append(args, arg)
+	}
+
+	if len(args) < 2 {
+		f.Println("Please specify sender address and at least one recipient.")
+		return
+	}
+
+	previews, err := f.previewer.PreviewSending(args[0], args[1:], composeMode)
+	if err != nil {
+		f.printAndLogError("Cannot preview sending: ", err)
+		return
+	}
+
+	spacing := "%-30s %-10s %-16s %-10s %-8s %s\n"
+	f.Printf(bold(spacing), "recipient", "scheme", "MIME type", "key", "encrypt", "signature")
+	for _, preview := range previews {
+		if preview.Err != nil {
+			f.Printf("%-30s error: %v\n", preview.Recipient, preview.Err)
+			continue
+		}
+
+		f.Printf(spacing,
+			preview.Recipient,
+			getSchemeName(preview.Scheme),
+			preview.MIMEType,
+			preview.KeySource,
+			yesNo(preview.Encrypt),
+			yesNo(preview.Sign),
+		)
+	}
+}
+
+func getSchemeName(scheme int) string {
+	switch scheme {
+	case pmapi.InternalPackage:
+		return "internal"
+	case pmapi.EncryptedOutsidePackage:
+		return "eo"
+	case pmapi.ClearPackage:
+		return "clear"
+	case pmapi.PGPInlinePackage:
+		return "pgp-inline"
+	case pmapi.PGPMIMEPackage:
+		return "pgp-mime"
+	case pmapi.ClearMIMEPackage:
+		return "clear-mime"
+	default:
+		return "unknown"
+	}
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
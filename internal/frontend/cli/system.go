@@ -18,7 +18,6 @@
 package cli
 
 import (
-	"fmt"
 	"strconv"
 	"strings"
 
@@ -73,59 +72,70 @@ func (f *frontendCLI) deleteCache(c *ishell.Context) {
 	f.Stop()
 }
 
-func (f *frontendCLI) changeSMTPSecurity(c *ishell.Context) {
+func (f *frontendCLI) changeSMTPListeners(c *ishell.Context) {
 	f.ShowPrompt(false)
 	defer f.ShowPrompt(true)
 
-	isSSL := f.preferences.GetBool(preferences.SMTPSSLKey)
-	newSecurity := "SSL"
-	if isSSL {
-		newSecurity = "STARTTLS"
-	}
+	startTLS := f.yesNoQuestion("Enable SMTP with STARTTLS on port " + f.preferences.Get(preferences.SMTPPortKey))
+	ssl := f.yesNoQuestion("Enable SMTP with SSL on port " + f.preferences.Get(preferences.SMTPSSLPortKey))
 
-	msg := fmt.Sprintf("Are you sure you want to change SMTP setting to %q and restart the Bridge", newSecurity)
+	if !startTLS && !ssl {
+		f.Println("At least one SMTP listener must be enabled!")
+		return
+	}
 
-	if f.yesNoQuestion(msg) {
-		f.preferences.SetBool(preferences.SMTPSSLKey, !isSSL)
-		f.Println("Restarting Bridge...")
-		f.appRestart = true
-		f.Stop()
+	if startTLS == f.preferences.GetBool(preferences.SMTPStartTLSEnabledKey) &&
+		ssl == f.preferences.GetBool(preferences.SMTPSSLEnabledKey) {
+		f.Println("Nothing changed")
+		return
 	}
+
+	f.preferences.SetBool(preferences.SMTPStartTLSEnabledKey, startTLS)
+	f.preferences.SetBool(preferences.SMTPSSLEnabledKey, ssl)
+	f.Println("Restarting Bridge...")
+	f.appRestart = true
+	f.Stop()
 }
 
 func (f *frontendCLI) changePort(c *ishell.Context) {
 	f.ShowPrompt(false)
 	defer f.ShowPrompt(true)
 
-	currentPort = f.preferences.Get(preferences.IMAPPortKey)
-	newIMAPPort := f.readStringInAttempts("Set IMAP port (current "+currentPort+")", c.ReadLine, f.isPortFree)
-	if newIMAPPort == "" {
-		newIMAPPort = currentPort
-	}
-	imapPortChanged := newIMAPPort != currentPort
+	keys := []string{preferences.IMAPPortKey, preferences.SMTPPortKey, preferences.SMTPSSLPortKey}
+	names := []string{"IMAP", "SMTP STARTTLS", "SMTP SSL"}
 
-	currentPort = f.preferences.Get(preferences.SMTPPortKey)
-	newSMTPPort := f.readStringInAttempts("Set SMTP port (current "+currentPort+")", c.ReadLine, f.isPortFree)
-	if newSMTPPort == "" {
-		newSMTPPort = currentPort
+	newPorts := map[string]string{}
+	changed := false
+	for i, key := range keys {
+		currentPort = f.preferences.Get(key)
+		newPort := f.readStringInAttempts("Set "+names[i]+" port (current "+currentPort+")", c.ReadLine, f.isPortFree)
+		if newPort == "" {
+			newPort = currentPort
+		}
+
+		for otherKey, otherPort := range newPorts {
+			if otherPort == newPort {
+				f.Println(names[i], "port must be different from", otherKey, "port!")
+				return
+			}
+		}
+
+		newPorts[names[i]] = newPort
+		changed = changed || newPort != currentPort
 	}
-	smtpPortChanged := newSMTPPort != currentPort
 
-	if newIMAPPort == newSMTPPort {
-		f.Println("SMTP and IMAP ports must be different!")
+	if !changed {
+		f.Println("Nothing changed")
 		return
 	}
 
-	if imapPortChanged || smtpPortChanged {
-		f.Println("Saving values IMAP:", newIMAPPort, "SMTP:", newSMTPPort)
-		f.preferences.Set(preferences.IMAPPortKey, newIMAPPort)
-		f.preferences.Set(preferences.SMTPPortKey, newSMTPPort)
-		f.Println("Restarting Bridge...")
-		f.appRestart = true
-		f.Stop()
-	} else {
-		f.Println("Nothing changed")
+	f.Println("Saving values IMAP:", newPorts["IMAP"], "SMTP STARTTLS:", newPorts["SMTP STARTTLS"], "SMTP SSL:", newPorts["SMTP SSL"])
+	for i, key := range keys {
+		f.preferences.Set(key, newPorts[names[i]])
 	}
+	f.Println("Restarting Bridge...")
+	f.appRestart = true
+	f.Stop()
 }
 
 func (f *frontendCLI) toggleAllowProxy(c *ishell.Context) {
@@ -98,9 +98,9 @@ func (f *frontendCLI) notifyNeedUpgrade() {
 
 func (f *frontendCLI) notifyCredentialsError() {
 	// Print in 80-column width.
-	f.Println("ProtonMail Bridge is not able to detect a supported password manager")
-	f.Println("(pass, gnome-keyring). Please install and set up a supported password manager")
-	f.Println("and restart the application.")
+	f.Println("ProtonMail Bridge is not able to access a supported password manager")
+	f.Println("(pass, gnome-keyring). Accounts are locked until it is installed, set up or")
+	f.Println("unlocked. Bridge keeps trying, there is no need to restart the application.")
 }
 
 func (f *frontendCLI) notifyCertIssue() {
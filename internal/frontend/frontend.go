@@ -60,10 +60,10 @@ func New(
 	eventListener listener.Listener,
 	updates types.Updater,
 	bridge *bridge.Bridge,
-	noEncConfirmator types.NoEncConfirmator,
+	smtpBackend types.SMTPBackend,
 ) Frontend {
 	bridgeWrap := types.NewBridgeWrap(bridge)
-	return new(version, buildVersion, frontendType, showWindowOnStart, panicHandler, config, preferences, eventListener, updates, bridgeWrap, noEncConfirmator)
+	return new(version, buildVersion, frontendType, showWindowOnStart, panicHandler, config, preferences, eventListener, updates, bridgeWrap, smtpBackend)
 }
 
 func new(
@@ -77,12 +77,12 @@ func new(
 	eventListener listener.Listener,
 	updates types.Updater,
 	bridge types.Bridger,
-	noEncConfirmator types.NoEncConfirmator,
+	smtpBackend types.SMTPBackend,
 ) Frontend {
 	switch frontendType {
 	case "cli":
-		return cli.New(panicHandler, config, preferences, eventListener, updates, bridge)
+		return cli.New(panicHandler, config, preferences, eventListener, updates, bridge, smtpBackend)
 	default:
-		return qt.New(version, buildVersion, showWindowOnStart, panicHandler, config, preferences, eventListener, updates, bridge, noEncConfirmator)
+		return qt.New(version, buildVersion, showWindowOnStart, panicHandler, config, preferences, eventListener, updates, bridge, smtpBackend)
 	}
 }
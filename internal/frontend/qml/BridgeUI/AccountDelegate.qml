@@ -425,6 +425,35 @@ Column {
                     dialogAddUser.inputPassword.focusInput = true
                 }
             }
+        },
+        State {
+            name: "locked"
+            PropertyChanges {
+                target : addressList
+                model  : 0
+            }
+            PropertyChanges {
+                target : toggleIcon
+                color  : Style.main.textDisabled
+            }
+            PropertyChanges {
+                target : accountName
+                color  : Style.main.textDisabled
+            }
+            PropertyChanges {
+                target    : statusMark
+                textColor : Style.main.textDisabled
+                text      : qsTr("locked", "status of a listed account waiting for the keychain to be available")
+                iconText  : Style.fa.lock
+            }
+            PropertyChanges {
+                target  : logoutAccount
+                visible : false
+            }
+            PropertyChanges {
+                target  : deleteAccount
+                visible : false
+            }
         }
     ]
 }
@@ -31,7 +31,7 @@ Dialog {
 
     Column {
         id: dialogMessage
-        property int heightInputs : imapPort.height + middleSep.height + smtpPort.height + buttonSep.height + buttonRow.height + secSMTPSep.height + securitySMTP.height
+        property int heightInputs : imapPort.height + middleSep.height + smtpPort.height + smtpSSLSep.height + smtpSSLPort.height + buttonSep.height + buttonRow.height + secSMTPSep.height + securitySMTP.height
 
         Rectangle { color : "transparent"; width : Style.main.dummy; height : (root.height-dialogMessage.heightInputs)/1.6 }
 
@@ -47,13 +47,22 @@ Dialog {
         InputField {
             id: smtpPort
             iconText : Style.fa.hashtag
-            label    : qsTr("SMTP port", "entry field to choose port used for the SMTP server")
+            label    : qsTr("SMTP port (STARTTLS)", "entry field to choose port used for the SMTP server with STARTTLS")
+            text     : "undef"
+        }
+
+        Rectangle { id:smtpSSLSep; color : "transparent"; width : Style.main.dummy; height : Style.dialog.heightSeparator }
+
+        InputField {
+            id: smtpSSLPort
+            iconText : Style.fa.hashtag
+            label    : qsTr("SMTP port (SSL)", "entry field to choose port used for the SMTP server with SSL")
             text     : "undef"
         }
 
         Rectangle { id:secSMTPSep; color : Style.transparent; width : Style.main.dummy; height : Style.dialog.heightSeparator }
 
-        // SSL button group
+        // SMTP listeners run independently; at least one has to be enabled.
         Rectangle {
             anchors.horizontalCenter : parent.horizontalCenter
             width : Style.dialog.widthInput
@@ -63,17 +72,14 @@ Dialog {
             AccessibleText {
                 id: securitySMTPLabel
                 anchors.left : parent.left
-                text:qsTr("SMTP connection mode")
+                text:qsTr("Enabled SMTP connection modes")
                 color: Style.dialog.text
                 font {
                     pointSize : Style.dialog.fontSize * Style.pt
                     bold      : true
                 }
             }
-            
-            QC.ButtonGroup {
-                buttons: securitySMTP.children
-            }
+
             Row {
                 id: securitySMTP
                 spacing: Style.dialog.spacing
@@ -81,14 +87,15 @@ Dialog {
                 anchors.topMargin: Style.dialog.fontSize
 
                 CheckBoxLabel {
-                    id: securitySMTPSSL
-                    text: qsTr("SSL")
+                    checked: true
+                    id: securitySMTPSTARTTLS
+                    text: qsTr("STARTTLS")
                 }
 
                 CheckBoxLabel {
                     checked: true
-                    id: securitySMTPSTARTTLS
-                    text: qsTr("STARTTLS")
+                    id: securitySMTPSSL
+                    text: qsTr("SSL")
                 }
             }
         }
@@ -129,7 +136,7 @@ Dialog {
                 pointSize : Style.dialog.fontSize * Style.pt
                 bold      : true
             }
-            text : "IMAP: " + imapPort.text + "\nSMTP: " + smtpPort.text + "\nSMTP Connection Mode: " + getSelectedSSLMode() + "\n\n" +
+            text : "IMAP: " + imapPort.text + "\nSMTP (STARTTLS): " + getListenerState(securitySMTPSTARTTLS, smtpPort) + "\nSMTP (SSL): " + getListenerState(securitySMTPSSL, smtpSSLPort) + "\n\n" +
             qsTr("Settings will be applied after the next start. You will need to reconfigure your email client(s).", "after user changes their ports they will see this notification to reconfigure their setup") +
             "\n\n" +
             qsTr("Bridge will now restart.", "after user changes their ports this appears to notify the user of restart")
@@ -138,44 +145,49 @@ Dialog {
         }
     }
 
+    // checkPort marks the port input and returns whether the port can be used.
+    // Unchanged ports are used by bridge itself.
+    function checkPort(input, currentPort) {
+        if (input.text==currentPort) {
+            input.rightIcon = ""
+            return true
+        }
+        if (go.isPortOpen(input.text)!=0) {
+            input.rightIcon = Style.fa.exclamation_triangle
+            root.warning.text = qsTr("Port number is not available.", "if the user changes one of their ports to a port that is occupied by another application")
+            return false
+        }
+        input.rightIcon = Style.fa.check_circle
+        return true
+    }
+
     function areInputsOK() {
         var isOK = true
-        var imapUnchanged = false
-        var secSMTPUnchanged = (securitySMTPSTARTTLS.checked == go.isSMTPSTARTTLS())
         root.warning.text = ""
 
-        if (imapPort.text!=go.getIMAPPort()) {
-            if (go.isPortOpen(imapPort.text)!=0) {
-                imapPort.rightIcon = Style.fa.exclamation_triangle
-                root.warning.text = qsTr("Port number is not available.", "if the user changes one of their ports to a port that is occupied by another application")
-                isOK=false
-            } else {
-                imapPort.rightIcon = Style.fa.check_circle
-            }
-        } else {
-            imapPort.rightIcon = ""
-            imapUnchanged = true
+        isOK = checkPort(imapPort, go.getIMAPPort()) && isOK
+        isOK = checkPort(smtpPort, go.getSMTPPort()) && isOK
+        isOK = checkPort(smtpSSLPort, go.getSMTPSSLPort()) && isOK
+
+        var isUnchanged = (
+            imapPort.text == go.getIMAPPort() &&
+            smtpPort.text == go.getSMTPPort() &&
+            smtpSSLPort.text == go.getSMTPSSLPort() &&
+            securitySMTPSTARTTLS.checked == go.isSMTPSTARTTLSEnabled() &&
+            securitySMTPSSL.checked == go.isSMTPSSLEnabled()
+        )
+        if (isOK && isUnchanged) {
+            root.warning.text = qsTr("Please change at least one port number or SMTP security.", "if the user tries to change IMAP/SMTP ports to the same ports as before")
+            isOK=false
         }
 
-        if (smtpPort.text!=go.getSMTPPort()) {
-            if (go.isPortOpen(smtpPort.text)!=0) {
-                smtpPort.rightIcon = Style.fa.exclamation_triangle
-                root.warning.text = qsTr("Port number is not available.", "if the user changes one of their ports to a port that is occupied by another application")
-                isOK=false
-            } else {
-                smtpPort.rightIcon = Style.fa.check_circle
-            }
-        } else {
-            smtpPort.rightIcon = ""
-            if (imapUnchanged && secSMTPUnchanged) {
-                root.warning.text = qsTr("Please change at least one port number or SMTP security.", "if the user tries to change IMAP/SMTP ports to the same ports as before")
-                isOK=false
-            }
+        if (!securitySMTPSTARTTLS.checked && !securitySMTPSSL.checked) {
+            root.warning.text = qsTr("At least one SMTP connection mode must be enabled.", "if the user disables both SMTP with STARTTLS and SMTP with SSL")
+            isOK=false
         }
 
-        if (imapPort.text == smtpPort.text) {
-            smtpPort.rightIcon = Style.fa.exclamation_triangle
-            root.warning.text = qsTr("Port numbers must be different.", "if the user sets both the IMAP and SMTP ports to the same number")
+        if (imapPort.text == smtpPort.text || imapPort.text == smtpSSLPort.text || smtpPort.text == smtpSSLPort.text) {
+            root.warning.text = qsTr("Port numbers must be different.", "if the user sets two of the IMAP and SMTP ports to the same number")
             isOK=false
         }
 
@@ -190,22 +202,19 @@ Dialog {
         }
     }
 
-    function getSelectedSSLMode() {
-        if (securitySMTPSTARTTLS.checked == true) {
-            return "STARTTLS"
-        } else {
-            return "SSL"
+    function getListenerState(enabled, port) {
+        if (enabled.checked) {
+            return port.text
         }
+        return qsTr("disabled", "SMTP connection mode which is not used")
     }
 
     onShow : {
         imapPort.text = go.getIMAPPort()
         smtpPort.text = go.getSMTPPort()
-        if (go.isSMTPSTARTTLS()) {
-            securitySMTPSTARTTLS.checked = true
-        } else {
-            securitySMTPSSL.checked = true
-        }
+        smtpSSLPort.text = go.getSMTPSSLPort()
+        securitySMTPSTARTTLS.checked = go.isSMTPSTARTTLSEnabled()
+        securitySMTPSSL.checked = go.isSMTPSSLEnabled()
         areInputsOK()
         root.warning.visible = false
     }
@@ -225,7 +234,7 @@ Dialog {
     Connections {
         target: timer
         onTriggered: {
-            go.setPortsAndSecurity(imapPort.text, smtpPort.text, securitySMTPSTARTTLS.checked)
+            go.setPorts(imapPort.text, smtpPort.text, smtpSSLPort.text, securitySMTPSTARTTLS.checked, securitySMTPSSL.checked)
             go.isRestarting = true
             Qt.quit()
         }
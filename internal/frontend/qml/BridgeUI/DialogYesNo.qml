@@ -298,8 +298,8 @@ Dialog {
                 target: root
                 currentIndex : 0
                 note     : qsTr(
-                    "%1 is not able to detected a supported password manager (pass, gnome-keyring). Please install and setup supported password manager and restart the application.",
-                    "Error message when no keychain is detected"
+                    "%1 is not able to access a supported password manager (pass, gnome-keyring). Accounts are locked until the password manager is installed, set up or unlocked. %1 keeps trying, there is no need to restart the application.",
+                    "Error message when no keychain is detected or it is locked"
                 ).arg(go.programTitle)
                 question         : qsTr("Do you want to close application now?", "when no password manager found." )
                 title        : "No system password manager detected"
@@ -48,6 +48,7 @@ Window {
         property int portSMTP : 0
     }
     property string address : "undef"
+    property string password : "undef"
     property int indexAccount : 0
     property int indexAddress : 0
 
@@ -88,7 +89,7 @@ Window {
             TextLabel { text: qsTr("Hostname", "in configuration screen, displays the server hostname (127.0.0.1)") + ":"} TextValue { text: root.accData.hostname }
             TextLabel { text: qsTr("Port", "in configuration screen, displays the server port (ex. 1025)") + ":"} TextValue { text: root.accData.portIMAP }
             TextLabel { text: qsTr("Username", "in configuration screen, displays the username to use with the desktop client") + ":"} TextValue { text: root.address          }
-            TextLabel { text: qsTr("Password", "in configuration screen, displays the Bridge password to use with the desktop client") + ":"} TextValue { text: root.accData.password }
+            TextLabel { text: qsTr("Password", "in configuration screen, displays the Bridge password to use with the desktop client") + ":"} TextValue { text: root.password }
             TextLabel { text: qsTr("Security", "in configuration screen, displays the IMAP security settings") + ":"} TextValue { text: "STARTTLS" }
         }
         Rectangle { width: Style.main.dummy; height: Style.main.fontSize; color: "#00000000"}
@@ -102,7 +103,7 @@ Window {
             TextLabel { text: qsTr("Hostname", "in configuration screen, displays the server hostname (127.0.0.1)") + ":"} TextValue { text: root.accData.hostname }
             TextLabel { text: qsTr("Port", "in configuration screen, displays the server port (ex. 1025)") + ":"} TextValue { text: root.accData.portSMTP }
             TextLabel { text: qsTr("Username", "in configuration screen, displays the username to use with the desktop client") + ":"} TextValue { text: root.address          }
-            TextLabel { text: qsTr("Password", "in configuration screen, displays the Bridge password to use with the desktop client") + ":"} TextValue { text: root.accData.password }
+            TextLabel { text: qsTr("Password", "in configuration screen, displays the Bridge password to use with the desktop client") + ":"} TextValue { text: root.password }
             TextLabel { text: qsTr("Security", "in configuration screen, displays the SMTP security settings") + ":"} TextValue { text: go.isSMTPSTARTTLS() ? "STARTTLS" : "SSL" }
         }
         Rectangle { width: Style.main.dummy; height: Style.main.fontSize; color: "#00000000"}
@@ -133,6 +134,7 @@ Window {
         root.indexAddress = iAddress
         root.accData = accountsModel.get(iAccount)
         root.address =  accData.aliases.split(";")[iAddress]
+        root.password = accData.password.split(";")[iAddress]
         root.show()
         root.raise()
         root.requestActivate()
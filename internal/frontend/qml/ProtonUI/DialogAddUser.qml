@@ -38,6 +38,7 @@ Dialog {
     isDialogBusy : currentIndex==waitingAuthIndex || currentIndex==addingAccIndex
 
     property bool isFirstAccount: false
+    property bool useSecurityKey: false
 
     property color buttonOpaqueMain : "white"
 
@@ -146,7 +147,9 @@ Dialog {
 
         InputField {
             id: input2FAuth
-            label      : qsTr("Two Factor Code", "two factor code entry field")
+            label      : root.useSecurityKey ?
+            qsTr("Two Factor Code (leave empty to use security key)", "two factor code entry field when security key can be used") :
+            qsTr("Two Factor Code", "two factor code entry field")
             iconText   : Style.fa.lock
             onAccepted : root.okay()
         }
@@ -332,7 +335,7 @@ Dialog {
             isOK &= inputPassword.checkNonEmpty()
             break
             case twoFAIndex :
-            isOK &= input2FAuth.checkNonEmpty()
+            if (!root.useSecurityKey) isOK &= input2FAuth.checkNonEmpty()
             break
             case mailboxIndex :
             isOK &= inputPasswMailbox.checkNonEmpty()
@@ -406,7 +409,8 @@ Dialog {
                     startAgain()
                     break
                 }
-                if (auth == 1) {
+                root.useSecurityKey = auth == 3
+                if (auth == 1 || auth == 3) {
                     root.currentIndex = twoFAIndex
                     root.input2FAuth.focusInput = true
                     break
@@ -228,9 +228,9 @@ Window {
 
         ListModel{
             id: accountsModel
-            ListElement{ account : "bridge"                                           ; status : "connected";    isExpanded: false; isCombinedAddressMode: false; hostname : "127.0.0.1"; password : "ZI9tKp+ryaxmbpn2E12"; security : "StarTLS"; portSMTP : 1025; portIMAP : 1143; aliases : "bridge@pm.com;bridge2@pm.com;theHorriblySlowMurderWithExtremelyInefficientWeapon@youtube.com" }
+            ListElement{ account : "bridge"                                           ; status : "connected";    isExpanded: false; isCombinedAddressMode: false; hostname : "127.0.0.1"; password : "ZI9tKp+ryaxmbpn2E12;Gv7xQb2kLm0ZyW1pTa4;Rk8sNc5uHd3JwE9vXq6"; security : "StarTLS"; portSMTP : 1025; portIMAP : 1143; aliases : "bridge@pm.com;bridge2@pm.com;theHorriblySlowMurderWithExtremelyInefficientWeapon@youtube.com" }
             ListElement{ account : "exteremelongnamewhichmustbeeladed@protonmail.com" ; status : "connected";    isExpanded: true;  isCombinedAddressMode: true;  hostname : "127.0.0.1"; password : "ZI9tKp+ryaxmbpn2E12"; security : "StarTLS"; portSMTP : 1025; portIMAP : 1143; aliases : "bridge@pm.com;bridge2@pm.com;hu@hu.hu"                                                        }
-            ListElement{ account : "bridge2@protonmail.com"                           ; status : "disconnected"; isExpanded: false; isCombinedAddressMode: false; hostname : "127.0.0.1"; password : "ZI9tKp+ryaxmbpn2E12"; security : "StarTLS"; portSMTP : 1025; portIMAP : 1143; aliases : "bridge@pm.com;bridge2@pm.com;hu@hu.hu"                                                        }
+            ListElement{ account : "bridge2@protonmail.com"                           ; status : "disconnected"; isExpanded: false; isCombinedAddressMode: false; hostname : "127.0.0.1"; password : "ZI9tKp+ryaxmbpn2E12;Gv7xQb2kLm0ZyW1pTa4;Rk8sNc5uHd3JwE9vXq6"; security : "StarTLS"; portSMTP : 1025; portIMAP : 1143; aliases : "bridge@pm.com;bridge2@pm.com;hu@hu.hu"                                                        }
         }
 
         Component.onCompleted : {
@@ -355,6 +355,9 @@ Window {
             if (username=="2fa") {
                 return 1
             }
+            if (username=="fido2") {
+                return 3
+            }
             if (username=="mbox") {
                 return 2
             }
@@ -554,6 +557,9 @@ Window {
         function getSMTPPort() {
             return 1025
         }
+        function getSMTPSSLPort() {
+            return 1026
+        }
 
         function isPortOpen(portstring){
             if (isNaN(portstring)) {
@@ -567,14 +573,22 @@ Window {
         }
 
         property bool isRestarting: false
-        function setPortsAndSecurity(portIMAP, portSMTP, secSMTP) {
-            console.log("Test: ports changed", portIMAP, portSMTP, secSMTP)
+        function setPorts(portIMAP, portSMTP, portSMTPSSL, enabledSMTP, enabledSMTPSSL) {
+            console.log("Test: ports changed", portIMAP, portSMTP, portSMTPSSL, enabledSMTP, enabledSMTPSSL)
         }
 
         function isSMTPSTARTTLS() {
             return true
         }
 
+        function isSMTPSTARTTLSEnabled() {
+            return true
+        }
+
+        function isSMTPSSLEnabled() {
+            return true
+        }
+
         signal openManual()
 
         function clearCache() {
@@ -26,6 +26,7 @@ import (
 	"github.com/ProtonMail/proton-bridge/internal/bridge"
 	"github.com/ProtonMail/proton-bridge/internal/events"
 	"github.com/ProtonMail/proton-bridge/internal/preferences"
+	"github.com/ProtonMail/proton-bridge/pkg/fido2"
 	"github.com/ProtonMail/proton-bridge/pkg/keychain"
 	pmapi "github.com/ProtonMail/proton-bridge/pkg/pmapi"
 )
@@ -38,9 +39,10 @@ func (s *FrontendQt) loadAccounts() {
 	s.Accounts.Clear()
 
 	users := s.bridge.GetUsers()
+	lockedAccounts := s.bridge.GetLockedAccounts()
 
 	// If there are no active accounts.
-	if len(users) == 0 {
+	if len(users) == 0 && len(lockedAccounts) == 0 {
 		log.Info("No active accounts")
 		return
 	}
@@ -62,9 +64,15 @@ func (s *FrontendQt) loadAccounts() {
 		// Set login info.
 		acc_info.SetUserID(user.ID())
 		acc_info.SetHostname(bridge.Host)
-		acc_info.SetPassword(user.GetBridgePassword())
+		// Passwords are in the same order as addresses; in split mode each one has its own.
+		var passwords []string
+		for _, address := range user.GetAddresses() {
+			passwords = append(passwords, user.GetAddressBridgePassword(address))
+		}
+		acc_info.SetPassword(strings.Join(passwords, ";"))
 		acc_info.SetPortIMAP(s.preferences.GetInt(preferences.IMAPPortKey))
-		acc_info.SetPortSMTP(s.preferences.GetInt(preferences.SMTPPortKey))
+		smtpPort, _ := s.getSMTPListener()
+		acc_info.SetPortSMTP(smtpPort)
 
 		// Set aliases.
 		acc_info.SetAliases(strings.Join(user.GetAddresses(), ";"))
@@ -74,6 +82,14 @@ func (s *FrontendQt) loadAccounts() {
 		s.Accounts.addAccount(acc_info)
 	}
 
+	// Accounts waiting for the keychain are shown without any details.
+	for _, name := range lockedAccounts {
+		acc_info := NewAccountInfo(nil)
+		acc_info.SetAccount(name)
+		acc_info.SetStatus("locked")
+		s.Accounts.addAccount(acc_info)
+	}
+
 	// Updated can clear.
 	s.userIDAdded = ""
 }
@@ -140,6 +156,7 @@ func (s *FrontendQt) showLoginError(err error, scope string) bool {
 //  0: when no 2FA and no MBOX
 //  1: when has 2FA
 //  2: when has no 2FA but have MBOX
+//  3: when has 2FA and security key can be used
 func (s *FrontendQt) login(login, password string) int {
 	var err error
 	s.authClient, s.auth, err = s.bridge.Login(login, password)
@@ -147,6 +164,13 @@ func (s *FrontendQt) login(login, password string) int {
 		return -1
 	}
 	if s.auth.HasTwoFactor() {
+		authenticator, err := fido2.ForAuth(s.auth)
+		if s.showLoginError(err, "login") {
+			return -1
+		}
+		if authenticator != nil {
+			return 3
+		}
 		return 1
 	}
 	if s.auth.HasMailboxPassword() {
@@ -155,7 +179,8 @@ func (s *FrontendQt) login(login, password string) int {
 	return 0 // No 2FA, no mailbox password.
 }
 
-// auth2FA returns:
+// auth2FA uses the security key when the code is empty and the account has one.
+// It returns:
 //  -1 : error (use SetAddAccountWarning to show message)
 //   0 : single password mode
 //   1 : two password mode
@@ -163,6 +188,8 @@ func (s *FrontendQt) auth2FA(twoFacAuth string) int {
 	var err error
 	if s.auth == nil || s.authClient == nil {
 		err = fmt.Errorf("missing authentication in auth2FA %p %p", s.auth, s.authClient)
+	} else if twoFacAuth == "" && s.auth.HasFIDO2() {
+		err = s.auth2FAFIDO2()
 	} else {
 		_, err = s.authClient.Auth2FA(twoFacAuth, s.auth)
 	}
@@ -177,6 +204,18 @@ func (s *FrontendQt) auth2FA(twoFacAuth string) int {
 	return 0 // One password.
 }
 
+func (s *FrontendQt) auth2FAFIDO2() error {
+	authenticator, err := fido2.ForAuth(s.auth)
+	if err == nil && authenticator == nil {
+		err = fido2.ErrNoAuthenticator
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.authClient.Auth2FAFIDO2(authenticator, s.auth)
+	return err
+}
+
 // addAccount adds an account. It should close login modal ProcessFinished if ok.
 func (s *FrontendQt) addAccount(mailboxPassword string) int {
 	if s.auth == nil || s.authClient == nil {
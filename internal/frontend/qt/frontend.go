@@ -163,7 +163,7 @@ func (s *FrontendQt) InstanceExistAlert() {
 //
 // It runs QtExecute in main thread with no additional function.
 func (s *FrontendQt) Loop(credentialsError error) (err error) {
-	if credentialsError != nil {
+	if credentialsError != nil || s.bridge.GetKeychainError() != nil {
 		s.notifyHasNoKeychain = true
 	}
 	go func() {
@@ -188,6 +188,8 @@ func (s *FrontendQt) watchEvents() {
 	updateApplicationCh := s.getEventChannel(events.UpgradeApplicationEvent)
 	newUserCh := s.getEventChannel(events.UserRefreshEvent)
 	certIssue := s.getEventChannel(events.TLSCertIssue)
+	keychainLockedCh := s.getEventChannel(events.KeychainLockedEvent)
+	keychainUnlockedCh := s.getEventChannel(events.KeychainUnlockedEvent)
 	for {
 		select {
 		case errorDetails := <-errorCh:
@@ -227,6 +229,10 @@ func (s *FrontendQt) watchEvents() {
 			s.Qml.LoadAccounts()
 		case <-certIssue:
 			s.Qml.ShowCertIssue()
+		case <-keychainLockedCh:
+			s.Qml.LoadAccounts()
+		case <-keychainUnlockedCh:
+			s.Qml.LoadAccounts()
 		}
 	}
 }
@@ -372,7 +378,8 @@ func (s *FrontendQt) qtExecute(Procedure func(*FrontendQt) error) error {
 	// IMAP/SMTP ports.
 	s.Qml.SetIsDefaultPort(
 		s.config.GetDefaultIMAPPort() == s.preferences.GetInt(preferences.IMAPPortKey) &&
-			s.config.GetDefaultSMTPPort() == s.preferences.GetInt(preferences.SMTPPortKey),
+			s.config.GetDefaultSMTPPort() == s.preferences.GetInt(preferences.SMTPPortKey) &&
+			s.config.GetDefaultSMTPSSLPort() == s.preferences.GetInt(preferences.SMTPSSLPortKey),
 	)
 
 	// Check QML is loaded properly.
@@ -476,16 +483,17 @@ func (s *FrontendQt) configureAppleMail(iAccount, iAddress int) {
 
 	imapPort := s.preferences.GetInt(preferences.IMAPPortKey)
 	imapSSL := false
-	smtpPort := s.preferences.GetInt(preferences.SMTPPortKey)
-	smtpSSL := s.preferences.GetBool(preferences.SMTPSSLKey)
+	smtpPort, smtpSSL := s.getSMTPListener()
 
 	// If configuring apple mail for Catalina or newer, users should use SSL.
 	doRestart := false
 	if !smtpSSL && useragent.IsCatalinaOrNewer() {
-		smtpSSL = true
-		s.preferences.SetBool(preferences.SMTPSSLKey, true)
-		log.Warn("Detected Catalina or newer with bad SMTP SSL settings, now using SSL, bridge needs to restart")
-		doRestart = true
+		smtpPort, smtpSSL = s.preferences.GetInt(preferences.SMTPSSLPortKey), true
+		if !s.preferences.GetBool(preferences.SMTPSSLEnabledKey) {
+			s.preferences.SetBool(preferences.SMTPSSLEnabledKey, true)
+			log.Warn("Detected Catalina or newer with SMTP SSL disabled, now using SSL, bridge needs to restart")
+			doRestart = true
+		}
 	}
 
 	for _, autoConf := range autoconfig.Available() {
@@ -545,6 +553,27 @@ func (s *FrontendQt) getSMTPPort() string {
 	return s.preferences.Get(preferences.SMTPPortKey)
 }
 
+func (s *FrontendQt) getSMTPSSLPort() string {
+	return s.preferences.Get(preferences.SMTPSSLPortKey)
+}
+
+func (s *FrontendQt) isSMTPSTARTTLSEnabled() bool {
+	return s.preferences.GetBool(preferences.SMTPStartTLSEnabledKey)
+}
+
+func (s *FrontendQt) isSMTPSSLEnabled() bool {
+	return s.preferences.GetBool(preferences.SMTPSSLEnabledKey)
+}
+
+// getSMTPListener returns the SMTP listener shown in GUI. Both listeners can
+// run at once; GUI shows the one using STARTTLS unless it is disabled.
+func (s *FrontendQt) getSMTPListener() (port int, useSSL bool) {
+	if s.preferences.GetBool(preferences.SMTPStartTLSEnabledKey) {
+		return s.preferences.GetInt(preferences.SMTPPortKey), false
+	}
+	return s.preferences.GetInt(preferences.SMTPSSLPortKey), true
+}
+
 // Return 0 -- port is free to use for server.
 // Return 1 -- port is occupied.
 func (s *FrontendQt) isPortOpen(portStr string) int {
@@ -558,14 +587,19 @@ func (s *FrontendQt) isPortOpen(portStr string) int {
 	return 0
 }
 
-func (s *FrontendQt) setPortsAndSecurity(imapPort, smtpPort string, useSTARTTLSforSMTP bool) {
+// setPorts configures the IMAP port and both SMTP listeners. Each SMTP
+// listener has its own port and is enabled independently of the other one.
+func (s *FrontendQt) setPorts(imapPort, smtpPort, smtpSSLPort string, smtpEnabled, smtpSSLEnabled bool) {
 	s.preferences.Set(preferences.IMAPPortKey, imapPort)
 	s.preferences.Set(preferences.SMTPPortKey, smtpPort)
-	s.preferences.SetBool(preferences.SMTPSSLKey, !useSTARTTLSforSMTP)
+	s.preferences.Set(preferences.SMTPSSLPortKey, smtpSSLPort)
+	s.preferences.SetBool(preferences.SMTPStartTLSEnabledKey, smtpEnabled)
+	s.preferences.SetBool(preferences.SMTPSSLEnabledKey, smtpSSLEnabled)
 }
 
 func (s *FrontendQt) isSMTPSTARTTLS() bool {
-	return !s.preferences.GetBool(preferences.SMTPSSLKey)
+	_, useSSL := s.getSMTPListener()
+	return !useSSL
 }
 
 func (s *FrontendQt) checkInternet() {
@@ -91,15 +91,18 @@ type GoQMLInterface struct {
 	_ func() `slot:"errorSystray"`
 	_ func() `slot:"normalSystray"`
 
-	_ func()                                                   `slot:"getLocalVersionInfo"`
-	_ func(showMessage bool)                                   `slot:"isNewVersionAvailable"`
-	_ func() string                                            `slot:"getBackendVersion"`
-	_ func() string                                            `slot:"getIMAPPort"`
-	_ func() string                                            `slot:"getSMTPPort"`
-	_ func() string                                            `slot:"getLastMailClient"`
-	_ func(portStr string) int                                 `slot:"isPortOpen"`
-	_ func(imapPort, smtpPort string, useSTARTTLSforSMTP bool) `slot:"setPortsAndSecurity"`
-	_ func() bool                                              `slot:"isSMTPSTARTTLS"`
+	_ func()                                                                         `slot:"getLocalVersionInfo"`
+	_ func(showMessage bool)                                                         `slot:"isNewVersionAvailable"`
+	_ func() string                                                                  `slot:"getBackendVersion"`
+	_ func() string                                                                  `slot:"getIMAPPort"`
+	_ func() string                                                                  `slot:"getSMTPPort"`
+	_ func() string                                                                  `slot:"getSMTPSSLPort"`
+	_ func() string                                                                  `slot:"getLastMailClient"`
+	_ func(portStr string) int                                                       `slot:"isPortOpen"`
+	_ func(imapPort, smtpPort, smtpSSLPort string, smtpEnabled, smtpSSLEnabled bool) `slot:"setPorts"`
+	_ func() bool                                                                    `slot:"isSMTPSTARTTLS"`
+	_ func() bool                                                                    `slot:"isSMTPSTARTTLSEnabled"`
+	_ func() bool                                                                    `slot:"isSMTPSSLEnabled"`
 
 	_ func(description, client, address string) bool `slot:"sendBug"`
 
@@ -156,9 +159,12 @@ func (s *GoQMLInterface) SetFrontend(f *FrontendQt) {
 	s.ConnectIsNewVersionAvailable(f.isNewVersionAvailable)
 	s.ConnectGetIMAPPort(f.getIMAPPort)
 	s.ConnectGetSMTPPort(f.getSMTPPort)
+	s.ConnectGetSMTPSSLPort(f.getSMTPSSLPort)
 	s.ConnectGetLastMailClient(f.getLastMailClient)
 	s.ConnectIsPortOpen(f.isPortOpen)
 	s.ConnectIsSMTPSTARTTLS(f.isSMTPSTARTTLS)
+	s.ConnectIsSMTPSTARTTLSEnabled(f.isSMTPSTARTTLSEnabled)
+	s.ConnectIsSMTPSSLEnabled(f.isSMTPSSLEnabled)
 
 	s.ConnectSendBug(f.sendBug)
 
@@ -168,7 +174,7 @@ func (s *GoQMLInterface) SetFrontend(f *FrontendQt) {
 	s.ConnectLogin(f.login)
 	s.ConnectAuth2FA(f.auth2FA)
 	s.ConnectAddAccount(f.addAccount)
-	s.ConnectSetPortsAndSecurity(f.setPortsAndSecurity)
+	s.ConnectSetPorts(f.setPorts)
 
 	s.ConnectHighlightSystray(HighlightSystray)
 	s.ConnectErrorSystray(ErrorSystray)
@@ -19,7 +19,12 @@
 package types
 
 import (
+	"io"
+
 	"github.com/ProtonMail/proton-bridge/internal/bridge"
+	"github.com/ProtonMail/proton-bridge/internal/smtp"
+	"github.com/ProtonMail/proton-bridge/internal/store"
+	"github.com/ProtonMail/proton-bridge/internal/users/credentials"
 	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
 	"github.com/ProtonMail/proton-bridge/pkg/updates"
 )
@@ -41,15 +46,30 @@ type NoEncConfirmator interface {
 	ConfirmNoEncryption(string, bool)
 }
 
+// SendingPreviewer computes how a message would be sent without sending it.
+type SendingPreviewer interface {
+	PreviewSending(from string, to []string, composeMode string) ([]*smtp.RecipientPreview, error)
+}
+
+// SMTPBackend is an interface of SMTP backend needed by frontend.
+type SMTPBackend interface {
+	NoEncConfirmator
+	SendingPreviewer
+}
+
 // Bridger is an interface of bridge needed by frontend.
 type Bridger interface {
 	GetCurrentClient() string
 	SetCurrentOS(os string)
 	Login(username, password string) (pmapi.Client, *pmapi.Auth, error)
+	LoginWithHost(username, password string, host pmapi.HostSettings) (pmapi.Client, *pmapi.Auth, error)
 	FinishLogin(client pmapi.Client, auth *pmapi.Auth, mailboxPassword string) (BridgeUser, error)
+	FinishLoginWithStore(client pmapi.Client, auth *pmapi.Auth, mailboxPassword string, archive io.Reader) (BridgeUser, error)
 	GetUsers() []BridgeUser
 	GetUser(query string) (BridgeUser, error)
 	DeleteUser(userID string, clearCache bool) error
+	GetKeychainError() error
+	GetLockedAccounts() []string
 	ReportBug(osType, osVersion, description, accountName, address, emailClient string) error
 	ClearData() error
 	AllowProxy()
@@ -66,7 +86,24 @@ type BridgeUser interface {
 	GetPrimaryAddress() string
 	GetAddresses() []string
 	GetBridgePassword() string
+	GetAddressBridgePassword(address string) string
+	RotateBridgePassword(address string) error
+	ListAppPasswords() []credentials.AppPassword
+	AddAppPassword(name string) (string, error)
+	RevokeAppPassword(name string) error
+	GetHostSettings() pmapi.HostSettings
+	HasAutoReauth() bool
+	EnableAutoReauth(loginPassword, totpSecret string) error
+	DisableAutoReauth() error
 	SwitchAddressMode() error
+	ExportStore(w io.Writer) error
+	ListOutgoing() ([]*store.OutgoingMessage, error)
+	CancelOutgoing(id string) error
+	ListScheduledSends() ([]*store.ScheduledSend, error)
+	CancelScheduledSend(messageID string) error
+	GetSenderAliases() (map[string]string, error)
+	SetSenderAlias(alias, address string) error
+	DeleteSenderAlias(alias string) error
 	Logout() error
 }
 
@@ -85,6 +122,10 @@ func (b *bridgeWrap) FinishLogin(client pmapi.Client, auth *pmapi.Auth, mailboxP
 	return b.Bridge.FinishLogin(client, auth, mailboxPassword)
 }
 
+func (b *bridgeWrap) FinishLoginWithStore(client pmapi.Client, auth *pmapi.Auth, mailboxPassword string, archive io.Reader) (BridgeUser, error) {
+	return b.Bridge.FinishLoginWithStore(client, auth, mailboxPassword, archive)
+}
+
 func (b *bridgeWrap) GetUsers() (users []BridgeUser) {
 	for _, user := range b.Bridge.GetUsers() {
 		users = append(users, user)
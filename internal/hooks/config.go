@@ -0,0 +1,194 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package hooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// hook is one hook of the hooks file, for example:
+//
+//	{"hooks": [
+//	  {"name": "notify", "events": ["newMessage"], "exec": ["/usr/local/bin/notify-mail", "--json"]},
+//	  {"name": "monitor", "events": ["logout", "sendFailed"], "url": "http://127.0.0.1:8080/bridge"}
+//	]}
+//
+// Hook runs either the executable with arguments or POSTs to the URL, which
+// must point to the local machine. Empty list of events means all events.
+type hook struct {
+	Name    string   `json:"name"`
+	Events  []string `json:"events"`
+	Exec    []string `json:"exec"`
+	URL     string   `json:"url"`
+	Timeout int      `json:"timeout"` // In seconds.
+}
+
+type hookList []hook
+
+type hooksFile struct {
+	Hooks hookList `json:"hooks"`
+}
+
+// hooksConfig provides hooks from the hooks file. The file is optional
+// and is read again whenever it changes, so there is no need to restart.
+type hooksConfig struct {
+	path string
+
+	lock    sync.Mutex
+	modTime time.Time
+	hooks   hookList
+}
+
+func newHooksConfig(path string) *hooksConfig {
+	return &hooksConfig{path: path}
+}
+
+// getHooks returns current hooks. Invalid hooks file is logged and no hooks
+// are run until it is fixed.
+func (c *hooksConfig) getHooks() hookList {
+	if c.path == "" {
+		return nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	info, err := os.Stat(c.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.WithError(err).WithField("path", c.path).Error("Cannot read hooks file")
+		}
+		c.modTime, c.hooks = time.Time{}, nil
+		return nil
+	}
+
+	if !info.ModTime().Equal(c.modTime) {
+		c.modTime = info.ModTime()
+		if c.hooks, err = loadHooks(c.path); err != nil {
+			log.WithError(err).WithField("path", c.path).Error("Hooks file is invalid, no hooks will be run")
+		} else {
+			log.WithField("path", c.path).WithField("hooks", len(c.hooks)).Info("Hooks loaded")
+		}
+	}
+
+	return c.hooks
+}
+
+func loadHooks(path string) (hookList, error) {
+	raw, err := ioutil.ReadFile(path) //nolint[gosec]
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read hooks file")
+	}
+
+	file := hooksFile{}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, errors.Wrap(err, "cannot parse hooks file")
+	}
+
+	for i, hook := range file.Hooks {
+		if err := hook.validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid hook #%d", i+1)
+		}
+	}
+
+	return file.Hooks, nil
+}
+
+func (h *hook) validate() error {
+	if (len(h.Exec) == 0) == (h.URL == "") {
+		return errors.New("exactly one of exec and url must be set")
+	}
+
+	if h.URL != "" {
+		if err := validateLocalURL(h.URL); err != nil {
+			return err
+		}
+	}
+
+	for _, eventName := range h.Events {
+		if !isSupportedEvent(eventName) {
+			return errors.New("unsupported event " + eventName)
+		}
+	}
+
+	if h.Timeout < 0 {
+		return errors.New("negative timeout")
+	}
+
+	return nil
+}
+
+// validateLocalURL allows only http(s) URLs on the loopback interface so that
+// the payload with user's data does not leave the machine.
+func validateLocalURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return errors.Wrap(err, "invalid url")
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("url scheme must be http or https")
+	}
+
+	if u.Hostname() == "localhost" {
+		return nil
+	}
+
+	if ip := net.ParseIP(u.Hostname()); ip == nil || !ip.IsLoopback() {
+		return errors.New("url must point to localhost")
+	}
+
+	return nil
+}
+
+func (h *hook) getName() string {
+	if h.Name != "" {
+		return h.Name
+	}
+	if h.URL != "" {
+		return h.URL
+	}
+	return h.Exec[0]
+}
+
+func (h *hook) getTimeout() time.Duration {
+	if h.Timeout == 0 {
+		return defaultTimeout
+	}
+	return time.Duration(h.Timeout) * time.Second
+}
+
+func (h *hook) matches(eventName string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, name := range h.Events {
+		if name == eventName {
+			return true
+		}
+	}
+	return false
+}
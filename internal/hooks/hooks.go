@@ -0,0 +1,202 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package hooks runs executables or POSTs to local URLs when bridge events
+// (such as new message or logout) are emitted.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/proton-bridge/internal/events"
+	"github.com/ProtonMail/proton-bridge/pkg/listener"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logrus.WithField("pkg", "hooks") //nolint[gochecknoglobals]
+
+const defaultTimeout = 30 * time.Second
+
+// supportedEvents can be used in the hooks file.
+var supportedEvents = []string{ //nolint[gochecknoglobals]
+	events.NewMessageEvent,
+	events.SyncFinishedEvent,
+	events.SendFailedEvent,
+	events.LogoutEvent,
+	events.AddressChangedEvent,
+	events.AddressChangedLogoutEvent,
+	events.InternetOffEvent,
+	events.InternetOnEvent,
+	events.KeychainLockedEvent,
+	events.KeychainUnlockedEvent,
+}
+
+func isSupportedEvent(eventName string) bool {
+	for _, name := range supportedEvents {
+		if name == eventName {
+			return true
+		}
+	}
+	return false
+}
+
+type panicHandler interface {
+	HandlePanic()
+}
+
+// Payload is sent as JSON to the hook: on the standard input of the executable
+// or as body of the POST request. Data is JSON object for events with
+// structured data (e.g. newMessage) or string (e.g. user ID for logout).
+type Payload struct {
+	Event string          `json:"event"`
+	Time  time.Time       `json:"time"`
+	Data  json.RawMessage `json:"data"`
+}
+
+func newPayload(eventName, data string) Payload {
+	payload := Payload{Event: eventName, Time: time.Now()}
+
+	if strings.HasPrefix(data, "{") && json.Valid([]byte(data)) {
+		payload.Data = json.RawMessage(data)
+	} else {
+		payload.Data, _ = json.Marshal(data)
+	}
+
+	return payload
+}
+
+// Hooks runs hooks from the hooks file for events emitted by the listener.
+type Hooks struct {
+	panicHandler  panicHandler
+	eventListener listener.Listener
+	config        *hooksConfig
+	httpClient    *http.Client
+}
+
+// New returns hooks configured by the file at `path`. The file does not
+// need to exist; it is read again whenever it changes.
+func New(panicHandler panicHandler, eventListener listener.Listener, path string) *Hooks {
+	return &Hooks{
+		panicHandler:  panicHandler,
+		eventListener: eventListener,
+		config:        newHooksConfig(path),
+		httpClient: &http.Client{
+			// Redirects could send the payload to a host which is not local.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Start subscribes to all supported events.
+func (h *Hooks) Start() {
+	for _, eventName := range supportedEvents {
+		ch := make(chan string)
+		h.eventListener.Add(eventName, ch)
+		go h.watch(eventName, ch)
+	}
+}
+
+func (h *Hooks) watch(eventName string, ch <-chan string) {
+	defer h.panicHandler.HandlePanic()
+
+	for data := range ch {
+		h.run(eventName, data)
+	}
+}
+
+// run runs all hooks for the event in parallel.
+func (h *Hooks) run(eventName, data string) {
+	var body []byte
+
+	for _, item := range h.config.getHooks() {
+		if !item.matches(eventName) {
+			continue
+		}
+
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(newPayload(eventName, data)); err != nil {
+				log.WithError(err).WithField("event", eventName).Error("Cannot encode hook payload")
+				return
+			}
+		}
+
+		go func(hook hook) {
+			defer h.panicHandler.HandlePanic()
+
+			l := log.WithField("hook", hook.getName()).WithField("event", eventName)
+			if err := h.runHook(hook, eventName, body); err != nil {
+				l.WithError(err).Warn("Hook failed")
+				return
+			}
+			l.Debug("Hook finished")
+		}(item)
+	}
+}
+
+func (h *Hooks) runHook(hook hook, eventName string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hook.getTimeout())
+	defer cancel()
+
+	if hook.URL != "" {
+		return h.postHook(ctx, hook.URL, body)
+	}
+	return execHook(ctx, hook.Exec, eventName, body)
+}
+
+func (h *Hooks) postHook(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := h.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close() //nolint[errcheck]
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	return nil
+}
+
+func execHook(ctx context.Context, command []string, eventName string, body []byte) error {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...) //nolint[gosec]
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(), "BRIDGE_EVENT="+eventName)
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrap(err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
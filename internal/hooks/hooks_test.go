@@ -0,0 +1,165 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/proton-bridge/internal/events"
+	"github.com/ProtonMail/proton-bridge/pkg/listener"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPanicHandler struct{}
+
+func (testPanicHandler) HandlePanic() {}
+
+func TestHookValidate(t *testing.T) {
+	testCases := []struct {
+		hook    hook
+		wantErr bool
+	}{
+		{hook: hook{Exec: []string{"/bin/true"}}},
+		{hook: hook{URL: "http://127.0.0.1:8080/hook", Events: []string{events.LogoutEvent}}},
+		{hook: hook{URL: "https://localhost/hook"}},
+		{hook: hook{URL: "http://[::1]/hook"}},
+		{hook: hook{}, wantErr: true},
+		{hook: hook{Exec: []string{"/bin/true"}, URL: "http://127.0.0.1/hook"}, wantErr: true},
+		{hook: hook{URL: "http://example.com/hook"}, wantErr: true},
+		{hook: hook{URL: "ftp://127.0.0.1/hook"}, wantErr: true},
+		{hook: hook{Exec: []string{"/bin/true"}, Events: []string{events.ErrorEvent}}, wantErr: true},
+		{hook: hook{Exec: []string{"/bin/true"}, Timeout: -1}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		err := tc.hook.validate()
+		if tc.wantErr {
+			assert.Error(t, err, "%+v", tc.hook)
+		} else {
+			assert.NoError(t, err, "%+v", tc.hook)
+		}
+	}
+}
+
+func TestNewPayload(t *testing.T) {
+	data := events.MessageEventData{UserID: "user", MessageID: "msg"}.String()
+
+	raw, err := json.Marshal(newPayload(events.NewMessageEvent, data))
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `"event":"newMessage"`)
+	assert.Contains(t, string(raw), `"data":{"userID":"user","messageID":"msg"}`)
+
+	raw, err = json.Marshal(newPayload(events.LogoutEvent, "user"))
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `"data":"user"`)
+}
+
+func TestHooksPostAndExec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hooks-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint[errcheck]
+
+	posted := make(chan Payload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := Payload{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		posted <- payload
+	}))
+	defer server.Close()
+
+	outPath := filepath.Join(dir, "out.json")
+	writeHooksFile(t, filepath.Join(dir, "hooks.json"), hooksFile{Hooks: hookList{
+		{Name: "post", Events: []string{events.LogoutEvent}, URL: server.URL},
+		{Name: "exec", Events: []string{events.SyncFinishedEvent}, Exec: []string{"sh", "-c", `cat > "$0"; echo "$BRIDGE_EVENT" >> "$0"`, outPath}},
+	}})
+
+	eventListener := listener.New()
+	New(testPanicHandler{}, eventListener, filepath.Join(dir, "hooks.json")).Start()
+
+	eventListener.Emit(events.LogoutEvent, "user")
+	select {
+	case payload := <-posted:
+		assert.Equal(t, events.LogoutEvent, payload.Event)
+		assert.Equal(t, `"user"`, string(payload.Data))
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "hook was not posted")
+	}
+
+	eventListener.Emit(events.SyncFinishedEvent, "user")
+	require.Eventually(t, func() bool {
+		out, err := ioutil.ReadFile(outPath) //nolint[gosec]
+		return err == nil && len(out) > 0 && out[len(out)-1] == '\n'
+	}, 5*time.Second, 10*time.Millisecond)
+
+	out, err := ioutil.ReadFile(outPath) //nolint[gosec]
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"event":"syncFinished"`)
+	assert.Contains(t, string(out), "}syncFinished\n")
+}
+
+func TestPostHookDoesNotFollowRedirects(t *testing.T) {
+	redirected := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer target.Close()
+
+	server := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer server.Close()
+
+	hooks := New(testPanicHandler{}, listener.New(), "")
+
+	err := hooks.postHook(context.Background(), server.URL, []byte("{}"))
+	assert.EqualError(t, err, "unexpected status 307 Temporary Redirect")
+	assert.False(t, redirected)
+}
+
+func TestHooksFileIsReloaded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hooks-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint[errcheck]
+
+	path := filepath.Join(dir, "hooks.json")
+	config := newHooksConfig(path)
+	assert.Empty(t, config.getHooks())
+
+	writeHooksFile(t, path, hooksFile{Hooks: hookList{{Exec: []string{"/bin/true"}}}})
+	assert.Len(t, config.getHooks(), 1)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"hooks": [{}]}`), 0600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+	assert.Empty(t, config.getHooks())
+
+	require.NoError(t, os.Remove(path))
+	assert.Empty(t, config.getHooks())
+}
+
+func writeHooksFile(t *testing.T, path string, file hooksFile) {
+	raw, err := json.Marshal(file)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, raw, 0600))
+}
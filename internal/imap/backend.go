@@ -161,7 +161,8 @@ func (ib *imapBackend) Login(_ *imap.ConnInfo, username, password string) (goIMA
 		return nil, err
 	}
 
-	if err := imapUser.user.CheckBridgeLogin(password); err != nil {
+	appPassword, err := imapUser.user.CheckBridgeLogin(username, password)
+	if err != nil {
 		log.WithError(err).Error("Could not check bridge password")
 		_ = imapUser.Logout()
 		// Apple Mail sometimes generates a lot of requests very quickly.
@@ -176,7 +177,7 @@ func (ib *imapBackend) Login(_ *imap.ConnInfo, username, password string) (goIMA
 	// (otherwise the store will be locked for 1 sec per email during synchronization).
 	imapUser.user.SetIMAPIdleUpdateChannel()
 
-	return imapUser, nil
+	return &imapSession{imapUser: imapUser, appPassword: appPassword}, nil
 }
 
 // Updates returns a channel of updates for IMAP IDLE extension.
@@ -36,7 +36,7 @@ type bridger interface {
 
 type bridgeUser interface {
 	ID() string
-	CheckBridgeLogin(password string) error
+	CheckBridgeLogin(address, password string) (string, error)
 	IsCombinedAddressMode() bool
 	GetAddressID(address string) (string, error)
 	GetPrimaryAddress() string
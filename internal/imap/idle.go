@@ -0,0 +1,57 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package imap
+
+import (
+	imapidle "github.com/emersion/go-imap-idle"
+	imapserver "github.com/emersion/go-imap/server"
+)
+
+// idleExtension wraps the standard IDLE extension to let the store know
+// when a client is waiting for updates so it can poll events faster.
+type idleExtension struct {
+	imapserver.Extension
+}
+
+func newIdleExtension() imapserver.Extension {
+	return &idleExtension{Extension: imapidle.NewExtension()}
+}
+
+func (ext *idleExtension) Command(name string) imapserver.HandlerFactory {
+	newHandler := ext.Extension.Command(name)
+	if newHandler == nil {
+		return nil
+	}
+
+	return func() imapserver.Handler {
+		return &idleHandler{Handler: newHandler()}
+	}
+}
+
+type idleHandler struct {
+	imapserver.Handler
+}
+
+func (h *idleHandler) Handle(conn imapserver.Conn) error {
+	if user, ok := conn.Context().User.(*imapSession); ok {
+		user.storeUser.NotifyIdleStarted()
+		defer user.storeUser.NotifyIdleFinished()
+	}
+
+	return h.Handler.Handle(conn)
+}
@@ -19,10 +19,8 @@ package imap
 
 import (
 	"bytes"
-	"encoding/base64"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"mime/multipart"
 	"net/mail"
 	"net/textproto"
@@ -39,7 +37,6 @@ import (
 	"github.com/ProtonMail/proton-bridge/pkg/parallel"
 	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
 	"github.com/emersion/go-imap"
-	"github.com/emersion/go-textwrapper"
 	"github.com/hashicorp/go-multierror"
 	enmime "github.com/jhillyerd/enmime"
 	"github.com/pkg/errors"
@@ -184,75 +181,9 @@ func (im *imapMailbox) CreateMessage(flags []string, date time.Time, body imap.L
 	return uidplus.AppendResponse(im.storeMailbox.UIDValidity(), targetSeq)
 }
 
-func (im *imapMailbox) importMessage(m *pmapi.Message, readers []io.Reader, kr *crypto.KeyRing) (err error) { // nolint[funlen]
-	b := &bytes.Buffer{}
-
-	// Overwrite content for main header for import.
-	// Even if message has just simple body we should upload as multipart/mixed.
-	// Each part has encrypted body and header reflects the original header.
-	mainHeader := message.GetHeader(m)
-	mainHeader.Set("Content-Type", "multipart/mixed; boundary="+message.GetBoundary(m))
-	mainHeader.Del("Content-Disposition")
-	mainHeader.Del("Content-Transfer-Encoding")
-	if err = writeHeader(b, mainHeader); err != nil {
-		return
-	}
-	mw := multipart.NewWriter(b)
-	if err = mw.SetBoundary(message.GetBoundary(m)); err != nil {
-		return
-	}
-
-	// Write the body part.
-	bodyHeader := make(textproto.MIMEHeader)
-	bodyHeader.Set("Content-Type", m.MIMEType+"; charset=utf-8")
-	bodyHeader.Set("Content-Disposition", "inline")
-	bodyHeader.Set("Content-Transfer-Encoding", "7bit")
-
-	var p io.Writer
-	if p, err = mw.CreatePart(bodyHeader); err != nil {
-		return
-	}
-	// First, encrypt the message body.
-	if err = m.Encrypt(kr, kr); err != nil {
-		return err
-	}
-	if _, err := io.WriteString(p, m.Body); err != nil {
-		return err
-	}
-
-	// Write the attachments parts.
-	for i := 0; i < len(m.Attachments); i++ {
-		att := m.Attachments[i]
-		r := readers[i]
-		h := message.GetAttachmentHeader(att)
-		if p, err = mw.CreatePart(h); err != nil {
-			return
-		}
-		// Create line wrapper writer.
-		ww := textwrapper.NewRFC822(p)
-
-		// Create base64 writer.
-		bw := base64.NewEncoder(base64.StdEncoding, ww)
-
-		data, err := ioutil.ReadAll(r)
-		if err != nil {
-			return err
-		}
-
-		// Create encrypted writer.
-		pgpMessage, err := kr.Encrypt(crypto.NewPlainMessage(data), nil)
-		if err != nil {
-			return err
-		}
-		if _, err := bw.Write(pgpMessage.GetBinary()); err != nil {
-			return err
-		}
-		if err := bw.Close(); err != nil {
-			return err
-		}
-	}
-
-	if err := mw.Close(); err != nil {
+func (im *imapMailbox) importMessage(m *pmapi.Message, readers []io.Reader, kr *crypto.KeyRing) error {
+	body, err := message.BuildEncrypted(m, readers, kr)
+	if err != nil {
 		return err
 	}
 
@@ -263,7 +194,7 @@ func (im *imapMailbox) importMessage(m *pmapi.Message, readers []io.Reader, kr *
 		}
 	}
 
-	return im.storeMailbox.ImportMessage(m, b.Bytes(), labels)
+	return im.storeMailbox.ImportMessage(m, body, labels)
 }
 
 func (im *imapMailbox) getMessage(storeMessage storeMessageProvider, items []imap.FetchItem) (msg *imap.Message, err error) {
@@ -32,7 +32,6 @@ import (
 	"github.com/ProtonMail/proton-bridge/pkg/listener"
 	"github.com/emersion/go-imap"
 	imapappendlimit "github.com/emersion/go-imap-appendlimit"
-	imapidle "github.com/emersion/go-imap-idle"
 	imapmove "github.com/emersion/go-imap-move"
 	imapquota "github.com/emersion/go-imap-quota"
 	imapspecialuse "github.com/emersion/go-imap-specialuse"
@@ -101,7 +100,7 @@ func NewIMAPServer(debugClient, debugServer bool, port int, tls *tls.Config, ima
 	})
 
 	s.Enable(
-		imapidle.NewExtension(),
+		newIdleExtension(),
 		imapmove.NewExtension(),
 		imapspecialuse.NewExtension(),
 		imapid.NewExtension(serverID),
@@ -122,6 +121,8 @@ func NewIMAPServer(debugClient, debugServer bool, port int, tls *tls.Config, ima
 // Starts the server.
 func (s *imapServer) ListenAndServe() {
 	go s.monitorDisconnectedUsers()
+	go s.monitorRevokedAppPasswords()
+	go s.monitorRotatedBridgePasswords()
 
 	log.Info("IMAP server listening at ", s.server.Addr)
 	l, err := net.Listen("tcp", s.server.Addr)
@@ -167,6 +168,50 @@ func (s *imapServer) monitorDisconnectedUsers() {
 	}
 }
 
+// monitorRevokedAppPasswords closes connections which logged in with
+// a revoked app password.
+func (s *imapServer) monitorRevokedAppPasswords() {
+	ch := make(chan string)
+	s.eventListener.Add(events.RevokedAppPasswordEvent, ch)
+
+	for data := range ch {
+		appPassword, err := events.ParseAppPasswordEventData(data)
+		if err != nil {
+			log.WithError(err).Error("Cannot parse revoked app password")
+			continue
+		}
+
+		log.Info("Disconnecting IMAP connections of app password ", appPassword.Name)
+		s.server.ForEachConn(func(conn imapserver.Conn) {
+			if session, ok := conn.Context().User.(*imapSession); ok && session.isLoggedInWith(appPassword) {
+				_ = conn.Close()
+			}
+		})
+	}
+}
+
+// monitorRotatedBridgePasswords closes connections which logged in with
+// a rotated bridge password.
+func (s *imapServer) monitorRotatedBridgePasswords() {
+	ch := make(chan string)
+	s.eventListener.Add(events.RotatedBridgePasswordEvent, ch)
+
+	for data := range ch {
+		rotated, err := events.ParseBridgePasswordEventData(data)
+		if err != nil {
+			log.WithError(err).Error("Cannot parse rotated bridge password")
+			continue
+		}
+
+		log.WithField("address", rotated.Address).Info("Disconnecting IMAP connections of rotated bridge password")
+		s.server.ForEachConn(func(conn imapserver.Conn) {
+			if session, ok := conn.Context().User.(*imapSession); ok && session.usedBridgePassword(rotated) {
+				_ = conn.Close()
+			}
+		})
+	}
+}
+
 // debugListener sets debug loggers on server containing fields with local
 // and remote addresses right after new connection is accepted.
 type debugListener struct {
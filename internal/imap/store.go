@@ -32,6 +32,9 @@ type storeUserProvider interface {
 	GetSpace() (usedSpace, maxSpace uint, err error)
 	GetMaxUpload() (uint, error)
 
+	NotifyIdleStarted()
+	NotifyIdleFinished()
+
 	GetAddress(addressID string) (storeAddressProvider, error)
 
 	CreateDraft(
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"strings"
 
+	"github.com/ProtonMail/proton-bridge/internal/events"
 	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
 	imapquota "github.com/emersion/go-imap-quota"
 	goIMAPBackend "github.com/emersion/go-imap/backend"
@@ -41,6 +42,29 @@ type imapUser struct {
 	currentAddressLowercase string
 }
 
+// imapSession is the user of one connection. IMAP users are shared by
+// connections of the same address; the session also knows which app password
+// was used to log in, if any.
+type imapSession struct {
+	*imapUser
+
+	appPassword string
+}
+
+// isLoggedInWith returns whether the session logged in with the app password.
+func (is *imapSession) isLoggedInWith(appPassword events.AppPasswordEventData) bool {
+	return is.appPassword != "" && is.appPassword == appPassword.Name && is.user.ID() == appPassword.UserID
+}
+
+// usedBridgePassword returns whether the session logged in with the rotated
+// bridge password.
+func (is *imapSession) usedBridgePassword(rotated events.BridgePasswordEventData) bool {
+	if is.appPassword != "" || is.user.ID() != rotated.UserID {
+		return false
+	}
+	return rotated.Address == "" || strings.EqualFold(is.Username(), rotated.Address)
+}
+
 // This method should eventually no longer be necessary. Everything should go via store.
 func (iu *imapUser) client() pmapi.Client {
 	return iu.user.GetTemporaryPMAPIClient()
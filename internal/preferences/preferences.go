@@ -33,11 +33,18 @@ const (
 	APIPortKey             = "user_port_api"
 	IMAPPortKey            = "user_port_imap"
 	SMTPPortKey            = "user_port_smtp"
-	SMTPSSLKey             = "user_ssl_smtp"
+	SMTPSSLPortKey         = "user_port_smtp_ssl"
+	SMTPStartTLSEnabledKey = "user_smtp_starttls_enabled"
+	SMTPSSLEnabledKey      = "user_smtp_ssl_enabled"
 	AllowProxyKey          = "allow_proxy"
 	AutostartKey           = "autostart"
 	ReportOutgoingNoEncKey = "report_outgoing_email_without_encryption"
 	LastVersionKey         = "last_used_version"
+	KnownAccountsKey       = "known_accounts"
+
+	// smtpSSLKey was used when there was only one SMTP listener. It is read
+	// only to migrate old preferences to SMTPSSLPortKey.
+	smtpSSLKey = "user_ssl_smtp"
 )
 
 type configProvider interface {
@@ -45,6 +52,7 @@ type configProvider interface {
 	GetDefaultAPIPort() int
 	GetDefaultIMAPPort() int
 	GetDefaultSMTPPort() int
+	GetDefaultSMTPSSLPort() int
 }
 
 var (
@@ -68,11 +76,34 @@ func setDefaults(preferences *config.Preferences, cfg configProvider) {
 	preferences.SetDefault(APIPortKey, strconv.Itoa(cfg.GetDefaultAPIPort()))
 	preferences.SetDefault(IMAPPortKey, strconv.Itoa(cfg.GetDefaultIMAPPort()))
 	preferences.SetDefault(SMTPPortKey, strconv.Itoa(cfg.GetDefaultSMTPPort()))
+	preferences.SetDefault(SMTPSSLPortKey, strconv.Itoa(cfg.GetDefaultSMTPSSLPort()))
+	preferences.SetDefault(SMTPStartTLSEnabledKey, "true")
+	preferences.SetDefault(SMTPSSLEnabledKey, "true")
 	preferences.SetDefault(AllowProxyKey, "true")
 	preferences.SetDefault(AutostartKey, "true")
 	preferences.SetDefault(ReportOutgoingNoEncKey, "false")
 	preferences.SetDefault(LastVersionKey, "")
 
-	// By default, stick to STARTTLS. If the user uses catalina+applemail they'll have to change to SSL.
-	preferences.SetDefault(SMTPSSLKey, "false")
+	migrateSMTPSSL(preferences, cfg)
+}
+
+// migrateSMTPSSL moves the port of the only SMTP listener to the SSL listener
+// when it used SSL, so clients configured before keep working. The STARTTLS
+// listener then uses the default port, or the default SSL port if taken.
+func migrateSMTPSSL(preferences *config.Preferences, cfg configProvider) {
+	if !preferences.GetBool(smtpSSLKey) {
+		return
+	}
+
+	port := preferences.GetInt(SMTPPortKey)
+	preferences.SetInt(SMTPSSLPortKey, port)
+
+	if port == cfg.GetDefaultSMTPPort() {
+		preferences.SetInt(SMTPPortKey, cfg.GetDefaultSMTPSSLPort())
+	} else {
+		preferences.SetInt(SMTPPortKey, cfg.GetDefaultSMTPPort())
+	}
+
+	preferences.SetBool(smtpSSLKey, false)
+	log.WithField("port", port).Info("SMTP SSL port migrated")
 }
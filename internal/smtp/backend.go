@@ -32,6 +32,10 @@ type panicHandler interface {
 	HandlePanic()
 }
 
+type configProvider interface {
+	GetOutgoingPolicyPath() string
+}
+
 type smtpBackend struct {
 	panicHandler            panicHandler
 	eventListener           listener.Listener
@@ -39,16 +43,21 @@ type smtpBackend struct {
 	bridge                  bridger
 	shouldSendNoEncChannels map[string]chan bool
 	sendRecorder            *sendRecorder
+	outboxWakeCh            chan struct{}
+	policy                  *outgoingPolicy
 }
 
 // NewSMTPBackend returns struct implementing go-smtp/backend interface.
 func NewSMTPBackend(
 	panicHandler panicHandler,
 	eventListener listener.Listener,
+	cfg configProvider,
 	preferences *config.Preferences,
 	bridge *bridge.Bridge,
 ) *smtpBackend { //nolint[golint]
-	return newSMTPBackend(panicHandler, eventListener, preferences, newBridgeWrap(bridge))
+	sb := newSMTPBackend(panicHandler, eventListener, preferences, newBridgeWrap(bridge), newOutgoingPolicy(cfg.GetOutgoingPolicyPath()))
+	go sb.watchOutbox()
+	return sb
 }
 
 func newSMTPBackend(
@@ -56,6 +65,7 @@ func newSMTPBackend(
 	eventListener listener.Listener,
 	preferences *config.Preferences,
 	bridge bridger,
+	policy *outgoingPolicy,
 ) *smtpBackend {
 	return &smtpBackend{
 		panicHandler:            panicHandler,
@@ -64,6 +74,8 @@ func newSMTPBackend(
 		bridge:                  bridge,
 		shouldSendNoEncChannels: make(map[string]chan bool),
 		sendRecorder:            newSendRecorder(),
+		outboxWakeCh:            make(chan struct{}, 1),
+		policy:                  policy,
 	}
 }
 
@@ -78,7 +90,8 @@ func (sb *smtpBackend) Login(username, password string) (goSMTPBackend.User, err
 		log.Warn("Cannot get user: ", err)
 		return nil, err
 	}
-	if err := user.CheckBridgeLogin(password); err != nil {
+	appPassword, err := user.CheckBridgeLogin(username, password)
+	if err != nil {
 		log.WithError(err).Error("Could not check bridge password")
 		// Apple Mail sometimes generates a lot of requests very quickly. It's good practice
 		// to have a timeout after bad logins so that we can slow those requests down a little bit.
@@ -95,7 +108,7 @@ func (sb *smtpBackend) Login(username, password string) (goSMTPBackend.User, err
 	if user.IsCombinedAddressMode() {
 		addressID = ""
 	}
-	return newSMTPUser(sb.panicHandler, sb.eventListener, sb, user, addressID)
+	return newSMTPUser(sb.panicHandler, sb.eventListener, sb, user, addressID, appPassword)
 }
 
 func (sb *smtpBackend) shouldReportOutgoingNoEnc() bool {
@@ -25,10 +25,13 @@ import (
 
 type bridger interface {
 	GetUser(query string) (bridgeUser, error)
+	GetUsers() []bridgeUser
 }
 
 type bridgeUser interface {
-	CheckBridgeLogin(password string) error
+	ID() string
+	IsConnected() bool
+	CheckBridgeLogin(address, password string) (string, error)
 	IsCombinedAddressMode() bool
 	GetAddressID(address string) (string, error)
 	GetTemporaryPMAPIClient() pmapi.Client
@@ -54,6 +57,13 @@ func (b *bridgeWrap) GetUser(query string) (bridgeUser, error) {
 	return newBridgeUserWrap(user), nil
 }
 
+func (b *bridgeWrap) GetUsers() (users []bridgeUser) {
+	for _, user := range b.Bridge.GetUsers() {
+		users = append(users, newBridgeUserWrap(user))
+	}
+	return
+}
+
 type bridgeUserWrap struct {
 	*users.User
 }
@@ -63,5 +73,10 @@ func newBridgeUserWrap(bridgeUser *users.User) *bridgeUserWrap {
 }
 
 func (u *bridgeUserWrap) GetStore() storeUserProvider {
-	return u.User.GetStore()
+	store := u.User.GetStore()
+	// Return untyped nil so callers can check the store is not initialised.
+	if store == nil {
+		return nil
+	}
+	return store
 }
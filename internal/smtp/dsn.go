@@ -0,0 +1,257 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package smtp
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/proton-bridge/internal/bridge"
+	"github.com/ProtonMail/proton-bridge/pkg/message"
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/pkg/errors"
+)
+
+// Delivery status notifications (DSN, see RFC 3461 and RFC 3464) are
+// requested per recipient by the NOTIFY parameter of the RCPT command.
+// Bridge does not relay them to the API; instead it imports a report
+// into the inbox of the sender once the result of the sending is known.
+
+const dsnPostmaster = "Mail Delivery System <MAILER-DAEMON@localhost>"
+
+// dsnNotify holds the NOTIFY parameter of one recipient.
+type dsnNotify struct {
+	set     bool
+	success bool
+	failure bool
+}
+
+// wantsFailure returns whether the failure should be reported. When the NOTIFY
+// parameter is not used, failures are reported as a regular MTA would do.
+func (n dsnNotify) wantsFailure() bool {
+	return !n.set || n.failure
+}
+
+func parseNotify(value string) (notify dsnNotify, err error) {
+	notify.set = true
+	never := false
+
+	for _, keyword := range strings.Split(strings.ToUpper(value), ",") {
+		switch keyword {
+		case "NEVER":
+			never = true
+		case "SUCCESS":
+			notify.success = true
+		case "FAILURE":
+			notify.failure = true
+		case "DELAY":
+			// Messages are never relayed, so there is no delay to report.
+		default:
+			return dsnNotify{}, errors.New("backend: invalid NOTIFY parameter " + value)
+		}
+	}
+
+	if never && (notify.success || notify.failure) {
+		return dsnNotify{}, errors.New("backend: NOTIFY=NEVER cannot be combined with other values")
+	}
+
+	return notify, nil
+}
+
+// parseRecipients returns the lists of recipients which should be notified
+// about successful and failed delivery. `params` are the ESMTP parameters
+// of the RCPT command of each recipient in `to`.
+func parseRecipients(to []string, params []map[string]string) (notifySuccess, notifyFailure []string, err error) {
+	for i, address := range to {
+		notify := dsnNotify{}
+		if i < len(params) {
+			if value, ok := params[i]["NOTIFY"]; ok {
+				if notify, err = parseNotify(value); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+
+		if notify.success {
+			notifySuccess = append(notifySuccess, address)
+		}
+		if notify.wantsFailure() {
+			notifyFailure = append(notifyFailure, address)
+		}
+	}
+	return notifySuccess, notifyFailure, nil
+}
+
+// deliveryReport is a multipart/report message with delivery status of
+// a message sent by the user.
+type deliveryReport struct {
+	sender         string
+	subject        string
+	originalHeader []byte
+	recipients     []string
+	sendErr        error
+	arrival        time.Time
+}
+
+func (r *deliveryReport) build(now time.Time) ([]byte, error) { //nolint[funlen]
+	b := &bytes.Buffer{}
+	mw := multipart.NewWriter(b)
+
+	result, action, status := "Success", "delivered", "2.0.0"
+	if r.sendErr != nil {
+		result, action, status = "Failure", "failed", "5.0.0"
+	}
+
+	fmt.Fprintf(b, "From: %s\r\n", dsnPostmaster)
+	fmt.Fprintf(b, "To: <%s>\r\n", r.sender)
+	fmt.Fprintf(b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Delivery Status Notification ("+result+"): "+r.subject))
+	fmt.Fprintf(b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(b, "Message-Id: <dsn.%x@localhost>\r\n", now.UnixNano())
+	fmt.Fprintf(b, "Auto-Submitted: auto-replied\r\n")
+	fmt.Fprintf(b, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(b, "Content-Type: multipart/report; report-type=delivery-status; boundary=%s\r\n\r\n", mw.Boundary())
+
+	textHeader := textproto.MIMEHeader{}
+	textHeader.Set("Content-Type", "text/plain; charset=utf-8")
+	p, err := mw.CreatePart(textHeader)
+	if err != nil {
+		return nil, err
+	}
+	if r.sendErr == nil {
+		fmt.Fprintf(p, "Your message was delivered to the following recipients:\r\n\r\n")
+	} else {
+		fmt.Fprintf(p, "Your message could not be delivered to the following recipients:\r\n\r\n")
+	}
+	for _, recipient := range r.recipients {
+		fmt.Fprintf(p, "    %s\r\n", recipient)
+	}
+	if r.sendErr != nil {
+		fmt.Fprintf(p, "\r\nReason: %s\r\n", r.sendErr)
+	}
+
+	statusHeader := textproto.MIMEHeader{}
+	statusHeader.Set("Content-Type", "message/delivery-status")
+	if p, err = mw.CreatePart(statusHeader); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(p, "Reporting-MTA: dns; %s\r\n", bridge.Host)
+	fmt.Fprintf(p, "Arrival-Date: %s\r\n", r.arrival.Format(time.RFC1123Z))
+	for _, recipient := range r.recipients {
+		fmt.Fprintf(p, "\r\nFinal-Recipient: rfc822; %s\r\n", recipient)
+		fmt.Fprintf(p, "Action: %s\r\n", action)
+		fmt.Fprintf(p, "Status: %s\r\n", status)
+		if r.sendErr != nil {
+			fmt.Fprintf(p, "Diagnostic-Code: smtp; %s\r\n", strings.ReplaceAll(r.sendErr.Error(), "\n", " "))
+		}
+	}
+
+	if len(r.originalHeader) > 0 {
+		headersHeader := textproto.MIMEHeader{}
+		headersHeader.Set("Content-Type", "text/rfc822-headers")
+		if p, err = mw.CreatePart(headersHeader); err != nil {
+			return nil, err
+		}
+		if _, err = p.Write(r.originalHeader); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// importDeliveryReport imports the report to the inbox of the address `addressID`.
+func importDeliveryReport(client pmapi.Client, addressID string, report *deliveryReport) error {
+	addr := client.Addresses().ByID(addressID)
+	if addr == nil {
+		return errors.New("address of the report not found")
+	}
+	report.sender = addr.Email
+
+	kr, err := client.KeyRingForAddressID(addressID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get address keyring")
+	}
+
+	raw, err := report.build(time.Now())
+	if err != nil {
+		return errors.Wrap(err, "failed to build report")
+	}
+
+	m, _, _, readers, err := message.Parse(bytes.NewReader(raw), "", "")
+	if err != nil {
+		return errors.Wrap(err, "failed to parse report")
+	}
+	m.AddressID = addressID
+	m.Unread = 1
+	m.Flags = pmapi.FlagReceived
+	m.Time = time.Now().Unix()
+
+	body, err := message.BuildEncrypted(m, readers, kr)
+	if err != nil {
+		return errors.Wrap(err, "failed to encrypt report")
+	}
+
+	_, err = client.Import([]*pmapi.ImportMsgReq{{
+		AddressID: addressID,
+		Body:      body,
+		Unread:    m.Unread,
+		Flags:     m.Flags,
+		Time:      m.Time,
+		LabelIDs:  []string{pmapi.InboxLabel},
+	}})
+	return errors.Wrap(err, "failed to import report")
+}
+
+// reportDelivery imports a delivery status notification for `recipients`
+// if there are any. The import runs in background so it does not delay
+// the SMTP response. Errors are only logged because the message itself
+// was already handled.
+func (sb *smtpBackend) reportDelivery(client pmapi.Client, addressID string, report *deliveryReport) {
+	if len(report.recipients) == 0 {
+		return
+	}
+
+	go func() {
+		defer sb.panicHandler.HandlePanic()
+
+		if err := importDeliveryReport(client, addressID, report); err != nil {
+			log.WithError(err).Error("Cannot import delivery status notification")
+			return
+		}
+
+		log.WithField("recipients", report.recipients).Info("Delivery status notification imported")
+	}()
+}
+
+// getRawHeader returns the header section of the raw message.
+func getRawHeader(raw []byte) []byte {
+	for _, sep := range [][]byte{[]byte("\r\n\r\n"), []byte("\n\n")} {
+		if i := bytes.Index(raw, sep); i >= 0 {
+			return raw[:i+len(sep)/2]
+		}
+	}
+	return raw
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package smtp
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNotify(t *testing.T) {
+	testCases := []struct {
+		value      string
+		wantNotify dsnNotify
+		wantErr    bool
+	}{
+		{value: "SUCCESS,FAILURE", wantNotify: dsnNotify{set: true, success: true, failure: true}},
+		{value: "never", wantNotify: dsnNotify{set: true}},
+		{value: "DELAY", wantNotify: dsnNotify{set: true}},
+		{value: "NEVER,SUCCESS", wantErr: true},
+		{value: "SOMETIMES", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		notify, err := parseNotify(tc.value)
+		if tc.wantErr {
+			assert.Error(t, err, tc.value)
+			continue
+		}
+		require.NoError(t, err, tc.value)
+		assert.Equal(t, tc.wantNotify, notify, tc.value)
+	}
+}
+
+func TestParseRecipients(t *testing.T) {
+	notifySuccess, notifyFailure, err := parseRecipients(
+		[]string{"a@pm.me", "b@pm.me", "c@pm.me", "d@pm.me", "e@pm.me"},
+		[]map[string]string{
+			{},
+			{"NOTIFY": "SUCCESS"},
+			{"NOTIFY": "NEVER", "ORCPT": "rfc822;c@pm.me"},
+			{"NOTIFY": "SUCCESS,FAILURE"},
+		},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"b@pm.me", "d@pm.me"}, notifySuccess)
+	assert.Equal(t, []string{"a@pm.me", "d@pm.me", "e@pm.me"}, notifyFailure)
+}
+
+func TestParseRecipientsInvalidNotify(t *testing.T) {
+	_, _, err := parseRecipients([]string{"a@pm.me"}, []map[string]string{{"NOTIFY": "SOMETIMES"}})
+	require.Error(t, err)
+}
+
+func TestDeliveryReportBuild(t *testing.T) {
+	report := &deliveryReport{
+		sender:         "user@pm.me",
+		subject:        "Hello",
+		originalHeader: []byte("Subject: Hello\r\n"),
+		recipients:     []string{"a@pm.me", "b@pm.me"},
+		sendErr:        errors.New("recipient rejected"),
+		arrival:        time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC),
+	}
+
+	raw, err := report.build(time.Date(2020, 6, 1, 11, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	require.NoError(t, err)
+	assert.Equal(t, "<user@pm.me>", msg.Header.Get("To"))
+	assert.Equal(t, "Delivery Status Notification (Failure): Hello", msg.Header.Get("Subject"))
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/report", mediaType)
+	assert.Equal(t, "delivery-status", params["report-type"])
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var types []string
+	var status string
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		types = append(types, part.Header.Get("Content-Type"))
+		if part.Header.Get("Content-Type") == "message/delivery-status" {
+			body, err := ioutil.ReadAll(part)
+			require.NoError(t, err)
+			status = string(body)
+		}
+	}
+
+	assert.Equal(t, []string{"text/plain; charset=utf-8", "message/delivery-status", "text/rfc822-headers"}, types)
+	assert.Contains(t, status, "Final-Recipient: rfc822; a@pm.me\r\nAction: failed\r\nStatus: 5.0.0\r\n")
+	assert.Contains(t, status, "Final-Recipient: rfc822; b@pm.me\r\n")
+	assert.Contains(t, status, "Diagnostic-Code: smtp; recipient rejected\r\n")
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package smtp
+
+import (
+	"time"
+
+	goSMTPBackend "github.com/emersion/go-smtp"
+)
+
+// envelope is the SMTP envelope of the message with the ESMTP parameters
+// which bridge supports.
+type envelope struct {
+	from string
+	to   []string
+
+	// notifySuccess and notifyFailure are recipients which should get
+	// delivery status notification (see dsn.go).
+	notifySuccess []string
+	notifyFailure []string
+
+	// releaseAt is the time requested by FUTURERELEASE (see scheduled_send.go).
+	// Zero time means the message should be sent now.
+	releaseAt time.Time
+}
+
+func newEnvelope(smtpEnvelope *goSMTPBackend.Envelope, now time.Time) (*envelope, error) {
+	releaseAt, err := parseFutureRelease(smtpEnvelope.MailParams, now)
+	if err != nil {
+		return nil, err
+	}
+
+	notifySuccess, notifyFailure, err := parseRecipients(smtpEnvelope.To, smtpEnvelope.RcptParams)
+	if err != nil {
+		return nil, err
+	}
+
+	return &envelope{
+		from:          smtpEnvelope.From,
+		to:            smtpEnvelope.To,
+		notifySuccess: notifySuccess,
+		notifyFailure: notifyFailure,
+		releaseAt:     releaseAt,
+	}, nil
+}
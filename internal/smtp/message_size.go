@@ -0,0 +1,122 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package smtp
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	goSMTPBackend "github.com/emersion/go-smtp"
+)
+
+// defaultMaxUpload is used when the upload limit of the user is not known.
+const defaultMaxUpload = 25 * 1024 * 1024
+
+// maxHeaderBytes is allowance for the header and MIME structure of the message.
+const maxHeaderBytes = 1024 * 1024
+
+// getMaxMessageBytes returns the largest raw message size allowed by
+// the upload limit `maxUpload`. The limit applies to decoded attachments
+// while SMTP gets them usually in base64 which is 4/3 larger and has a line
+// break after each 76 characters. The precise limit is checked by the API.
+func getMaxMessageBytes(maxUpload uint) uint {
+	encoded := uint64(maxUpload+2) / 3 * 4
+	encoded += encoded / 76 * 2
+	return uint(encoded + maxHeaderBytes)
+}
+
+// getMaxMessageBytes returns the largest raw message size allowed for the user.
+func (su *smtpUser) getMaxMessageBytes() uint {
+	maxUpload, err := su.storeUser.GetMaxUpload()
+	if err != nil || maxUpload == 0 {
+		log.WithError(err).Warn("Cannot get max upload size, using the default one")
+		maxUpload = defaultMaxUpload
+	}
+
+	return getMaxMessageBytes(maxUpload)
+}
+
+// MaxMessageBytes implements go-smtp SizeLimitUser so that message declaring
+// larger SIZE in the MAIL command is rejected before it is uploaded.
+func (su *smtpUser) MaxMessageBytes() int {
+	return int(su.getMaxMessageBytes())
+}
+
+// getMaxUploadOfConnectedUsers returns the largest upload limit of any of
+// the connected users. The SIZE extension is advertised before the client
+// authenticates, so the limit of each user is checked by MaxMessageBytes
+// and readMessage.
+func (sb *smtpBackend) getMaxUploadOfConnectedUsers() uint {
+	var maxUpload uint
+
+	for _, user := range sb.bridge.GetUsers() {
+		if !user.IsConnected() {
+			continue
+		}
+
+		storeUser := user.GetStore()
+		if storeUser == nil {
+			continue
+		}
+
+		userMaxUpload, err := storeUser.GetMaxUpload()
+		if err != nil {
+			log.WithError(err).WithField("user", user.ID()).Warn("Cannot get max upload size")
+			continue
+		}
+
+		if userMaxUpload > maxUpload {
+			maxUpload = userMaxUpload
+		}
+	}
+
+	if maxUpload == 0 {
+		return defaultMaxUpload
+	}
+	return maxUpload
+}
+
+// getSizeCaps returns the SIZE capability advertised in the EHLO response.
+// It is computed for each EHLO so it follows changes of the upload limits.
+func (sb *smtpBackend) getSizeCaps() []string {
+	return []string{fmt.Sprintf("SIZE %d", getMaxMessageBytes(sb.getMaxUploadOfConnectedUsers()))}
+}
+
+// readMessage reads the whole message. Message larger than `maxBytes` is
+// rejected with ErrDataTooLarge which go-smtp turns into 552 reply. Zero
+// `maxBytes` means there is no limit.
+func readMessage(r io.Reader, maxBytes uint) ([]byte, error) {
+	if maxBytes == 0 {
+		return ioutil.ReadAll(r)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r, int64(maxBytes)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if uint(len(body)) > maxBytes {
+		// The rest of DATA has to be consumed, otherwise go-smtp would
+		// take it as commands.
+		_, _ = io.Copy(ioutil.Discard, r)
+		return nil, goSMTPBackend.ErrDataTooLarge
+	}
+
+	return body, nil
+}
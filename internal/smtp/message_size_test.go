@@ -0,0 +1,62 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package smtp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	goSMTPBackend "github.com/emersion/go-smtp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadMessage(t *testing.T) {
+	body, err := readMessage(strings.NewReader("0123456789"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(body))
+
+	body, err = readMessage(strings.NewReader("0123456789"), 10)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(body))
+
+	r := strings.NewReader("0123456789")
+	_, err = readMessage(r, 9)
+	assert.Equal(t, goSMTPBackend.ErrDataTooLarge, err)
+	assert.Equal(t, 0, r.Len(), "the rest of the message must be consumed")
+}
+
+func TestGetMaxMessageBytes(t *testing.T) {
+	const maxUpload = 10 * 1024 * 1024
+
+	// Attachment of the largest allowed size encoded as MUAs do it.
+	var encoded bytes.Buffer
+	line := make([]byte, 76)
+	raw := base64.StdEncoding.EncodeToString(make([]byte, maxUpload))
+	for r := strings.NewReader(raw); r.Len() > 0; {
+		n, _ := r.Read(line)
+		encoded.Write(line[:n])
+		encoded.WriteString("\r\n")
+	}
+
+	maxBytes := getMaxMessageBytes(maxUpload)
+	assert.True(t, maxBytes > uint(encoded.Len()), "limit %d is smaller than encoded attachment %d", maxBytes, encoded.Len())
+	assert.True(t, maxBytes < maxUpload*3/2, "limit %d is too generous", maxBytes)
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package smtp
+
+import (
+	"bytes"
+	"mime"
+	"net/mail"
+	"time"
+
+	"github.com/ProtonMail/proton-bridge/internal/events"
+	"github.com/ProtonMail/proton-bridge/internal/store"
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/pkg/errors"
+)
+
+const (
+	// outboxStartDelay gives bridge time to load and unlock users after start.
+	outboxStartDelay = 1 * time.Minute
+
+	outboxMinBackoff = 1 * time.Minute
+	outboxMaxBackoff = 30 * time.Minute
+)
+
+// isOfflineError returns whether the sending failed because the API was not reachable.
+func isOfflineError(err error) bool {
+	return err != nil && errors.Cause(err) == pmapi.ErrAPINotReachable
+}
+
+// isRetryableError returns whether the queued message should be tried again later.
+func isRetryableError(err error) bool {
+	return isOfflineError(err) || err == errMessageIsSending
+}
+
+// getOutboxBackoff returns how long to wait after `attempts` failed attempts.
+func getOutboxBackoff(attempts int) time.Duration {
+	backoff := outboxMinBackoff
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > outboxMaxBackoff {
+		backoff = outboxMaxBackoff
+	}
+	return backoff
+}
+
+// wakeOutbox makes watchOutbox check the queues immediately.
+func (sb *smtpBackend) wakeOutbox() {
+	select {
+	case sb.outboxWakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// watchOutbox delivers queued messages and dispatches scheduled messages of all
+// users when the internet connection is back or when the next one is due.
+func (sb *smtpBackend) watchOutbox() {
+	defer sb.panicHandler.HandlePanic()
+
+	internetOnCh := make(chan string)
+	sb.eventListener.Add(events.InternetOnEvent, internetOnCh)
+
+	timer := time.NewTimer(outboxStartDelay)
+	defer timer.Stop()
+
+	for {
+		force := false
+		select {
+		case <-internetOnCh:
+			force = true
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-sb.outboxWakeCh:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-timer.C:
+		}
+
+		wait := sb.deliverOutbox(force)
+		if scheduledWait := sb.dispatchScheduled(); scheduledWait < wait {
+			wait = scheduledWait
+		}
+		timer.Reset(wait)
+	}
+}
+
+// deliverOutbox tries to send all queued messages which are due (or all
+// retryable messages if `force` is set) and returns time to wait before
+// the next attempt.
+func (sb *smtpBackend) deliverOutbox(force bool) time.Duration {
+	wait := outboxMaxBackoff
+
+	for _, user := range sb.bridge.GetUsers() {
+		if !user.IsConnected() {
+			continue
+		}
+
+		storeUser := user.GetStore()
+		if storeUser == nil {
+			continue
+		}
+
+		userWait, online := sb.deliverUserOutbox(user, storeUser, force)
+		if userWait < wait {
+			wait = userWait
+		}
+		if !online {
+			break
+		}
+	}
+
+	return wait
+}
+
+// deliverUserOutbox sends due messages of one user. It returns time to wait
+// before the next attempt and false if the API is not reachable.
+func (sb *smtpBackend) deliverUserOutbox(user bridgeUser, storeUser storeUserProvider, force bool) (wait time.Duration, online bool) {
+	l := log.WithField("user", user.ID())
+	wait = outboxMaxBackoff
+
+	msgs, err := storeUser.ListOutgoing()
+	if err != nil {
+		l.WithError(err).Error("Cannot list outbox")
+		return wait, true
+	}
+
+	for _, msg := range msgs {
+		if msg.Failed || msg.Body == nil {
+			continue
+		}
+
+		if !force && time.Now().Before(msg.NextAttempt) {
+			if untilNext := time.Until(msg.NextAttempt); untilNext < wait {
+				wait = untilNext
+			}
+			continue
+		}
+
+		addressID := msg.AddressID
+		if user.IsCombinedAddressMode() {
+			addressID = ""
+		}
+
+		su := &smtpUser{
+			panicHandler:  sb.panicHandler,
+			eventListener: sb.eventListener,
+			backend:       sb,
+			user:          user,
+			storeUser:     storeUser,
+			addressID:     addressID,
+		}
+
+		l := l.WithField("id", msg.ID)
+		sendErr := su.send(&envelope{
+			from:          msg.From,
+			to:            msg.To,
+			notifySuccess: msg.NotifySuccess,
+			notifyFailure: msg.NotifyFailure,
+			releaseAt:     msg.SendAt,
+		}, msg.Body)
+
+		if sendErr == nil {
+			l.Info("Queued message was sent")
+			if err := storeUser.CancelOutgoing(msg.ID); err != nil {
+				l.WithError(err).Error("Cannot remove sent message from outbox")
+			}
+			continue
+		}
+
+		retryable := isRetryableError(sendErr)
+		backoff := getOutboxBackoff(msg.Attempts + 1)
+		if err := storeUser.RecordOutgoingFailure(msg.ID, sendErr, time.Now().Add(backoff), !retryable); err != nil {
+			l.WithError(err).Error("Cannot record failure of queued message")
+		}
+
+		if !retryable {
+			l.WithError(sendErr).Error("Queued message cannot be sent")
+			sb.reportOutboxFailure(user, msg, sendErr)
+			continue
+		}
+
+		l.WithError(sendErr).Warn("Queued message was not sent, will retry")
+		if backoff < wait {
+			wait = backoff
+		}
+
+		if isOfflineError(sendErr) {
+			return wait, false
+		}
+	}
+
+	return wait, true
+}
+
+// reportOutboxFailure imports a delivery status notification about the queued
+// message which cannot be sent. Without it, the user would not know about the
+// failure because the SMTP client was told the message was accepted.
+func (sb *smtpBackend) reportOutboxFailure(user bridgeUser, msg *store.OutgoingMessage, sendErr error) {
+	subject := ""
+	if header, err := mail.ReadMessage(bytes.NewReader(msg.Body)); err == nil {
+		subject, _ = new(mime.WordDecoder).DecodeHeader(header.Header.Get("Subject"))
+	}
+
+	sb.reportDelivery(user.GetTemporaryPMAPIClient(), msg.AddressID, &deliveryReport{
+		subject:        subject,
+		originalHeader: getRawHeader(msg.Body),
+		recipients:     msg.NotifyFailure,
+		sendErr:        sendErr,
+		arrival:        msg.QueuedAt,
+	})
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package smtp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	assert.Equal(t, 1*time.Minute, getOutboxBackoff(0))
+	assert.Equal(t, 1*time.Minute, getOutboxBackoff(1))
+	assert.Equal(t, 2*time.Minute, getOutboxBackoff(2))
+	assert.Equal(t, 16*time.Minute, getOutboxBackoff(5))
+	assert.Equal(t, outboxMaxBackoff, getOutboxBackoff(6))
+	assert.Equal(t, outboxMaxBackoff, getOutboxBackoff(100))
+}
+
+func TestOutboxRetryableErrors(t *testing.T) {
+	assert.False(t, isRetryableError(nil))
+	assert.True(t, isRetryableError(pmapi.ErrAPINotReachable))
+	assert.True(t, isRetryableError(errors.Wrap(pmapi.ErrAPINotReachable, "failed to create draft")))
+	assert.True(t, isRetryableError(errMessageIsSending))
+	assert.False(t, isRetryableError(errors.New("invalid recipient")))
+	assert.False(t, isOfflineError(errMessageIsSending))
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package smtp
+
+import (
+	"bytes"
+	"mime"
+	"net/mail"
+	"strings"
+
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+)
+
+// PGP/MIME messages (RFC 3156) encrypted or signed by the client are sent
+// as they are. Parsing and building them again would wrap the encrypted
+// part once more or break the signature.
+const (
+	pgpEncryptedProtocol = "application/pgp-encrypted"
+	pgpSignatureProtocol = "application/pgp-signature"
+)
+
+// getPGPMIMEBody returns the MIME entity of the raw message if it is
+// multipart/encrypted or multipart/signed using PGP. Returned entity has
+// only the Content-Type header; the body is kept byte by byte.
+func getPGPMIMEBody(raw []byte) (body string, encrypted, ok bool) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return "", false, false
+	}
+
+	contentType := msg.Header.Get("Content-Type")
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false, false
+	}
+
+	protocol := strings.ToLower(params["protocol"])
+	switch {
+	case mediaType == "multipart/encrypted" && protocol == pgpEncryptedProtocol:
+		encrypted = true
+	case mediaType == "multipart/signed" && protocol == pgpSignatureProtocol:
+		encrypted = false
+	default:
+		return "", false, false
+	}
+
+	return "Content-Type: " + contentType + "\r\n\r\n" + string(getRawBody(raw)), encrypted, true
+}
+
+// getPGPMIMESendingInfo adjusts the sending decision for a PGP/MIME message
+// from the client. It is always sent as MIME and never signed again (see
+// encryptSymmetric). Internal recipients get it encrypted to their key, which
+// is required to store it; the encryption of the client stays inside. External
+// recipients with a key get the message signed by the client encrypted to that
+// key. Others, or anyone for message already encrypted by the client, get it
+// as it is.
+func getPGPMIMESendingInfo(info SendingInfo, clientEncrypted bool) SendingInfo {
+	info.Sign = false
+	info.MIMEType = pmapi.ContentTypeMultipartMixed
+
+	if info.Scheme == pmapi.InternalPackage || (!clientEncrypted && info.Encrypt && info.PublicKey != nil) {
+		info.Scheme = pmapi.PGPMIMEPackage
+		info.Encrypt = true
+		return info
+	}
+
+	info.Scheme = pmapi.ClearMIMEPackage
+	info.Encrypt = clientEncrypted
+	info.PublicKey = nil
+	return info
+}
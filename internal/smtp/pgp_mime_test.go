@@ -0,0 +1,123 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package smtp
+
+import (
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPGPMIMEBody(t *testing.T) {
+	testCases := []struct {
+		name          string
+		raw           string
+		wantBody      string
+		wantEncrypted bool
+		wantOK        bool
+	}{
+		{
+			name: "plain text",
+			raw:  "Subject: Hello\r\nContent-Type: text/plain\r\n\r\nbody\r\n",
+		},
+		{
+			name: "multipart/signed with other protocol",
+			raw:  "Content-Type: multipart/signed; protocol=\"application/pkcs7-signature\"; boundary=b\r\n\r\n--b--\r\n",
+		},
+		{
+			name:          "multipart/encrypted",
+			raw:           "Subject: Hello\r\nContent-Type: multipart/encrypted;\r\n protocol=\"application/pgp-encrypted\"; boundary=b\r\n\r\n--b\r\nVersion: 1\r\n--b--\r\n",
+			wantBody:      "Content-Type: multipart/encrypted; protocol=\"application/pgp-encrypted\"; boundary=b\r\n\r\n--b\r\nVersion: 1\r\n--b--\r\n",
+			wantEncrypted: true,
+			wantOK:        true,
+		},
+		{
+			name:     "multipart/signed",
+			raw:      "Content-Type: multipart/signed; micalg=pgp-sha256; protocol=\"application/pgp-signature\"; boundary=b\n\n--b\nbody \n--b--\n",
+			wantBody: "Content-Type: multipart/signed; micalg=pgp-sha256; protocol=\"application/pgp-signature\"; boundary=b\r\n\r\n--b\nbody \n--b--\n",
+			wantOK:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		body, encrypted, ok := getPGPMIMEBody([]byte(tc.raw))
+		assert.Equal(t, tc.wantOK, ok, tc.name)
+		assert.Equal(t, tc.wantEncrypted, encrypted, tc.name)
+		assert.Equal(t, tc.wantBody, body, tc.name)
+	}
+}
+
+func TestGetPGPMIMESendingInfo(t *testing.T) {
+	internal := getPGPMIMESendingInfo(SendingInfo{Encrypt: true, Sign: true, Scheme: pmapi.InternalPackage, MIMEType: pmapi.ContentTypeHTML}, false)
+	assert.Equal(t, SendingInfo{Encrypt: true, Scheme: pmapi.PGPMIMEPackage, MIMEType: pmapi.ContentTypeMultipartMixed}, internal)
+
+	external := getPGPMIMESendingInfo(SendingInfo{Encrypt: true, Sign: true, Scheme: pmapi.PGPInlinePackage, MIMEType: pmapi.ContentTypePlainText, KeySource: KeySourceWKD}, true)
+	assert.Equal(t, SendingInfo{Encrypt: true, Scheme: pmapi.ClearMIMEPackage, MIMEType: pmapi.ContentTypeMultipartMixed, KeySource: KeySourceWKD}, external)
+
+	signed := getPGPMIMESendingInfo(SendingInfo{Sign: true, Scheme: pmapi.ClearPackage}, false)
+	assert.False(t, signed.Encrypt)
+	assert.False(t, signed.Sign)
+	assert.Equal(t, pmapi.ClearMIMEPackage, signed.Scheme)
+}
+
+func TestGetPGPMIMESendingInfoExternalWithKey(t *testing.T) {
+	publicKey := keyRingFromKey(testPublicKey)
+
+	signed := getPGPMIMESendingInfo(SendingInfo{Encrypt: true, Sign: true, Scheme: pmapi.PGPInlinePackage, MIMEType: pmapi.ContentTypePlainText, PublicKey: publicKey, KeySource: KeySourceWKD}, false)
+	assert.True(t, signed.Encrypt)
+	assert.False(t, signed.Sign)
+	assert.Equal(t, pmapi.PGPMIMEPackage, signed.Scheme)
+	assert.Equal(t, pmapi.ContentTypeMultipartMixed, signed.MIMEType)
+	assert.True(t, keyRingsAreEqual(publicKey, signed.PublicKey))
+
+	encrypted := getPGPMIMESendingInfo(SendingInfo{Encrypt: true, Sign: true, Scheme: pmapi.PGPMIMEPackage, PublicKey: publicKey, KeySource: KeySourceContact}, true)
+	assert.True(t, encrypted.Encrypt)
+	assert.Equal(t, pmapi.ClearMIMEPackage, encrypted.Scheme)
+	assert.Nil(t, encrypted.PublicKey)
+
+	notEncrypted := getPGPMIMESendingInfo(SendingInfo{Sign: true, Scheme: pmapi.ClearPackage, PublicKey: publicKey, KeySource: KeySourceContact}, false)
+	assert.False(t, notEncrypted.Encrypt)
+	assert.Equal(t, pmapi.ClearMIMEPackage, notEncrypted.Scheme)
+}
+
+func TestEncryptSymmetricSignature(t *testing.T) {
+	key, err := crypto.GenerateKey("Test", "test@pm.me", "x25519", 0)
+	require.NoError(t, err)
+	kr, err := crypto.NewKeyRing(key)
+	require.NoError(t, err)
+
+	decryptAndVerify := func(sessionKey *crypto.SessionKey, data []byte) error {
+		keyPacket, err := kr.EncryptSessionKey(sessionKey)
+		require.NoError(t, err)
+
+		msg := crypto.NewPGPSplitMessage(keyPacket, data).GetPGPMessage()
+		_, err = kr.Decrypt(msg, kr, crypto.GetUnixTime())
+		return err
+	}
+
+	sessionKey, data, err := encryptSymmetric(kr, "Content-Type: text/plain\r\n\r\nHello\r\n", true, true)
+	require.NoError(t, err)
+	assert.NoError(t, decryptAndVerify(sessionKey, data))
+
+	sessionKey, data, err = encryptSymmetric(kr, "Content-Type: text/plain\r\n\r\nHello\r\n", false, false)
+	require.NoError(t, err)
+	assert.Error(t, decryptAndVerify(sessionKey, data))
+}
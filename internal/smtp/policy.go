@@ -0,0 +1,260 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package smtp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	goSMTPBackend "github.com/emersion/go-smtp"
+	"github.com/pkg/errors"
+)
+
+// Actions of outgoing policy rules.
+const (
+	policyRequireEncryption = "require_encryption"
+	policyBlockExternal     = "block_external"
+	policyStripHeaders      = "strip_headers"
+)
+
+// policyRule is one rule of the outgoing policy file, for example:
+//
+//	{"rules": [
+//	  {"name": "partners", "action": "require_encryption", "recipients": ["partner.com"]},
+//	  {"name": "interns", "action": "block_external", "senders": ["*@interns.example.com"]},
+//	  {"name": "privacy", "action": "strip_headers", "headers": ["User-Agent", "X-Mailer"]}
+//	]}
+//
+// Senders and recipients are either domains (matching also subdomains) or
+// addresses with optional wildcards. Empty list matches everyone.
+type policyRule struct {
+	Name       string   `json:"name"`
+	Action     string   `json:"action"`
+	Senders    []string `json:"senders"`
+	Recipients []string `json:"recipients"`
+	Headers    []string `json:"headers"`
+}
+
+type policyRules []policyRule
+
+type policyFile struct {
+	Rules policyRules `json:"rules"`
+}
+
+// policyError is returned when the message violates the outgoing policy.
+// It is replied with 550 and the enhanced status code (see RFC 3463) in the
+// text tells clients why the message was rejected.
+type policyError struct {
+	rule   string
+	reason string
+}
+
+func (err *policyError) Error() string {
+	return fmt.Sprintf("5.7.1 Rejected by outgoing policy rule %q: %s", err.rule, err.reason)
+}
+
+// outgoingPolicy provides rules from the policy file. The file is optional
+// and is read again whenever it changes, so there is no need to restart.
+type outgoingPolicy struct {
+	path string
+
+	lock    sync.Mutex
+	modTime time.Time
+	rules   policyRules
+	loadErr error
+}
+
+func newOutgoingPolicy(path string) *outgoingPolicy {
+	return &outgoingPolicy{path: path}
+}
+
+// getRules returns current rules. Invalid policy file is reported as error
+// so that messages are not sent without the policy the admin asked for.
+// It is a temporary failure; clients can send again once it is fixed.
+func (p *outgoingPolicy) getRules() (policyRules, error) {
+	if p == nil || p.path == "" {
+		return nil, nil
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	info, err := os.Stat(p.path)
+	if os.IsNotExist(err) {
+		p.modTime, p.rules, p.loadErr = time.Time{}, nil, nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "4.3.5 Cannot read outgoing policy")
+	}
+
+	if !info.ModTime().Equal(p.modTime) {
+		p.modTime = info.ModTime()
+		p.rules, p.loadErr = loadPolicyRules(p.path)
+		if p.loadErr != nil {
+			log.WithError(p.loadErr).WithField("path", p.path).Error("Outgoing policy is invalid, all messages will be rejected")
+		} else {
+			log.WithField("path", p.path).WithField("rules", len(p.rules)).Info("Outgoing policy loaded")
+		}
+	}
+
+	return p.rules, p.loadErr
+}
+
+func loadPolicyRules(path string) (policyRules, error) {
+	raw, err := ioutil.ReadFile(path) //nolint[gosec]
+	if err != nil {
+		return nil, errors.Wrap(err, "4.3.5 Cannot read outgoing policy")
+	}
+
+	file := policyFile{}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, errors.Wrap(err, "4.3.5 Cannot parse outgoing policy")
+	}
+
+	for i, rule := range file.Rules {
+		if err := rule.validate(); err != nil {
+			return nil, errors.Wrapf(err, "4.3.5 Invalid outgoing policy rule #%d", i+1)
+		}
+	}
+
+	return file.Rules, nil
+}
+
+func (rule *policyRule) validate() error {
+	switch rule.Action {
+	case policyRequireEncryption, policyBlockExternal:
+	case policyStripHeaders:
+		if len(rule.Headers) == 0 {
+			return errors.New("no headers to strip")
+		}
+	default:
+		return errors.New("unknown action " + rule.Action)
+	}
+
+	for _, pattern := range append(rule.Senders, rule.Recipients...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrap(err, pattern)
+		}
+	}
+
+	return nil
+}
+
+func (rule *policyRule) getName() string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	return rule.Action
+}
+
+// stripHeaders removes headers listed by rules which apply to `senders`.
+func (rules policyRules) stripHeaders(senders []string, body []byte) []byte {
+	for _, rule := range rules {
+		if rule.Action != policyStripHeaders || !matchesAnyAddress(rule.Senders, senders...) {
+			continue
+		}
+		for _, header := range rule.Headers {
+			body = removeHeader(body, header)
+		}
+	}
+	return body
+}
+
+// newPolicySMTPError returns the SMTP reply for errors of the outgoing policy:
+// 550 for a message rejected by the policy and 451 when it cannot be loaded.
+func newPolicySMTPError(err error) *goSMTPBackend.SMTPError {
+	if _, ok := err.(*policyError); ok {
+		return &goSMTPBackend.SMTPError{Code: 550, Message: err.Error()}
+	}
+	return &goSMTPBackend.SMTPError{Code: 451, Message: err.Error()}
+}
+
+// check returns policyError when sending to recipients `to` with decisions
+// `sendingInfos` is not allowed for any of `senders`.
+func (rules policyRules) check(senders, to []string, sendingInfos map[string]SendingInfo) error {
+	for _, rule := range rules {
+		if !matchesAnyAddress(rule.Senders, senders...) {
+			continue
+		}
+
+		for _, email := range to {
+			if !matchesAnyAddress(rule.Recipients, email) {
+				continue
+			}
+
+			sendingInfo := sendingInfos[email]
+			switch rule.Action {
+			case policyRequireEncryption:
+				if !sendingInfo.Encrypt {
+					return &policyError{rule: rule.getName(), reason: "message to " + email + " would not be encrypted"}
+				}
+			case policyBlockExternal:
+				if !sendingInfo.Internal {
+					return &policyError{rule: rule.getName(), reason: "sending to external recipient " + email + " is not allowed"}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// matchesAnyAddress returns whether any of `addresses` matches any of
+// `patterns`. Empty `patterns` matches everything.
+func matchesAnyAddress(patterns []string, addresses ...string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		for _, address := range addresses {
+			if matchesAddress(pattern, address) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchesAddress returns whether `address` matches `pattern` which is
+// a domain (matching also its subdomains) or an address with wildcards.
+func matchesAddress(pattern, address string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	address = strings.ToLower(strings.TrimSpace(address))
+
+	if pattern == "*" {
+		return true
+	}
+
+	if strings.Contains(pattern, "@") {
+		ok, _ := path.Match(pattern, address)
+		return ok
+	}
+
+	domain := address[strings.LastIndex(address, "@")+1:]
+	pattern = strings.TrimPrefix(pattern, ".")
+	return domain == pattern || strings.HasSuffix(domain, "."+pattern)
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package smtp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchesAddress(t *testing.T) {
+	testCases := []struct {
+		pattern, address string
+		want             bool
+	}{
+		{"*", "user@example.com", true},
+		{"example.com", "user@example.com", true},
+		{"example.com", "user@mail.example.com", true},
+		{"example.com", "user@badexample.com", false},
+		{".Example.com", "user@EXAMPLE.com", true},
+		{"*@example.com", "user@example.com", true},
+		{"*@example.com", "user@mail.example.com", false},
+		{"boss@example.com", "Boss@example.com", true},
+		{"boss@example.com", "intern@example.com", false},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, matchesAddress(tc.pattern, tc.address), "%v ~ %v", tc.pattern, tc.address)
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	rules := policyRules{
+		{Name: "partners", Action: policyRequireEncryption, Recipients: []string{"partner.com"}},
+		{Name: "interns", Action: policyBlockExternal, Senders: []string{"intern@pm.me"}},
+	}
+	sendingInfos := map[string]SendingInfo{
+		"a@partner.com":  {Encrypt: false},
+		"b@partner.com":  {Encrypt: true},
+		"c@pm.me":        {Encrypt: true, Internal: true},
+		"d@external.com": {Encrypt: false},
+	}
+
+	testCases := []struct {
+		sender   string
+		to       []string
+		wantRule string
+	}{
+		{sender: "user@pm.me", to: []string{"b@partner.com", "c@pm.me", "d@external.com"}},
+		{sender: "user@pm.me", to: []string{"b@partner.com", "a@partner.com"}, wantRule: "partners"},
+		{sender: "intern@pm.me", to: []string{"c@pm.me"}},
+		{sender: "intern@pm.me", to: []string{"c@pm.me", "d@external.com"}, wantRule: "interns"},
+		{sender: "intern+tag@pm.me", to: []string{"b@partner.com"}},
+	}
+
+	for _, tc := range testCases {
+		err := rules.check([]string{tc.sender}, tc.to, sendingInfos)
+		if tc.wantRule == "" {
+			assert.NoError(t, err, "%v -> %v", tc.sender, tc.to)
+			continue
+		}
+		if assert.IsType(t, &policyError{}, err, "%v -> %v", tc.sender, tc.to) {
+			assert.Equal(t, tc.wantRule, err.(*policyError).rule)
+		}
+	}
+}
+
+func TestPolicyStripHeaders(t *testing.T) {
+	rules := policyRules{
+		{Action: policyStripHeaders, Headers: []string{"User-Agent"}},
+		{Action: policyStripHeaders, Senders: []string{"other.com"}, Headers: []string{"Subject"}},
+	}
+
+	body := []byte("Subject: Hello\r\nUser-Agent: Mail\r\n client\r\nTo: a@b.c\r\n\r\nUser-Agent: body\r\n")
+	got := rules.stripHeaders([]string{"user@pm.me"}, body)
+	assert.Equal(t, "Subject: Hello\r\nTo: a@b.c\r\n\r\nUser-Agent: body\r\n", string(got))
+}
+
+func TestOutgoingPolicyReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policy")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint[errcheck]
+
+	path := filepath.Join(dir, "outgoing_policy.json")
+	policy := newOutgoingPolicy(path)
+
+	rules, err := policy.getRules()
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"rules": [{"action": "block_external"}]}`), 0600))
+	rules, err = policy.getRules()
+	require.NoError(t, err)
+	assert.Equal(t, policyRules{{Action: policyBlockExternal}}, rules)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"rules": [{"action": "unknown"}]}`), 0600))
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, future, future))
+	_, err = policy.getRules()
+	assert.Error(t, err)
+
+	require.NoError(t, os.Remove(path))
+	rules, err = policy.getRules()
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+}
+
+func TestNewPolicySMTPError(t *testing.T) {
+	rejected := newPolicySMTPError(&policyError{rule: "rule", reason: "reason"})
+	assert.Equal(t, 550, rejected.Code)
+	assert.Equal(t, `5.7.1 Rejected by outgoing policy rule "rule": reason`, rejected.Message)
+
+	notLoaded := newPolicySMTPError(errors.New("4.3.5 Cannot parse outgoing policy"))
+	assert.Equal(t, 451, notLoaded.Code)
+	assert.Equal(t, "4.3.5 Cannot parse outgoing policy", notLoaded.Message)
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package smtp
+
+import (
+	"github.com/ProtonMail/proton-bridge/pkg/listener"
+	"github.com/pkg/errors"
+)
+
+// RecipientPreview describes how a message would be sent to one recipient.
+// Err is set when the message could not be sent to the recipient.
+type RecipientPreview struct {
+	SendingInfo
+	Recipient string
+	Err       error
+}
+
+// PreviewSending returns how a message from `from` composed as `composeMode`
+// would be sent to each of `to`. It makes the same decisions as sending but
+// no draft is created and nothing is sent.
+func (sb *smtpBackend) PreviewSending(from string, to []string, composeMode string) ([]*RecipientPreview, error) {
+	su := sb.getPreviewUser(from)
+	if su == nil {
+		return nil, errors.New("backend: invalid email address: not owned by any user")
+	}
+
+	mailSettings, err := su.client().GetMailSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	previews := []*RecipientPreview{}
+	for _, email := range to {
+		preview := &RecipientPreview{Recipient: email}
+		if !looksLikeEmail(email) {
+			preview.Err = errors.New(`"` + email + `" is not a valid recipient.`)
+		} else {
+			preview.SendingInfo, preview.Err = su.getSendingInfo(email, composeMode, mailSettings.Sign > 0, mailSettings.PGPScheme)
+		}
+		previews = append(previews, preview)
+	}
+
+	return previews, nil
+}
+
+// getPreviewUser returns the user sending messages from `from`.
+func (sb *smtpBackend) getPreviewUser(from string) *smtpUser {
+	for _, user := range sb.bridge.GetUsers() {
+		storeUser := user.GetStore()
+		if !user.IsConnected() || storeUser == nil {
+			continue
+		}
+
+		// Preview must not notify the GUI about recipients without active key,
+		// therefore events go to a listener nobody listens to.
+		su := &smtpUser{
+			panicHandler:  sb.panicHandler,
+			eventListener: listener.New(),
+			backend:       sb,
+			user:          user,
+			storeUser:     storeUser,
+		}
+
+		if addr, err := su.getSenderAddress(from); err == nil {
+			su.addressID = addr.ID
+			return su
+		}
+	}
+
+	return nil
+}
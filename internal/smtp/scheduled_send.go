@@ -0,0 +1,252 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package smtp
+
+import (
+	"bytes"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/proton-bridge/internal/store"
+	"github.com/pkg/errors"
+)
+
+// sendAtHeader lets clients without support of scheduled sending ask bridge
+// to send the message later. The value is a date in RFC 5322 or RFC 3339 format.
+const sendAtHeader = "X-PM-Send-At"
+
+// maxFutureRelease is the longest time the message can be held by
+// the FUTURERELEASE extension (RFC 4865).
+const maxFutureRelease = 30 * 24 * time.Hour
+
+// futureReleaseCaps returns the FUTURERELEASE capability advertised in
+// the EHLO response. The latest allowed release date moves with time.
+func futureReleaseCaps() []string {
+	maxInterval := int64(maxFutureRelease / time.Second)
+	maxDate := time.Now().Add(maxFutureRelease).UTC().Format(time.RFC3339)
+	return []string{fmt.Sprintf("FUTURERELEASE %d %s", maxInterval, maxDate)}
+}
+
+// parseFutureRelease returns the release time requested by HOLDFOR or
+// HOLDUNTIL parameter of the MAIL command. Zero time means the message
+// should be sent now.
+func parseFutureRelease(params map[string]string, now time.Time) (time.Time, error) {
+	holdFor, hasHoldFor := params["HOLDFOR"]
+	holdUntil, hasHoldUntil := params["HOLDUNTIL"]
+
+	var releaseAt time.Time
+	switch {
+	case hasHoldFor && hasHoldUntil:
+		return time.Time{}, errors.New("backend: HOLDFOR and HOLDUNTIL cannot be used together")
+	case hasHoldFor:
+		seconds, err := strconv.ParseUint(holdFor, 10, 32)
+		if err != nil {
+			return time.Time{}, errors.Wrap(err, "backend: invalid HOLDFOR parameter")
+		}
+		releaseAt = now.Add(time.Duration(seconds) * time.Second)
+	case hasHoldUntil:
+		var err error
+		if releaseAt, err = time.Parse(time.RFC3339, holdUntil); err != nil {
+			return time.Time{}, errors.Wrap(err, "backend: invalid HOLDUNTIL parameter")
+		}
+	default:
+		return time.Time{}, nil
+	}
+
+	if releaseAt.Sub(now) > maxFutureRelease {
+		return time.Time{}, errors.New("backend: release time exceeds the maximal future release interval")
+	}
+
+	if !releaseAt.After(now) {
+		return time.Time{}, nil
+	}
+
+	return releaseAt, nil
+}
+
+// extractSendAt returns the time the message should be sent at and the message
+// without the sendAtHeader. Zero time means the message should be sent now.
+func extractSendAt(raw []byte, now time.Time) (sendAt time.Time, stripped []byte, err error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		// Broken message is reported by the message parser later.
+		return time.Time{}, raw, nil
+	}
+
+	value := msg.Header.Get(sendAtHeader)
+	if value == "" {
+		return time.Time{}, raw, nil
+	}
+
+	if sendAt, err = parseSendAt(value); err != nil {
+		return time.Time{}, raw, errors.Wrap(err, "backend: invalid "+sendAtHeader+" header")
+	}
+
+	if !sendAt.After(now) {
+		sendAt = time.Time{}
+	}
+
+	return sendAt, removeHeader(raw, sendAtHeader), nil
+}
+
+func parseSendAt(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return mail.ParseDate(value)
+}
+
+// removeHeader removes all occurrences of the header `key` (including
+// its folded continuation lines) from the header section of `raw`.
+func removeHeader(raw []byte, key string) []byte {
+	var out bytes.Buffer
+	prefix := strings.ToLower(key) + ":"
+	skipping := false
+
+	rest := raw
+	for len(rest) > 0 {
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line = rest[:i+1]
+		}
+		rest = rest[len(line):]
+
+		// Empty line ends the header; the body is copied as it is.
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			out.Write(line)
+			out.Write(rest)
+			break
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			if !skipping {
+				out.Write(line)
+			}
+			continue
+		}
+
+		skipping = strings.HasPrefix(strings.ToLower(string(line)), prefix)
+		if !skipping {
+			out.Write(line)
+		}
+	}
+
+	return out.Bytes()
+}
+
+// scheduleSend stores the send request of the draft to be dispatched at `send.SendAt`.
+func (su *smtpUser) scheduleSend(send *store.ScheduledSend) error {
+	if err := su.storeUser.ScheduleSend(send); err != nil {
+		return errors.Wrap(err, "failed to schedule message")
+	}
+
+	log.WithField("messageID", send.ID).WithField("sendAt", send.SendAt).Info("Message scheduled to be sent later")
+	su.backend.wakeOutbox()
+	return nil
+}
+
+// dispatchScheduled sends all scheduled drafts which are due and returns time
+// to wait before the next dispatch.
+func (sb *smtpBackend) dispatchScheduled() time.Duration {
+	wait := outboxMaxBackoff
+
+	for _, user := range sb.bridge.GetUsers() {
+		if !user.IsConnected() {
+			continue
+		}
+
+		storeUser := user.GetStore()
+		if storeUser == nil {
+			continue
+		}
+
+		if userWait := sb.dispatchUserScheduled(user, storeUser); userWait < wait {
+			wait = userWait
+		}
+	}
+
+	return wait
+}
+
+func (sb *smtpBackend) dispatchUserScheduled(user bridgeUser, storeUser storeUserProvider) time.Duration {
+	l := log.WithField("user", user.ID())
+	wait := outboxMaxBackoff
+
+	sends, err := storeUser.ListScheduledSends()
+	if err != nil {
+		l.WithError(err).Error("Cannot list scheduled sends")
+		return wait
+	}
+
+	for _, send := range sends {
+		if send.Failed || send.Request == nil {
+			continue
+		}
+
+		if untilNext := time.Until(send.NextAttempt); untilNext > 0 {
+			if untilNext < wait {
+				wait = untilNext
+			}
+			continue
+		}
+
+		l := l.WithField("messageID", send.ID)
+		sendErr := storeUser.SendMessage(send.ID, send.Request)
+
+		if sendErr == nil {
+			l.Info("Scheduled message was sent")
+			if err := storeUser.CancelScheduledSend(send.ID); err != nil {
+				l.WithError(err).Error("Cannot remove sent message from schedule")
+			}
+			sb.reportDelivery(user.GetTemporaryPMAPIClient(), send.AddressID, &deliveryReport{
+				subject:    send.Subject,
+				recipients: send.NotifySuccess,
+				arrival:    send.SendAt,
+			})
+			continue
+		}
+
+		retryable := isOfflineError(sendErr)
+		backoff := getOutboxBackoff(send.Attempts + 1)
+		if err := storeUser.RecordScheduledSendFailure(send.ID, sendErr, time.Now().Add(backoff), !retryable); err != nil {
+			l.WithError(err).Error("Cannot record failure of scheduled message")
+		}
+
+		if !retryable {
+			l.WithError(sendErr).Error("Scheduled message cannot be sent")
+			sb.reportDelivery(user.GetTemporaryPMAPIClient(), send.AddressID, &deliveryReport{
+				subject:    send.Subject,
+				recipients: send.NotifyFailure,
+				sendErr:    sendErr,
+				arrival:    send.SendAt,
+			})
+			continue
+		}
+
+		l.WithError(sendErr).Warn("Scheduled message was not sent, will retry")
+		if backoff < wait {
+			wait = backoff
+		}
+	}
+
+	return wait
+}
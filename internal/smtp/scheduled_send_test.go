@@ -0,0 +1,161 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package smtp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractSendAt(t *testing.T) {
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name       string
+		raw        string
+		wantSendAt time.Time
+		wantRaw    string
+		wantErr    bool
+	}{
+		{
+			name:    "no header",
+			raw:     "Subject: Hello\r\n\r\nbody\r\n",
+			wantRaw: "Subject: Hello\r\n\r\nbody\r\n",
+		},
+		{
+			name:       "RFC 3339",
+			raw:        "Subject: Hello\r\nX-PM-Send-At: 2020-06-01T12:00:00Z\r\n\r\nbody\r\n",
+			wantSendAt: time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC),
+			wantRaw:    "Subject: Hello\r\n\r\nbody\r\n",
+		},
+		{
+			name:       "RFC 5322 folded",
+			raw:        "x-pm-send-at: Mon, 1 Jun 2020\r\n 11:00:00 +0000\r\nSubject: Hello\r\n\r\nX-PM-Send-At: in body\r\n",
+			wantSendAt: time.Date(2020, 6, 1, 11, 0, 0, 0, time.UTC),
+			wantRaw:    "Subject: Hello\r\n\r\nX-PM-Send-At: in body\r\n",
+		},
+		{
+			name:    "in the past",
+			raw:     "X-PM-Send-At: 2020-06-01T09:00:00Z\nSubject: Hello\n\nbody\n",
+			wantRaw: "Subject: Hello\n\nbody\n",
+		},
+		{
+			name:    "invalid",
+			raw:     "X-PM-Send-At: tomorrow\r\n\r\nbody\r\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // bind
+		t.Run(tc.name, func(t *testing.T) {
+			sendAt, raw, err := extractSendAt([]byte(tc.raw), now)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tc.wantSendAt.Equal(sendAt), "got %v", sendAt)
+			assert.Equal(t, tc.wantRaw, string(raw))
+		})
+	}
+}
+
+func TestParseFutureRelease(t *testing.T) {
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name          string
+		params        map[string]string
+		wantReleaseAt time.Time
+		wantErr       bool
+	}{
+		{
+			name: "no parameters",
+		},
+		{
+			name:   "other parameters",
+			params: map[string]string{"BODY": "8BITMIME"},
+		},
+		{
+			name:          "HOLDFOR",
+			params:        map[string]string{"HOLDFOR": "3600"},
+			wantReleaseAt: time.Date(2020, 6, 1, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "HOLDUNTIL",
+			params:        map[string]string{"HOLDUNTIL": "2020-06-01T14:00:00+02:00"},
+			wantReleaseAt: time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "HOLDUNTIL in the past",
+			params: map[string]string{"HOLDUNTIL": "2020-06-01T09:00:00Z"},
+		},
+		{
+			name:    "both",
+			params:  map[string]string{"HOLDFOR": "60", "HOLDUNTIL": "2020-06-01T12:00:00Z"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid HOLDFOR",
+			params:  map[string]string{"HOLDFOR": "-60"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid HOLDUNTIL",
+			params:  map[string]string{"HOLDUNTIL": "tomorrow"},
+			wantErr: true,
+		},
+		{
+			name:    "too far",
+			params:  map[string]string{"HOLDUNTIL": "2020-08-01T00:00:00Z"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // bind
+		t.Run(tc.name, func(t *testing.T) {
+			releaseAt, err := parseFutureRelease(tc.params, now)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tc.wantReleaseAt.Equal(releaseAt), "got %v", releaseAt)
+		})
+	}
+}
+
+func TestFutureReleaseCaps(t *testing.T) {
+	caps := futureReleaseCaps()
+	require.Len(t, caps, 1)
+
+	fields := strings.Fields(caps[0])
+	require.Len(t, fields, 3)
+	assert.Equal(t, "FUTURERELEASE", fields[0])
+	assert.Equal(t, "2592000", fields[1])
+
+	maxDate, err := time.Parse(time.RFC3339, fields[2])
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(maxFutureRelease), maxDate, time.Minute)
+}
@@ -18,78 +18,120 @@
 package smtp
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
 )
 
+// sendRecordExpiration is how long the sent messages are remembered.
+// It's hard to find a good expiration time.
+// On the one hand, a user could set up some cron job sending the same message over and over again (heartbeat).
+// On the the other, a user could put the device into sleep mode while sending.
+// Changing the expiration time will always make one of the edge cases worse.
+// But both edge cases are something we don't care much about. Important thing is we don't send the same message many times.
+const sendRecordExpiration = 30 * time.Minute
+
 type messageGetter interface {
 	GetMessage(string) (*pmapi.Message, error)
 }
 
-type sendRecorderValue struct {
-	messageID string
-	time      time.Time
+// sendRecordStorer persists the records, so duplicates are detected
+// even after the bridge is restarted.
+type sendRecordStorer interface {
+	GetSendRecord(hash string) (messageID string, recordedAt time.Time, err error)
+	AddSendRecord(hash, messageID string) error
+	DeleteSendRecordsBefore(before time.Time) error
+	IsScheduledSend(messageID string) (bool, error)
 }
 
+// sendRecorder detects the same message being sent more than once, e.g.
+// when the client does not get the response quickly and tries again.
 type sendRecorder struct {
-	lock   *sync.RWMutex
-	hashes map[string]sendRecorderValue
+	lock *sync.Mutex
 }
 
 func newSendRecorder() *sendRecorder {
 	return &sendRecorder{
-		lock:   &sync.RWMutex{},
-		hashes: map[string]sendRecorderValue{},
+		lock: &sync.Mutex{},
 	}
 }
 
-func (q *sendRecorder) getMessageHash(message *pmapi.Message) string {
+// getMessageHash returns the hash identifying the message sent from the address
+// with `addressID` to the envelope recipients `to`. The message is identified by
+// its Message-ID (if any) and its body (`raw` without the header), so retries are
+// detected even if the client regenerated headers such as Date. Recipients are
+// part of the hash because clients can send one message in several transactions,
+// e.g. separately to BCC recipients.
+func getMessageHash(addressID, externalID string, to []string, raw []byte) string {
+	recipients := append([]string{}, to...)
+	sort.Strings(recipients)
+
 	h := sha256.New()
-	_, _ = h.Write([]byte(message.AddressID + message.Subject))
-	if message.Sender != nil {
-		_, _ = h.Write([]byte(message.Sender.Address))
-	}
-	for _, to := range message.ToList {
-		_, _ = h.Write([]byte(to.Address))
-	}
-	for _, to := range message.CCList {
-		_, _ = h.Write([]byte(to.Address))
-	}
-	for _, to := range message.BCCList {
-		_, _ = h.Write([]byte(to.Address))
-	}
-	_, _ = h.Write([]byte(message.Body))
-	for _, att := range message.Attachments {
-		_, _ = h.Write([]byte(att.Name + att.MIMEType + fmt.Sprintf("%d", att.Size)))
+	_, _ = h.Write([]byte(addressID))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(externalID))
+	_, _ = h.Write([]byte{0})
+	for _, recipient := range recipients {
+		_, _ = h.Write([]byte(recipient))
+		_, _ = h.Write([]byte{0})
 	}
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write(getRawBody(raw))
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
-func (q *sendRecorder) addMessage(hash, messageID string) {
+// getRawBody returns the part of the raw message after the header.
+func getRawBody(raw []byte) []byte {
+	for _, sep := range [][]byte{[]byte("\r\n\r\n"), []byte("\n\n")} {
+		if i := bytes.Index(raw, sep); i >= 0 {
+			return raw[i+len(sep):]
+		}
+	}
+	return raw
+}
+
+func (q *sendRecorder) addMessage(storer sendRecordStorer, hash, messageID string) {
+	if hash == "" {
+		return
+	}
+
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	q.deleteExpiredKeys()
-	q.hashes[hash] = sendRecorderValue{
-		messageID: messageID,
-		time:      time.Now(),
+	q.deleteExpiredKeys(storer)
+	if err := storer.AddSendRecord(hash, messageID); err != nil {
+		log.WithError(err).Warn("Cannot record sent message")
 	}
 }
 
-func (q *sendRecorder) isSendingOrSent(client messageGetter, hash string) (isSending bool, wasSent bool) {
+func (q *sendRecorder) isSendingOrSent(storer sendRecordStorer, client messageGetter, hash string) (isSending bool, wasSent bool) {
+	if hash == "" {
+		return
+	}
+
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	q.deleteExpiredKeys()
-	value, ok := q.hashes[hash]
-	if !ok {
+	q.deleteExpiredKeys(storer)
+	messageID, _, err := storer.GetSendRecord(hash)
+	if err != nil {
+		log.WithError(err).Warn("Cannot read send record")
 		return
 	}
-	message, err := client.GetMessage(value.messageID)
+	if messageID == "" {
+		return
+	}
+	// Draft scheduled to be sent later was already accepted.
+	if isScheduled, err := storer.IsScheduledSend(messageID); err == nil && isScheduled {
+		wasSent = true
+		return
+	}
+	message, err := client.GetMessage(messageID)
 	// Message could be deleted or there could be an internet issue or whatever,
 	// so let's assume the message was not sent.
 	if err != nil {
@@ -110,15 +152,8 @@ func (q *sendRecorder) isSendingOrSent(client messageGetter, hash string) (isSen
 	return
 }
 
-func (q *sendRecorder) deleteExpiredKeys() {
-	for key, value := range q.hashes {
-		// It's hard to find a good expiration time.
-		// On the one hand, a user could set up some cron job sending the same message over and over again (heartbeat).
-		// On the the other, a user could put the device into sleep mode while sending.
-		// Changing the expiration time will always make one of the edge cases worse.
-		// But both edge cases are something we don't care much about. Important thing is we don't send the same message many times.
-		if time.Since(value.time) > 30*time.Minute {
-			delete(q.hashes, key)
-		}
+func (q *sendRecorder) deleteExpiredKeys(storer sendRecordStorer) {
+	if err := storer.DeleteSendRecordsBefore(time.Now().Add(-sendRecordExpiration)); err != nil {
+		log.WithError(err).Warn("Cannot delete expired send records")
 	}
 }
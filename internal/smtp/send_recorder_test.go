@@ -20,7 +20,6 @@ package smtp
 import (
 	"errors"
 	"fmt"
-	"net/mail"
 	"testing"
 	"time"
 
@@ -37,323 +36,72 @@ func (m *testSendRecorderGetMessageMock) GetMessage(messageID string) (*pmapi.Me
 	return m.message, m.err
 }
 
-func TestSendRecorder_getMessageHash(t *testing.T) {
-	q := newSendRecorder()
+type testSendRecord struct {
+	messageID string
+	time      time.Time
+}
 
-	message := &pmapi.Message{
-		AddressID: "address123",
-		Subject:   "Subject #1",
-		Sender: &mail.Address{
-			Address: "from@pm.me",
-		},
-		ToList: []*mail.Address{
-			{Address: "to@pm.me"},
-		},
-		CCList:  []*mail.Address{},
-		BCCList: []*mail.Address{},
-		Body:    "body",
-		Attachments: []*pmapi.Attachment{
-			{
-				Name:     "att1",
-				MIMEType: "image/png",
-				Size:     12345,
-			},
-		},
+type testSendRecordStorer struct {
+	records   map[string]testSendRecord
+	scheduled map[string]bool
+}
+
+func newTestSendRecordStorer() *testSendRecordStorer {
+	return &testSendRecordStorer{
+		records:   map[string]testSendRecord{},
+		scheduled: map[string]bool{},
 	}
-	hash := q.getMessageHash(message)
+}
+
+func (s *testSendRecordStorer) IsScheduledSend(messageID string) (bool, error) {
+	return s.scheduled[messageID], nil
+}
+
+func (s *testSendRecordStorer) GetSendRecord(hash string) (string, time.Time, error) {
+	record := s.records[hash]
+	return record.messageID, record.time, nil
+}
+
+func (s *testSendRecordStorer) AddSendRecord(hash, messageID string) error {
+	s.records[hash] = testSendRecord{messageID: messageID, time: time.Now()}
+	return nil
+}
+
+func (s *testSendRecordStorer) DeleteSendRecordsBefore(before time.Time) error {
+	for hash, record := range s.records {
+		if record.time.Before(before) {
+			delete(s.records, hash)
+		}
+	}
+	return nil
+}
+
+func TestSendRecorder_getMessageHash(t *testing.T) {
+	raw := []byte("Message-Id: <id@pm.me>\r\nDate: Mon, 1 Jun 2020 10:00:00 +0000\r\nSubject: Subject #1\r\n\r\nbody\r\n")
+	to := []string{"a@pm.me", "b@pm.me"}
+	hash := getMessageHash("address123", "id@pm.me", to, raw)
 
 	testCases := []struct {
-		message     *pmapi.Message
+		name        string
+		addressID   string
+		externalID  string
+		to          []string
+		raw         []byte
 		expectEqual bool
 	}{
-		{
-			message,
-			true,
-		},
-		{
-			&pmapi.Message{},
-			false,
-		},
-		{ // Different AddressID
-			&pmapi.Message{
-				AddressID: "...",
-				Subject:   "Subject #1",
-				Sender: &mail.Address{
-					Address: "from@pm.me",
-				},
-				ToList: []*mail.Address{
-					{Address: "to@pm.me"},
-				},
-				CCList:  []*mail.Address{},
-				BCCList: []*mail.Address{},
-				Body:    "body",
-				Attachments: []*pmapi.Attachment{
-					{
-						Name:     "att1",
-						MIMEType: "image/png",
-						Size:     12345,
-					},
-				},
-			},
-			false,
-		},
-		{ // Different subject
-			&pmapi.Message{
-				AddressID: "address123",
-				Subject:   "Subject #1.",
-				Sender: &mail.Address{
-					Address: "from@pm.me",
-				},
-				ToList: []*mail.Address{
-					{Address: "to@pm.me"},
-				},
-				CCList:  []*mail.Address{},
-				BCCList: []*mail.Address{},
-				Body:    "body",
-				Attachments: []*pmapi.Attachment{
-					{
-						Name:     "att1",
-						MIMEType: "image/png",
-						Size:     12345,
-					},
-				},
-			},
-			false,
-		},
-		{ // Different sender
-			&pmapi.Message{
-				AddressID: "address123",
-				Subject:   "Subject #1",
-				Sender: &mail.Address{
-					Address: "sender@pm.me",
-				},
-				ToList: []*mail.Address{
-					{Address: "to@pm.me"},
-				},
-				CCList:  []*mail.Address{},
-				BCCList: []*mail.Address{},
-				Body:    "body",
-				Attachments: []*pmapi.Attachment{
-					{
-						Name:     "att1",
-						MIMEType: "image/png",
-						Size:     12345,
-					},
-				},
-			},
-			false,
-		},
-		{ // Different ToList - changed address
-			&pmapi.Message{
-				AddressID: "address123",
-				Subject:   "Subject #1",
-				Sender: &mail.Address{
-					Address: "from@pm.me",
-				},
-				ToList: []*mail.Address{
-					{Address: "other@pm.me"},
-				},
-				CCList:  []*mail.Address{},
-				BCCList: []*mail.Address{},
-				Body:    "body",
-				Attachments: []*pmapi.Attachment{
-					{
-						Name:     "att1",
-						MIMEType: "image/png",
-						Size:     12345,
-					},
-				},
-			},
-			false,
-		},
-		{ // Different ToList - more addresses
-			&pmapi.Message{
-				AddressID: "address123",
-				Subject:   "Subject #1",
-				Sender: &mail.Address{
-					Address: "from@pm.me",
-				},
-				ToList: []*mail.Address{
-					{Address: "to@pm.me"},
-					{Address: "another@pm.me"},
-				},
-				CCList:  []*mail.Address{},
-				BCCList: []*mail.Address{},
-				Body:    "body",
-				Attachments: []*pmapi.Attachment{
-					{
-						Name:     "att1",
-						MIMEType: "image/png",
-						Size:     12345,
-					},
-				},
-			},
-			false,
-		},
-		{ // Different CCList
-			&pmapi.Message{
-				AddressID: "address123",
-				Subject:   "Subject #1",
-				Sender: &mail.Address{
-					Address: "from@pm.me",
-				},
-				ToList: []*mail.Address{
-					{Address: "to@pm.me"},
-				},
-				CCList: []*mail.Address{
-					{Address: "to@pm.me"},
-				},
-				BCCList: []*mail.Address{},
-				Body:    "body",
-				Attachments: []*pmapi.Attachment{
-					{
-						Name:     "att1",
-						MIMEType: "image/png",
-						Size:     12345,
-					},
-				},
-			},
-			false,
-		},
-		{ // Different BCCList
-			&pmapi.Message{
-				AddressID: "address123",
-				Subject:   "Subject #1",
-				Sender: &mail.Address{
-					Address: "from@pm.me",
-				},
-				ToList: []*mail.Address{
-					{Address: "to@pm.me"},
-				},
-				CCList: []*mail.Address{},
-				BCCList: []*mail.Address{
-					{Address: "to@pm.me"},
-				},
-				Body: "body",
-				Attachments: []*pmapi.Attachment{
-					{
-						Name:     "att1",
-						MIMEType: "image/png",
-						Size:     12345,
-					},
-				},
-			},
-			false,
-		},
-		{ // Different body
-			&pmapi.Message{
-				AddressID: "address123",
-				Subject:   "Subject #1",
-				Sender: &mail.Address{
-					Address: "from@pm.me",
-				},
-				ToList: []*mail.Address{
-					{Address: "to@pm.me"},
-				},
-				CCList:  []*mail.Address{},
-				BCCList: []*mail.Address{},
-				Body:    "body.",
-				Attachments: []*pmapi.Attachment{
-					{
-						Name:     "att1",
-						MIMEType: "image/png",
-						Size:     12345,
-					},
-				},
-			},
-			false,
-		},
-		{ // Different attachment - no attachment
-			&pmapi.Message{
-				AddressID: "address123",
-				Subject:   "Subject #1",
-				Sender: &mail.Address{
-					Address: "from@pm.me",
-				},
-				ToList: []*mail.Address{
-					{Address: "to@pm.me"},
-				},
-				CCList:      []*mail.Address{},
-				BCCList:     []*mail.Address{},
-				Body:        "body",
-				Attachments: []*pmapi.Attachment{},
-			},
-			false,
-		},
-		{ // Different attachment - name
-			&pmapi.Message{
-				AddressID: "address123",
-				Subject:   "Subject #1",
-				Sender: &mail.Address{
-					Address: "from@pm.me",
-				},
-				ToList: []*mail.Address{
-					{Address: "to@pm.me"},
-				},
-				CCList:  []*mail.Address{},
-				BCCList: []*mail.Address{},
-				Body:    "body",
-				Attachments: []*pmapi.Attachment{
-					{
-						Name:     "...",
-						MIMEType: "image/png",
-						Size:     12345,
-					},
-				},
-			},
-			false,
-		},
-		{ // Different attachment - MIMEType
-			&pmapi.Message{
-				AddressID: "address123",
-				Subject:   "Subject #1",
-				Sender: &mail.Address{
-					Address: "from@pm.me",
-				},
-				ToList: []*mail.Address{
-					{Address: "to@pm.me"},
-				},
-				CCList:  []*mail.Address{},
-				BCCList: []*mail.Address{},
-				Body:    "body",
-				Attachments: []*pmapi.Attachment{
-					{
-						Name:     "att1",
-						MIMEType: "image/jpeg",
-						Size:     12345,
-					},
-				},
-			},
-			false,
-		},
-		{ // Different attachment - Size
-			&pmapi.Message{
-				AddressID: "address123",
-				Subject:   "Subject #1",
-				Sender: &mail.Address{
-					Address: "from@pm.me",
-				},
-				ToList: []*mail.Address{
-					{Address: "to@pm.me"},
-				},
-				CCList:  []*mail.Address{},
-				BCCList: []*mail.Address{},
-				Body:    "body",
-				Attachments: []*pmapi.Attachment{
-					{
-						Name:     "att1",
-						MIMEType: "image/png",
-						Size:     42,
-					},
-				},
-			},
-			false,
-		},
+		{"same", "address123", "id@pm.me", to, raw, true},
+		{"different header", "address123", "id@pm.me", to, []byte("Message-Id: <id@pm.me>\r\nDate: Mon, 1 Jun 2020 10:01:00 +0000\r\nSubject: Subject #1\r\n\r\nbody\r\n"), true},
+		{"different recipient order", "address123", "id@pm.me", []string{"b@pm.me", "a@pm.me"}, raw, true},
+		{"different address", "...", "id@pm.me", to, raw, false},
+		{"different Message-ID", "address123", "other@pm.me", to, raw, false},
+		{"different recipients", "address123", "id@pm.me", []string{"bcc@pm.me"}, raw, false},
+		{"different body", "address123", "id@pm.me", to, []byte("Message-Id: <id@pm.me>\r\nSubject: Subject #1\r\n\r\nbody.\r\n"), false},
+		{"no Message-ID", "address123", "", to, raw, false},
 	}
-	for i, tc := range testCases {
+	for _, tc := range testCases {
 		tc := tc // bind
-		t.Run(fmt.Sprintf("%d / %v", i, tc.message), func(t *testing.T) {
-			newHash := q.getMessageHash(tc.message)
+		t.Run(tc.name, func(t *testing.T) {
+			newHash := getMessageHash(tc.addressID, tc.externalID, tc.to, tc.raw)
 			if tc.expectEqual {
 				assert.Equal(t, hash, newHash)
 			} else {
@@ -361,11 +109,18 @@ func TestSendRecorder_getMessageHash(t *testing.T) {
 			}
 		})
 	}
+
+	// Messages without Message-ID are identified by body and recipients.
+	noIDHash := getMessageHash("address123", "", to, raw)
+	assert.NotEqual(t, "", noIDHash)
+	assert.Equal(t, noIDHash, getMessageHash("address123", "", to, raw))
+	assert.NotEqual(t, noIDHash, getMessageHash("address123", "", []string{"bcc@pm.me"}, raw))
 }
 
 func TestSendRecorder_isSendingOrSent(t *testing.T) {
 	q := newSendRecorder()
-	q.addMessage("hash", "messageID")
+	storer := newTestSendRecordStorer()
+	q.addMessage(storer, "hash", "messageID")
 
 	testCases := []struct {
 		hash          string
@@ -374,7 +129,8 @@ func TestSendRecorder_isSendingOrSent(t *testing.T) {
 		wantIsSending bool
 		wantWasSent   bool
 	}{
-		{"badhash", &pmapi.Message{Type: pmapi.MessageTypeDraft}, nil, false, false},
+		{"badhash", &pmapi.Message{Type: pmapi.MessageTypeDraft, Time: time.Now().Unix()}, nil, false, false},
+		{"", &pmapi.Message{Type: pmapi.MessageTypeDraft, Time: time.Now().Unix()}, nil, false, false},
 		{"hash", nil, errors.New("message not found"), false, false},
 		{"hash", &pmapi.Message{Type: pmapi.MessageTypeInbox}, nil, false, false},
 		{"hash", &pmapi.Message{Type: pmapi.MessageTypeDraft, Time: time.Now().Add(-20 * time.Minute).Unix()}, nil, false, false},
@@ -386,29 +142,42 @@ func TestSendRecorder_isSendingOrSent(t *testing.T) {
 		tc := tc // bind
 		t.Run(fmt.Sprintf("%d / %v / %v / %v", i, tc.hash, tc.message, tc.err), func(t *testing.T) {
 			messageGetter := &testSendRecorderGetMessageMock{message: tc.message, err: tc.err}
-			isSending, wasSent := q.isSendingOrSent(messageGetter, "hash")
+			isSending, wasSent := q.isSendingOrSent(storer, messageGetter, tc.hash)
 			assert.Equal(t, tc.wantIsSending, isSending, "isSending does not match")
 			assert.Equal(t, tc.wantWasSent, wasSent, "wasSent does not match")
 		})
 	}
 }
 
+func TestSendRecorder_isSendingOrSentScheduled(t *testing.T) {
+	q := newSendRecorder()
+	storer := newTestSendRecordStorer()
+	q.addMessage(storer, "hash", "messageID")
+	storer.scheduled["messageID"] = true
+
+	messageGetter := &testSendRecorderGetMessageMock{message: &pmapi.Message{Type: pmapi.MessageTypeDraft, Time: time.Now().Unix()}}
+	isSending, wasSent := q.isSendingOrSent(storer, messageGetter, "hash")
+	assert.False(t, isSending)
+	assert.True(t, wasSent)
+}
+
 func TestSendRecorder_deleteExpiredKeys(t *testing.T) {
 	q := newSendRecorder()
+	storer := newTestSendRecordStorer()
 
-	q.hashes["hash1"] = sendRecorderValue{
+	storer.records["hash1"] = testSendRecord{
 		messageID: "msg1",
 		time:      time.Now(),
 	}
-	q.hashes["hash2"] = sendRecorderValue{
+	storer.records["hash2"] = testSendRecord{
 		messageID: "msg2",
 		time:      time.Now().Add(-31 * time.Minute),
 	}
 
-	q.deleteExpiredKeys()
+	q.deleteExpiredKeys(storer)
 
-	_, ok := q.hashes["hash1"]
+	_, ok := storer.records["hash1"]
 	assert.True(t, ok)
-	_, ok = q.hashes["hash2"]
+	_, ok = storer.records["hash2"]
 	assert.False(t, ok)
 }
@@ -0,0 +1,60 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package smtp
+
+import (
+	"strings"
+
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/pkg/errors"
+)
+
+// getSenderAddress returns the owned address used to send messages from
+// `from`. Besides owned addresses, `from` can be an alias configured by the
+// user or a plus address (user+tag@pm.me) of any of them. Keys of the returned
+// address are used to sign and encrypt the message.
+func getSenderAddress(addresses pmapi.AddressList, aliases map[string]string, from string) *pmapi.Address {
+	// ByEmail ignores the plus part already.
+	if addr := addresses.ByEmail(from); addr != nil {
+		return addr
+	}
+
+	from = strings.ToLower(from)
+	if owned, ok := aliases[from]; ok {
+		return addresses.ByEmail(owned)
+	}
+	if owned, ok := aliases[pmapi.SanitizeEmail(from)]; ok {
+		return addresses.ByEmail(owned)
+	}
+
+	return nil
+}
+
+func (su *smtpUser) getSenderAddress(from string) (*pmapi.Address, error) {
+	aliases, err := su.storeUser.GetSenderAliases()
+	if err != nil {
+		return nil, err
+	}
+
+	addr := getSenderAddress(su.client().Addresses(), aliases, from)
+	if addr == nil {
+		return nil, errors.New("backend: invalid email address: not owned by user")
+	}
+
+	return addr, nil
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package smtp
+
+import (
+	"testing"
+
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSenderAddress(t *testing.T) {
+	addresses := pmapi.AddressList{
+		{ID: "addr1", Email: "user@pm.me"},
+		{ID: "addr2", Email: "Other@pm.me"},
+	}
+	aliases := map[string]string{
+		"me@example.com": "other@pm.me",
+	}
+
+	testCases := []struct {
+		from   string
+		wantID string
+	}{
+		{from: "user@pm.me", wantID: "addr1"},
+		{from: "USER@pm.me", wantID: "addr1"},
+		{from: "user+newsletter@pm.me", wantID: "addr1"},
+		{from: "other+a+b@pm.me", wantID: "addr2"},
+		{from: "Me@Example.com", wantID: "addr2"},
+		{from: "me+tag@example.com", wantID: "addr2"},
+		{from: "unknown@pm.me"},
+		{from: "unknown+user@pm.me"},
+	}
+
+	for _, tc := range testCases {
+		addr := getSenderAddress(addresses, aliases, tc.from)
+		if tc.wantID == "" {
+			assert.Nil(t, addr, tc.from)
+			continue
+		}
+		if assert.NotNil(t, addr, tc.from) {
+			assert.Equal(t, tc.wantID, addr.ID, tc.from)
+		}
+	}
+}
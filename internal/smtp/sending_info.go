@@ -32,12 +32,24 @@ const (
 	pgpMime   = "pgp-mime"
 )
 
+// Key sources tell where the public key of the recipient comes from.
+const (
+	KeySourceNone     = "none"
+	KeySourceContact  = "contact"
+	KeySourceWKD      = "wkd"
+	KeySourceInternal = "internal"
+)
+
 type SendingInfo struct {
 	Encrypt   bool
 	Sign      bool
 	Scheme    int
 	MIMEType  string
 	PublicKey *crypto.KeyRing
+	KeySource string
+
+	// Internal is true when the recipient has a ProtonMail account.
+	Internal bool
 }
 
 func generateSendingInfo(
@@ -59,6 +71,7 @@ func generateSendingInfo(
 	} else {
 		sendingInfo, err = generateExternalSendingInfo(contactMeta, composeMode, apiKeys, contactKeys, settingsSign, settingsPgpScheme)
 	}
+	sendingInfo.Internal = isInternal
 
 	if (sendingInfo.Scheme == pmapi.PGPInlinePackage || sendingInfo.Scheme == pmapi.PGPMIMEPackage) && sendingInfo.PublicKey == nil {
 		return sendingInfo, errors.New("public key nil during attempt to encrypt")
@@ -88,6 +101,7 @@ func generateInternalSendingInfo(
 		Scheme:    pmapi.InternalPackage,
 		MIMEType:  composeMode,
 		PublicKey: apiKeys[0],
+		KeySource: KeySourceInternal,
 	}
 
 	// If there is no saved contact, our work here is done.
@@ -111,6 +125,7 @@ func generateInternalSendingInfo(
 
 	if len(checkedContactKeys) > 0 {
 		sendingInfo.PublicKey = checkedContactKeys[0]
+		sendingInfo.KeySource = KeySourceContact
 	}
 
 	// If contact has a saved mime type preference, prefer that over the default.
@@ -133,16 +148,19 @@ func generateExternalSendingInfo(
 		Encrypt:   false,
 		Sign:      settingsSign,
 		PublicKey: nil,
+		KeySource: KeySourceNone,
 	}
 
 	if contactMeta != nil && len(contactKeys) > 0 {
 		// If the contact has a key, use it. And if the contact metadata says to encryt, do so.
 		sendingInfo.PublicKey = contactKeys[0]
 		sendingInfo.Encrypt = contactMeta.Encrypt
+		sendingInfo.KeySource = KeySourceContact
 	} else if len(apiKeys) > 0 {
 		// If the api returned a key (via WKD), use it. In this case we always encrypt.
 		sendingInfo.PublicKey = apiKeys[0]
 		sendingInfo.Encrypt = true
+		sendingInfo.KeySource = KeySourceWKD
 	}
 
 	// - If we are encrypting, we always sign
@@ -73,6 +73,8 @@ func (tt *testData) runTest(t *testing.T) {
 			assert.Equal(t, gotSendingInfo.Sign, tt.wantSendingInfo.Sign)
 			assert.Equal(t, gotSendingInfo.Scheme, tt.wantSendingInfo.Scheme)
 			assert.Equal(t, gotSendingInfo.MIMEType, tt.wantSendingInfo.MIMEType)
+			assert.Equal(t, gotSendingInfo.KeySource, tt.wantSendingInfo.KeySource)
+			assert.Equal(t, gotSendingInfo.Internal, tt.args.isInternal)
 			assert.True(t, keyRingsAreEqual(gotSendingInfo.PublicKey, tt.wantSendingInfo.PublicKey))
 		}
 	})
@@ -142,6 +144,7 @@ func TestGenerateSendingInfo_WithoutContact(t *testing.T) {
 				Scheme:    pmapi.InternalPackage,
 				MIMEType:  pmapi.ContentTypeHTML,
 				PublicKey: pubKey,
+				KeySource: KeySourceInternal,
 			},
 		},
 		{
@@ -161,6 +164,7 @@ func TestGenerateSendingInfo_WithoutContact(t *testing.T) {
 				Scheme:    pmapi.InternalPackage,
 				MIMEType:  pmapi.ContentTypeHTML,
 				PublicKey: pubKey,
+				KeySource: KeySourceInternal,
 			},
 		},
 		{
@@ -180,6 +184,7 @@ func TestGenerateSendingInfo_WithoutContact(t *testing.T) {
 				Scheme:    pmapi.ClearMIMEPackage,
 				MIMEType:  pmapi.ContentTypeMultipartMixed,
 				PublicKey: nil,
+				KeySource: KeySourceNone,
 			},
 		},
 		{
@@ -199,6 +204,7 @@ func TestGenerateSendingInfo_WithoutContact(t *testing.T) {
 				Scheme:    pmapi.ClearPackage,
 				MIMEType:  pmapi.ContentTypePlainText,
 				PublicKey: nil,
+				KeySource: KeySourceNone,
 			},
 		},
 		{
@@ -218,6 +224,7 @@ func TestGenerateSendingInfo_WithoutContact(t *testing.T) {
 				Scheme:    pmapi.ClearPackage,
 				MIMEType:  pmapi.ContentTypeHTML,
 				PublicKey: nil,
+				KeySource: KeySourceNone,
 			},
 		},
 		{
@@ -249,6 +256,7 @@ func TestGenerateSendingInfo_WithoutContact(t *testing.T) {
 				Scheme:    pmapi.PGPMIMEPackage,
 				MIMEType:  pmapi.ContentTypeMultipartMixed,
 				PublicKey: pubKey,
+				KeySource: KeySourceWKD,
 			},
 		},
 	}
@@ -286,6 +294,7 @@ func TestGenerateSendingInfo_Contact_Internal(t *testing.T) {
 				Scheme:    pmapi.InternalPackage,
 				MIMEType:  pmapi.ContentTypeHTML,
 				PublicKey: pubKey,
+				KeySource: KeySourceInternal,
 			},
 		},
 		{
@@ -305,6 +314,7 @@ func TestGenerateSendingInfo_Contact_Internal(t *testing.T) {
 				Scheme:    pmapi.InternalPackage,
 				MIMEType:  pmapi.ContentTypeHTML,
 				PublicKey: pubKey,
+				KeySource: KeySourceContact,
 			},
 		},
 		{
@@ -324,6 +334,7 @@ func TestGenerateSendingInfo_Contact_Internal(t *testing.T) {
 				Scheme:    pmapi.InternalPackage,
 				MIMEType:  pmapi.ContentTypeHTML,
 				PublicKey: preferredPubKey,
+				KeySource: KeySourceContact,
 			},
 		},
 		{
@@ -358,6 +369,7 @@ func TestGenerateSendingInfo_Contact_Internal(t *testing.T) {
 				Scheme:    pmapi.PGPMIMEPackage,
 				MIMEType:  pmapi.ContentTypeMultipartMixed,
 				PublicKey: pubKey,
+				KeySource: KeySourceWKD,
 			},
 		},
 		{
@@ -377,6 +389,7 @@ func TestGenerateSendingInfo_Contact_Internal(t *testing.T) {
 				Scheme:    pmapi.PGPMIMEPackage,
 				MIMEType:  pmapi.ContentTypeMultipartMixed,
 				PublicKey: differentPubKey,
+				KeySource: KeySourceContact,
 			},
 		},
 	}
@@ -408,6 +421,7 @@ func TestGenerateSendingInfo_Contact_External(t *testing.T) {
 				Scheme:    pmapi.ClearPackage,
 				MIMEType:  pmapi.ContentTypeHTML,
 				PublicKey: nil,
+				KeySource: KeySourceNone,
 			},
 		},
 		{
@@ -427,6 +441,7 @@ func TestGenerateSendingInfo_Contact_External(t *testing.T) {
 				Scheme:    pmapi.ClearPackage,
 				MIMEType:  pmapi.ContentTypeHTML,
 				PublicKey: nil,
+				KeySource: KeySourceNone,
 			},
 		},
 		{
@@ -446,6 +461,7 @@ func TestGenerateSendingInfo_Contact_External(t *testing.T) {
 				Scheme:    pmapi.PGPMIMEPackage,
 				MIMEType:  pmapi.ContentTypeMultipartMixed,
 				PublicKey: pubKey,
+				KeySource: KeySourceContact,
 			},
 		},
 		{
@@ -465,6 +481,7 @@ func TestGenerateSendingInfo_Contact_External(t *testing.T) {
 				Scheme:    pmapi.PGPInlinePackage,
 				MIMEType:  pmapi.ContentTypePlainText,
 				PublicKey: pubKey,
+				KeySource: KeySourceContact,
 			},
 		},
 		{
@@ -484,6 +501,7 @@ func TestGenerateSendingInfo_Contact_External(t *testing.T) {
 				Scheme:    pmapi.PGPMIMEPackage,
 				MIMEType:  pmapi.ContentTypeMultipartMixed,
 				PublicKey: pubKey,
+				KeySource: KeySourceContact,
 			},
 		},
 		{
@@ -503,6 +521,7 @@ func TestGenerateSendingInfo_Contact_External(t *testing.T) {
 				Scheme:    pmapi.ClearPackage,
 				MIMEType:  pmapi.ContentTypeHTML,
 				PublicKey: nil,
+				KeySource: KeySourceNone,
 			},
 		},
 		{
@@ -522,6 +541,7 @@ func TestGenerateSendingInfo_Contact_External(t *testing.T) {
 				Scheme:    pmapi.ClearPackage,
 				MIMEType:  pmapi.ContentTypePlainText,
 				PublicKey: nil,
+				KeySource: KeySourceNone,
 			},
 		},
 		{
@@ -541,6 +561,7 @@ func TestGenerateSendingInfo_Contact_External(t *testing.T) {
 				Scheme:    pmapi.ClearPackage,
 				MIMEType:  pmapi.ContentTypePlainText,
 				PublicKey: nil,
+				KeySource: KeySourceNone,
 			},
 		},
 	}
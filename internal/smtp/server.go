@@ -36,12 +36,20 @@ type smtpServer struct {
 }
 
 // NewSMTPServer returns an SMTP server configured with the given options.
-func NewSMTPServer(debug bool, port int, useSSL bool, tls *tls.Config, smtpBackend goSMTP.Backend, eventListener listener.Listener) *smtpServer { //nolint[golint]
+func NewSMTPServer(debug bool, port int, useSSL bool, tls *tls.Config, smtpBackend *smtpBackend, eventListener listener.Listener) *smtpServer { //nolint[golint]
 	s := goSMTP.NewServer(smtpBackend)
 	s.Addr = fmt.Sprintf("%v:%v", bridge.Host, port)
 	s.TLSConfig = tls
 	s.Domain = bridge.Host
 	s.AllowInsecureAuth = true
+	// MaxMessageBytes is not set because the limit differs per user. It is
+	// checked by smtpUser.MaxMessageBytes against SIZE of the MAIL command and
+	// by smtpUser.SendEnvelope against the data; SIZE is advertised as extra
+	// capability.
+	s.ExtraCaps = func() []string {
+		caps := append([]string{"DSN"}, smtpBackend.getSizeCaps()...)
+		return append(caps, futureReleaseCaps()...)
+	}
 
 	if debug {
 		s.Debug = logrus.
@@ -71,6 +79,8 @@ func NewSMTPServer(debug bool, port int, useSSL bool, tls *tls.Config, smtpBacke
 // Starts the server.
 func (s *smtpServer) ListenAndServe() {
 	go s.monitorDisconnectedUsers()
+	go s.monitorRevokedAppPasswords()
+	go s.monitorRotatedBridgePasswords()
 	l := log.WithField("useSSL", s.useSSL).WithField("address", s.server.Addr)
 
 	l.Info("SMTP server is starting")
@@ -110,3 +120,47 @@ func (s *smtpServer) monitorDisconnectedUsers() {
 		s.server.ForEachConn(disconnectUser)
 	}
 }
+
+// monitorRevokedAppPasswords closes connections which logged in with
+// a revoked app password.
+func (s *smtpServer) monitorRevokedAppPasswords() {
+	ch := make(chan string)
+	s.eventListener.Add(events.RevokedAppPasswordEvent, ch)
+
+	for data := range ch {
+		appPassword, err := events.ParseAppPasswordEventData(data)
+		if err != nil {
+			log.WithError(err).Error("Cannot parse revoked app password")
+			continue
+		}
+
+		log.Info("Disconnecting SMTP connections of app password ", appPassword.Name)
+		s.server.ForEachConn(func(conn *goSMTP.Conn) {
+			if connUser, ok := conn.User().(*smtpUser); ok && connUser.isLoggedInWith(appPassword) {
+				_ = conn.Close()
+			}
+		})
+	}
+}
+
+// monitorRotatedBridgePasswords closes connections which logged in with
+// a rotated bridge password.
+func (s *smtpServer) monitorRotatedBridgePasswords() {
+	ch := make(chan string)
+	s.eventListener.Add(events.RotatedBridgePasswordEvent, ch)
+
+	for data := range ch {
+		rotated, err := events.ParseBridgePasswordEventData(data)
+		if err != nil {
+			log.WithError(err).Error("Cannot parse rotated bridge password")
+			continue
+		}
+
+		log.WithField("address", rotated.Address).Info("Disconnecting SMTP connections of rotated bridge password")
+		s.server.ForEachConn(func(conn *goSMTP.Conn) {
+			if connUser, ok := conn.User().(*smtpUser); ok && connUser.usedBridgePassword(rotated) {
+				_ = conn.Close()
+			}
+		})
+	}
+}
@@ -19,8 +19,10 @@ package smtp
 
 import (
 	"io"
+	"time"
 
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/ProtonMail/proton-bridge/internal/store"
 	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
 )
 
@@ -33,4 +35,15 @@ type storeUserProvider interface {
 		attachedPublicKeyName string,
 		parentID string) (*pmapi.Message, []*pmapi.Attachment, error)
 	SendMessage(messageID string, req *pmapi.SendMessageReq) error
+	GetMaxUpload() (uint, error)
+	GetSenderAliases() (map[string]string, error)
+	QueueOutgoing(msg *store.OutgoingMessage) (string, error)
+	ListOutgoing() ([]*store.OutgoingMessage, error)
+	CancelOutgoing(id string) error
+	RecordOutgoingFailure(id string, sendErr error, nextAttempt time.Time, permanent bool) error
+	ScheduleSend(send *store.ScheduledSend) error
+	ListScheduledSends() ([]*store.ScheduledSend, error)
+	CancelScheduledSend(messageID string) error
+	RecordScheduledSendFailure(messageID string, sendErr error, nextAttempt time.Time, permanent bool) error
+	sendRecordStorer
 }
@@ -20,8 +20,8 @@
 package smtp
 
 import (
+	"bytes"
 	"encoding/base64"
-	"fmt"
 	"io"
 	"math/rand"
 	"mime"
@@ -33,6 +33,7 @@ import (
 
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
 	"github.com/ProtonMail/proton-bridge/internal/events"
+	"github.com/ProtonMail/proton-bridge/internal/store"
 	"github.com/ProtonMail/proton-bridge/pkg/listener"
 	"github.com/ProtonMail/proton-bridge/pkg/message"
 	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
@@ -40,6 +41,8 @@ import (
 	"github.com/pkg/errors"
 )
 
+var errMessageIsSending = errors.New("message is sending") //nolint[gochecknoglobals]
+
 type smtpUser struct {
 	panicHandler  panicHandler
 	eventListener listener.Listener
@@ -47,6 +50,7 @@ type smtpUser struct {
 	user          bridgeUser
 	storeUser     storeUserProvider
 	addressID     string
+	appPassword   string
 }
 
 // newSMTPUser returns struct implementing go-smtp/session interface.
@@ -56,6 +60,7 @@ func newSMTPUser(
 	smtpBackend *smtpBackend,
 	user bridgeUser,
 	addressID string,
+	appPassword string,
 ) (goSMTPBackend.User, error) {
 	storeUser := user.GetStore()
 	if storeUser == nil {
@@ -69,38 +74,141 @@ func newSMTPUser(
 		user:          user,
 		storeUser:     storeUser,
 		addressID:     addressID,
+		appPassword:   appPassword,
 	}, nil
 }
 
+// isLoggedInWith returns whether the session logged in with the app password.
+func (su *smtpUser) isLoggedInWith(appPassword events.AppPasswordEventData) bool {
+	return su.appPassword != "" && su.appPassword == appPassword.Name && su.user.ID() == appPassword.UserID
+}
+
+// usedBridgePassword returns whether the session logged in with the rotated
+// bridge password.
+func (su *smtpUser) usedBridgePassword(rotated events.BridgePasswordEventData) bool {
+	if su.appPassword != "" || su.user.ID() != rotated.UserID {
+		return false
+	}
+	if rotated.Address == "" {
+		return true
+	}
+	addressID, err := su.user.GetAddressID(rotated.Address)
+	return err == nil && addressID == su.addressID
+}
+
 // This method should eventually no longer be necessary. Everything should go via store.
 func (su *smtpUser) client() pmapi.Client {
 	return su.user.GetTemporaryPMAPIClient()
 }
 
 // Send sends an email from the given address to the given addresses with the given body.
-func (su *smtpUser) Send(from string, to []string, messageReader io.Reader) (err error) { //nolint[funlen]
+func (su *smtpUser) Send(from string, to []string, messageReader io.Reader) error {
+	return su.SendEnvelope(&goSMTPBackend.Envelope{From: from, To: to}, messageReader)
+}
+
+// SendEnvelope sends the message with respect to the ESMTP parameters of the envelope.
+// When the API is not reachable or the same message is still being sent, the message
+// is queued and sent later (see outbox.go).
+func (su *smtpUser) SendEnvelope(smtpEnvelope *goSMTPBackend.Envelope, messageReader io.Reader) (err error) {
 	// Called from go-smtp in goroutines - we need to handle panics for each function.
 	defer su.panicHandler.HandlePanic()
 
+	maxBytes := su.getMaxMessageBytes()
+	body, err := readMessage(messageReader, maxBytes)
+	if err != nil {
+		log.WithError(err).WithField("limit", maxBytes).Warn("Message cannot be read")
+		return err
+	}
+
+	env, err := newEnvelope(smtpEnvelope, time.Now())
+	if err != nil {
+		log.WithError(err).Warn("Message envelope is not valid")
+		return err
+	}
+
+	if err = su.send(env, body); isRetryableError(err) {
+		log.WithError(err).Warn("Message cannot be sent now, queueing it")
+		err = su.queue(env, body)
+	}
+
+	if err != nil {
+		su.eventListener.Emit(events.SendFailedEvent, events.MessageEventData{
+			UserID:  su.user.ID(),
+			Address: env.from,
+			Error:   err.Error(),
+		}.String())
+	}
+
+	return err
+}
+
+// queue stores the message in the outbox to be sent when the API is reachable again.
+func (su *smtpUser) queue(env *envelope, body []byte) error {
+	addr, err := su.getSenderAddress(env.from)
+	if err != nil {
+		return err
+	}
+
+	id, err := su.storeUser.QueueOutgoing(&store.OutgoingMessage{
+		AddressID:     addr.ID,
+		From:          env.from,
+		To:            env.to,
+		NotifySuccess: env.notifySuccess,
+		NotifyFailure: env.notifyFailure,
+		SendAt:        env.releaseAt,
+		Body:          body,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to queue message")
+	}
+
+	log.WithField("id", id).Info("Message queued in outbox")
+	return nil
+}
+
+// send sends the message now or schedules it when the release time of the envelope
+// or the sendAtHeader is in the future. The envelope takes precedence over the header.
+func (su *smtpUser) send(env *envelope, body []byte) (err error) { //nolint[funlen]
+	from, to := env.from, env.to
+	notifySuccess, notifyFailure := env.notifySuccess, env.notifyFailure
+
+	arrival := time.Now()
+	sendAt, body, err := extractSendAt(body, arrival)
+	if err != nil {
+		return err
+	}
+	if env.releaseAt.After(arrival) {
+		sendAt = env.releaseAt
+	}
+
 	mailSettings, err := su.client().GetMailSettings()
 	if err != nil {
 		return err
 	}
 
-	var addr *pmapi.Address = su.client().Addresses().ByEmail(from)
-	if addr == nil {
-		err = errors.New("backend: invalid email address: not owned by user")
-		return
+	addr, err := su.getSenderAddress(from)
+	if err != nil {
+		return err
+	}
+
+	policyRules, err := su.backend.policy.getRules()
+	if err != nil {
+		return newPolicySMTPError(err)
 	}
+	senders := []string{from, addr.Email}
+	body = policyRules.stripHeaders(senders, body)
 
 	kr, err := su.client().KeyRingForAddressID(addr.ID)
 	if err != nil {
 		return
 	}
 
+	// Messages already encrypted or signed by the client are sent as they are.
+	pgpMIMEBody, clientEncrypted, isPGPMIME := getPGPMIMEBody(body)
+
 	var attachedPublicKey string
 	var attachedPublicKeyName string
-	if mailSettings.AttachPublicKey > 0 {
+	if mailSettings.AttachPublicKey > 0 && !isPGPMIME {
 		firstKey, err := kr.GetKey(0)
 		if err != nil {
 			return err
@@ -114,11 +222,14 @@ func (su *smtpUser) Send(from string, to []string, messageReader io.Reader) (err
 		attachedPublicKeyName = "publickey - " + kr.GetIdentities()[0].Name
 	}
 
-	message, mimeBody, plainBody, attReaders, err := message.Parse(messageReader, attachedPublicKey, attachedPublicKeyName)
+	message, mimeBody, plainBody, attReaders, err := message.Parse(bytes.NewReader(body), attachedPublicKey, attachedPublicKeyName)
 	if err != nil {
 		return
 	}
 	clearBody := message.Body
+	if isPGPMIME {
+		mimeBody = pgpMIMEBody
+	}
 
 	externalID := message.Header.Get("Message-Id")
 	externalID = strings.Trim(externalID, "<>")
@@ -141,32 +252,59 @@ func (su *smtpUser) Send(from string, to []string, messageReader io.Reader) (err
 	message.ExternalID = externalID
 
 	// If Outlook does not get a response quickly, it will try to send the message again, leading
-	// to sending the same message multiple times. In case we detect the same message is in the
-	// sending queue, we wait a minute to finish the first request. If the message is still being
-	// sent after the timeout, we return an error back to the client. The UX is not the best,
-	// but it's better than sending the message many times. If the message was sent, we simply return
-	// nil to indicate it's OK.
-	sendRecorderMessageHash := su.backend.sendRecorder.getMessageHash(message)
-	isSending, wasSent := su.backend.sendRecorder.isSendingOrSent(su.client(), sendRecorderMessageHash)
-	if isSending {
-		log.Debug("Message is in send queue, waiting")
-		time.Sleep(60 * time.Second)
-		isSending, wasSent = su.backend.sendRecorder.isSendingOrSent(su.client(), sendRecorderMessageHash)
-	}
+	// to sending the same message multiple times. The message is identified by Message-ID, recipients
+	// and its body. If the same message was already sent, we simply return nil to indicate it's OK. If it
+	// is still being sent, we do not block the client: errMessageIsSending makes Send queue the
+	// message to the outbox which sends it only when the first attempt did not succeed.
+	sendRecorderMessageHash := getMessageHash(addr.ID, externalID, to, body)
+	isSending, wasSent := su.backend.sendRecorder.isSendingOrSent(su.storeUser, su.client(), sendRecorderMessageHash)
 	if isSending {
-		log.Debug("Message is still in send queue, returning error")
-		return errors.New("message is sending")
+		log.Debug("Message is still in send queue")
+		return errMessageIsSending
 	}
 	if wasSent {
 		log.Debug("Message was already sent")
 		return nil
 	}
 
+	// PMEL 2.
+	settingsPgpScheme := mailSettings.PGPScheme
+	settingsSign := (mailSettings.Sign > 0)
+
+	// PMEL 3.
+	composeMode := message.MIMEType
+
+	containsUnencryptedRecipients := false
+
+	sendingInfos := make(map[string]SendingInfo)
+	for _, email := range to {
+		if !looksLikeEmail(email) {
+			return errors.New(`"` + email + `" is not a valid recipient.`)
+		}
+
+		sendingInfo, err := su.getSendingInfo(email, composeMode, settingsSign, settingsPgpScheme)
+		if err == nil && isPGPMIME {
+			sendingInfo = getPGPMIMESendingInfo(sendingInfo, clientEncrypted)
+		}
+		if !sendingInfo.Encrypt {
+			containsUnencryptedRecipients = true
+		}
+		if err != nil {
+			return errors.New("error sending to user " + email + ": " + err.Error())
+		}
+		sendingInfos[email] = sendingInfo
+	}
+
+	if err := policyRules.check(senders, to, sendingInfos); err != nil {
+		log.WithError(err).Warn("Message rejected by outgoing policy")
+		return newPolicySMTPError(err)
+	}
+
 	message, atts, err := su.storeUser.CreateDraft(kr, message, attReaders, attachedPublicKey, attachedPublicKeyName, parentID)
 	if err != nil {
 		return
 	}
-	su.backend.sendRecorder.addMessage(sendRecorderMessageHash, message.ID)
+	su.backend.sendRecorder.addMessage(su.storeUser, sendRecorderMessageHash, message.ID)
 
 	// We always have to create a new draft even if there already is one,
 	// because clients don't necessarily save the draft before sending, which
@@ -205,93 +343,11 @@ func (su *smtpUser) Send(from string, to []string, messageReader io.Reader) (err
 	htmlAddressMap := make(map[string]*pmapi.MessageAddress)
 	mimeAddressMap := make(map[string]*pmapi.MessageAddress)
 
-	// PMEL 2.
-	settingsPgpScheme := mailSettings.PGPScheme
-	settingsSign := (mailSettings.Sign > 0)
-
-	// PMEL 3.
-	composeMode := message.MIMEType
-
 	var plainKey, htmlKey, mimeKey *crypto.SessionKey
 	var plainData, htmlData, mimeData []byte
 
-	containsUnencryptedRecipients := false
-
 	for _, email := range to {
-		if !looksLikeEmail(email) {
-			return errors.New(`"` + email + `" is not a valid recipient.`)
-		}
-
-		// PMEL 1.
-		contactEmails, err := su.client().GetContactEmailByEmail(email, 0, 1000)
-		if err != nil {
-			return err
-		}
-		var contactMeta *ContactMetadata
-		var contactKeyRings []*crypto.KeyRing
-		for _, contactEmail := range contactEmails {
-			if contactEmail.Defaults == 1 { // WARNING: in doc it says _ignore for now, future feature_
-				continue
-			}
-			contact, err := su.client().GetContactByID(contactEmail.ContactID)
-			if err != nil {
-				return err
-			}
-			decryptedCards, err := su.client().DecryptAndVerifyCards(contact.Cards)
-			if err != nil {
-				return err
-			}
-			contactMeta, err = GetContactMetadataFromVCards(decryptedCards, email)
-			if err != nil {
-				return err
-			}
-			contactKeyRing, err := crypto.NewKeyRing(nil)
-			if err != nil {
-				return err
-			}
-			for _, contactRawKey := range contactMeta.Keys {
-				contactKey, err := crypto.NewKey([]byte(contactRawKey))
-				if err != nil {
-					return err
-				}
-				if err := contactKeyRing.AddKey(contactKey); err != nil {
-					return err
-				}
-				contactKeyRings = append(contactKeyRings, contactKeyRing)
-			}
-
-			break // We take the first hit where Defaults == 0, see "How to find the right contact" of PMEL
-		}
-
-		// PMEL 4.
-		apiRawKeyList, isInternal, err := su.client().GetPublicKeysForEmail(email)
-		if err != nil {
-			err = fmt.Errorf("backend: cannot get recipients' public keys: %v", err)
-			return err
-		}
-
-		var apiKeyRings []*crypto.KeyRing
-		for _, apiRawKey := range apiRawKeyList {
-			key, err := crypto.NewKeyFromArmored(apiRawKey.PublicKey)
-			if err != nil {
-				return err
-			}
-
-			kr, err := crypto.NewKeyRing(key)
-			if err != nil {
-				return err
-			}
-
-			apiKeyRings = append(apiKeyRings, kr)
-		}
-
-		sendingInfo, err := generateSendingInfo(su.eventListener, contactMeta, isInternal, composeMode, apiKeyRings, contactKeyRings, settingsSign, settingsPgpScheme)
-		if !sendingInfo.Encrypt {
-			containsUnencryptedRecipients = true
-		}
-		if err != nil {
-			return errors.New("error sending to user " + email + ": " + err.Error())
-		}
+		sendingInfo := sendingInfos[email]
 
 		var signature int
 		if sendingInfo.Sign {
@@ -301,7 +357,7 @@ func (su *smtpUser) Send(from string, to []string, messageReader io.Reader) (err
 		}
 		if sendingInfo.Scheme == pmapi.PGPMIMEPackage || sendingInfo.Scheme == pmapi.ClearMIMEPackage {
 			if mimeKey == nil {
-				if mimeKey, mimeData, err = encryptSymmetric(kr, mimeBody, true); err != nil {
+				if mimeKey, mimeData, err = encryptSymmetric(kr, mimeBody, !isPGPMIME, !isPGPMIME); err != nil {
 					return err
 				}
 			}
@@ -319,7 +375,7 @@ func (su *smtpUser) Send(from string, to []string, messageReader io.Reader) (err
 			switch sendingInfo.MIMEType {
 			case pmapi.ContentTypePlainText:
 				if plainKey == nil {
-					if plainKey, plainData, err = encryptSymmetric(kr, plainBody, true); err != nil {
+					if plainKey, plainData, err = encryptSymmetric(kr, plainBody, true, true); err != nil {
 						return err
 					}
 				}
@@ -334,7 +390,7 @@ func (su *smtpUser) Send(from string, to []string, messageReader io.Reader) (err
 				plainSharedScheme |= sendingInfo.Scheme
 			case pmapi.ContentTypeHTML:
 				if htmlKey == nil {
-					if htmlKey, htmlData, err = encryptSymmetric(kr, clearBody, true); err != nil {
+					if htmlKey, htmlData, err = encryptSymmetric(kr, clearBody, true, true); err != nil {
 						return err
 					}
 				}
@@ -388,7 +444,98 @@ func (su *smtpUser) Send(from string, to []string, messageReader io.Reader) (err
 		req.Packages = append(req.Packages, pkg)
 	}
 
-	return su.storeUser.SendMessage(message.ID, req)
+	if !sendAt.IsZero() {
+		return su.scheduleSend(&store.ScheduledSend{
+			ID:            message.ID,
+			AddressID:     addr.ID,
+			SendAt:        sendAt,
+			Subject:       message.Subject,
+			To:            to,
+			Request:       req,
+			NotifySuccess: notifySuccess,
+			NotifyFailure: notifyFailure,
+		})
+	}
+
+	if err = su.storeUser.SendMessage(message.ID, req); err != nil {
+		return err
+	}
+
+	su.backend.reportDelivery(su.client(), addr.ID, &deliveryReport{
+		subject:        message.Subject,
+		originalHeader: getRawHeader(body),
+		recipients:     notifySuccess,
+		arrival:        arrival,
+	})
+	return nil
+}
+
+// getSendingInfo looks up keys and preferences of the recipient `email` and
+// decides how the message should be sent to it.
+func (su *smtpUser) getSendingInfo(email, composeMode string, settingsSign bool, settingsPgpScheme int) (SendingInfo, error) {
+	// PMEL 1.
+	contactEmails, err := su.client().GetContactEmailByEmail(email, 0, 1000)
+	if err != nil {
+		return SendingInfo{}, err
+	}
+	var contactMeta *ContactMetadata
+	var contactKeyRings []*crypto.KeyRing
+	for _, contactEmail := range contactEmails {
+		if contactEmail.Defaults == 1 { // WARNING: in doc it says _ignore for now, future feature_
+			continue
+		}
+		contact, err := su.client().GetContactByID(contactEmail.ContactID)
+		if err != nil {
+			return SendingInfo{}, err
+		}
+		decryptedCards, err := su.client().DecryptAndVerifyCards(contact.Cards)
+		if err != nil {
+			return SendingInfo{}, err
+		}
+		contactMeta, err = GetContactMetadataFromVCards(decryptedCards, email)
+		if err != nil {
+			return SendingInfo{}, err
+		}
+		contactKeyRing, err := crypto.NewKeyRing(nil)
+		if err != nil {
+			return SendingInfo{}, err
+		}
+		for _, contactRawKey := range contactMeta.Keys {
+			contactKey, err := crypto.NewKey([]byte(contactRawKey))
+			if err != nil {
+				return SendingInfo{}, err
+			}
+			if err := contactKeyRing.AddKey(contactKey); err != nil {
+				return SendingInfo{}, err
+			}
+			contactKeyRings = append(contactKeyRings, contactKeyRing)
+		}
+
+		break // We take the first hit where Defaults == 0, see "How to find the right contact" of PMEL
+	}
+
+	// PMEL 4.
+	apiRawKeyList, isInternal, err := su.client().GetPublicKeysForEmail(email)
+	if err != nil {
+		return SendingInfo{}, errors.Wrap(err, "backend: cannot get recipients' public keys")
+	}
+
+	var apiKeyRings []*crypto.KeyRing
+	for _, apiRawKey := range apiRawKeyList {
+		key, err := crypto.NewKeyFromArmored(apiRawKey.PublicKey)
+		if err != nil {
+			return SendingInfo{}, err
+		}
+
+		kr, err := crypto.NewKeyRing(key)
+		if err != nil {
+			return SendingInfo{}, err
+		}
+
+		apiKeyRings = append(apiKeyRings, kr)
+	}
+
+	return generateSendingInfo(su.eventListener, contactMeta, isInternal, composeMode, apiKeyRings, contactKeyRings, settingsSign, settingsPgpScheme)
 }
 
 func (su *smtpUser) handleReferencesHeader(m *pmapi.Message) (draftID, parentID string) {
@@ -60,10 +60,14 @@ func createPackets(
 	return
 }
 
+// encryptSymmetric encrypts the text by a new session key. The data is signed
+// by `kr` only if `sign` is set, so the signature of the client made on
+// a PGP/MIME message is not wrapped by another one.
 func encryptSymmetric(
 	kr *crypto.KeyRing,
 	textToEncrypt string,
-	canonicalizeText bool, // nolint[unparam]
+	canonicalizeText bool,
+	sign bool,
 ) (key *crypto.SessionKey, symEncryptedData []byte, err error) {
 	// We use only primary key to encrypt the message. Our keyring contains all keys (primary, old and deacivated ones).
 	firstKey, err := kr.FirstKey()
@@ -71,7 +75,17 @@ func encryptSymmetric(
 		return
 	}
 
-	pgpMessage, err := firstKey.Encrypt(crypto.NewPlainMessageFromString(textToEncrypt), kr)
+	plainMessage := crypto.NewPlainMessage([]byte(textToEncrypt))
+	if canonicalizeText {
+		plainMessage = crypto.NewPlainMessageFromString(textToEncrypt)
+	}
+
+	var signKeyRing *crypto.KeyRing
+	if sign {
+		signKeyRing = kr
+	}
+
+	pgpMessage, err := firstKey.Encrypt(plainMessage, signKeyRing)
 	if err != nil {
 		return
 	}
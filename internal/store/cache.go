@@ -61,6 +61,13 @@ func (c *Cache) setEventID(userID, eventID string) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
+	// Cache might not be loaded yet when event ID is set before the store
+	// is opened (e.g. when the store is imported).
+	_ = c.loadCache()
+
+	if c.cache == nil {
+		c.cache = map[string]map[string]string{}
+	}
 	if c.cache[userID] == nil {
 		c.cache[userID] = map[string]string{}
 	}
@@ -19,6 +19,7 @@ package store
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 
 	bridgeEvents "github.com/ProtonMail/proton-bridge/internal/events"
@@ -31,6 +32,18 @@ import (
 const pollInterval = 30 * time.Second
 const pollIntervalSpread = 5 * time.Second
 
+// fastPollInterval is used while any IMAP client is in IDLE or shortly after
+// the message was sent, because the user is expecting updates right away.
+const fastPollInterval = 10 * time.Second
+
+// fastPollDuration is how long the fast polling is kept after activity.
+const fastPollDuration = 2 * time.Minute
+
+// idleBackoffAfter is the time without any change after which the polling
+// starts to back off, doubling the interval up to maxPollInterval.
+const idleBackoffAfter = 10 * time.Minute
+const maxPollInterval = 5 * time.Minute
+
 type eventLoop struct {
 	cache          *Cache
 	currentEventID string
@@ -43,6 +56,13 @@ type eventLoop struct {
 
 	pollCounter int
 
+	// pollLock guards the state used to decide the next poll interval.
+	pollLock      *sync.Mutex
+	idleSessions  int
+	fastPollUntil time.Time
+	lastChange    time.Time
+	idleBackoff   time.Duration
+
 	log *logrus.Entry
 
 	store  *Store
@@ -60,6 +80,9 @@ func newEventLoop(cache *Cache, store *Store, user BridgeUser, events listener.L
 		pollCh:         make(chan chan struct{}),
 		isRunning:      false,
 
+		pollLock:   &sync.Mutex{},
+		lastChange: time.Now(),
+
 		log: eventLog,
 
 		store:  store,
@@ -104,6 +127,103 @@ func (loop *eventLoop) pollNow() {
 	close(eventProcessedCh)
 }
 
+// wake starts polling right away without waiting for the result.
+// It does nothing when the loop is not running or stops before polling.
+func (loop *eventLoop) wake() {
+	if !loop.isRunning {
+		return
+	}
+
+	stopCh := loop.stopCh
+	go func() {
+		// The loop confirms processed events; nobody waits for it here,
+		// so the channel is buffered to not block the loop.
+		select {
+		case loop.pollCh <- make(chan struct{}, 1):
+		case <-stopCh:
+		}
+	}()
+}
+
+// idleStarted registers new IMAP IDLE session. The first session wakes
+// the loop up so the client gets fresh state even if the polling was
+// backed off.
+func (loop *eventLoop) idleStarted() {
+	loop.pollLock.Lock()
+	loop.idleSessions++
+	isFirst := loop.idleSessions == 1
+	loop.pollLock.Unlock()
+
+	if isFirst {
+		loop.wake()
+	}
+}
+
+// idleFinished unregisters IMAP IDLE session.
+func (loop *eventLoop) idleFinished() {
+	loop.pollLock.Lock()
+	defer loop.pollLock.Unlock()
+
+	if loop.idleSessions > 0 {
+		loop.idleSessions--
+	}
+}
+
+// speedUp makes the loop use fast polling for the next fastPollDuration.
+func (loop *eventLoop) speedUp() {
+	loop.pollLock.Lock()
+	defer loop.pollLock.Unlock()
+
+	loop.fastPollUntil = time.Now().Add(fastPollDuration)
+}
+
+// setChanged records that the last poll brought a new event.
+func (loop *eventLoop) setChanged() {
+	loop.pollLock.Lock()
+	defer loop.pollLock.Unlock()
+
+	loop.lastChange = time.Now()
+}
+
+// nextPollInterval returns how long to wait before the next poll. It is
+// fast when the user is active, standard pollInterval normally, and it
+// backs off exponentially up to maxPollInterval when nothing happened
+// for idleBackoffAfter.
+func (loop *eventLoop) nextPollInterval() time.Duration {
+	loop.pollLock.Lock()
+	defer loop.pollLock.Unlock()
+
+	now := time.Now()
+
+	if loop.idleSessions > 0 || now.Before(loop.fastPollUntil) {
+		loop.idleBackoff = 0
+		return fastPollInterval
+	}
+
+	if now.Sub(loop.lastChange) < idleBackoffAfter {
+		loop.idleBackoff = 0
+		return pollInterval
+	}
+
+	if loop.idleBackoff == 0 {
+		loop.idleBackoff = pollInterval
+	}
+	loop.idleBackoff *= 2
+	if loop.idleBackoff > maxPollInterval {
+		loop.idleBackoff = maxPollInterval
+	}
+
+	return loop.idleBackoff
+}
+
+// getPollSpread returns the range used to randomise the given interval.
+func getPollSpread(interval time.Duration) time.Duration {
+	if spread := interval / 2; spread < pollIntervalSpread {
+		return spread
+	}
+	return pollIntervalSpread
+}
+
 func (loop *eventLoop) stop() {
 	if loop.isRunning {
 		loop.isRunning = false
@@ -146,7 +266,8 @@ func (loop *eventLoop) start() {
 
 // loop is the main body of the event loop.
 func (loop *eventLoop) loop() {
-	t := time.NewTicker(pollInterval - pollIntervalSpread)
+	interval := loop.nextPollInterval()
+	t := time.NewTimer(interval - getPollSpread(interval))
 	defer t.Stop()
 
 	for {
@@ -156,8 +277,9 @@ func (loop *eventLoop) loop() {
 			close(loop.notifyStopCh)
 			return
 		case <-t.C:
-			// Randomise periodic calls within range pollInterval ± pollSpread to reduces potential load spikes on API.
-			time.Sleep(time.Duration(rand.Intn(2*int(pollIntervalSpread.Milliseconds()))) * time.Millisecond)
+			// Randomise periodic calls within range interval ± spread to reduces potential load spikes on API.
+			spread := getPollSpread(interval)
+			time.Sleep(time.Duration(rand.Intn(2*int(spread.Milliseconds())+1)) * time.Millisecond)
 		case eventProcessedCh = <-loop.pollCh:
 			// We don't want to wait here. Polling should happen instantly.
 		}
@@ -197,6 +319,17 @@ func (loop *eventLoop) loop() {
 		if more {
 			go loop.pollNow()
 		}
+
+		// The interval can change after every poll; the timer is reset
+		// also after polls triggered manually to keep the regular spacing.
+		if !t.Stop() {
+			select {
+			case <-t.C:
+			default:
+			}
+		}
+		interval = loop.nextPollInterval()
+		t.Reset(interval - getPollSpread(interval))
 	}
 }
 
@@ -254,6 +387,9 @@ func (loop *eventLoop) processNextEvent() (more bool, err error) { // nolint[fun
 
 	var event *pmapi.Event
 	if event, err = loop.client().GetEvent(loop.currentEventID); err != nil {
+		if isInvalidEventIDError(err) {
+			return false, loop.recoverFromInvalidEventID(l, err)
+		}
 		return false, errors.Wrap(err, "failed to get event")
 	}
 
@@ -282,6 +418,7 @@ func (loop *eventLoop) processNextEvent() (more bool, err error) { // nolint[fun
 		// This allows the event loop to continue to function (unless the cache was broken
 		// and bridge stopped, in which case it will start from the old event ID anyway).
 		loop.currentEventID = event.EventID
+		loop.setChanged()
 		if err = loop.cache.setEventID(loop.user.ID(), event.EventID); err != nil {
 			return false, errors.Wrap(err, "failed to save event ID to cache")
 		}
@@ -290,13 +427,36 @@ func (loop *eventLoop) processNextEvent() (more bool, err error) { // nolint[fun
 	return event.More == 1, err
 }
 
+// isInvalidEventIDError returns whether the API refused the event ID itself.
+// Other unprocessable entity errors must not drop the events in between.
+func isInvalidEventIDError(err error) bool {
+	apiErr, ok := errors.Cause(err).(*pmapi.ErrUnprocessableEntity)
+	return ok && (apiErr.Code == pmapi.InvalidID || apiErr.Code == pmapi.NotExists)
+}
+
+// recoverFromInvalidEventID is called when the API refuses the current event ID,
+// for example because it is too old. Events in between are lost, therefore the
+// loop continues from the latest event and the store is recovered by comparing
+// the local database with the API.
+func (loop *eventLoop) recoverFromInvalidEventID(l *logrus.Entry, eventErr error) error {
+	l.WithError(eventErr).Warn("Event ID is not valid anymore, recovering")
+
+	if err := loop.setFirstEventID(); err != nil {
+		return errors.Wrap(err, "failed to get latest event ID")
+	}
+
+	loop.store.triggerRecovery()
+
+	return nil
+}
+
 func (loop *eventLoop) processEvent(event *pmapi.Event) (err error) {
 	eventLog := loop.log.WithField("event", event.EventID)
 	eventLog.Debug("Processing event")
 
 	if (event.Refresh & pmapi.EventRefreshMail) != 0 {
 		eventLog.Info("Processing refresh event")
-		loop.store.triggerSync()
+		loop.store.triggerRecovery()
 
 		return
 	}
@@ -432,6 +592,10 @@ func (loop *eventLoop) processMessages(eventLog *logrus.Entry, messages []*pmapi
 				return errors.Wrap(err, "failed to put message into DB")
 			}
 
+			if message.Created.HasLabelID(pmapi.InboxLabel) {
+				loop.emitNewMessage(message.Created)
+			}
+
 		case pmapi.EventUpdate, pmapi.EventUpdateFlags:
 			msgLog.Debug("Processing EventUpdate(Flags) for message")
 
@@ -549,6 +713,21 @@ func updateMessage(msgLog *logrus.Entry, message *pmapi.Message, updates *pmapi.
 	}
 }
 
+func (loop *eventLoop) emitNewMessage(msg *pmapi.Message) {
+	data := bridgeEvents.MessageEventData{
+		UserID:    loop.user.ID(),
+		MessageID: msg.ID,
+		Subject:   msg.Subject,
+	}
+	if msg.Sender != nil {
+		data.Sender = msg.Sender.Address
+	}
+	if address := loop.client().Addresses().ByID(msg.AddressID); address != nil {
+		data.Address = address.Email
+	}
+	loop.events.Emit(bridgeEvents.NewMessageEvent, data.String())
+}
+
 func (loop *eventLoop) processMessageCounts(l *logrus.Entry, messageCounts []*pmapi.MessagesCount) error {
 	l.WithField("apiCounts", messageCounts).Debug("Processing message count change event")
 
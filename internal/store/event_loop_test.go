@@ -18,12 +18,16 @@
 package store
 
 import (
+	"net/http"
 	"net/mail"
+	"sync"
 	"testing"
 	"time"
 
+	bridgeEvents "github.com/ProtonMail/proton-bridge/internal/events"
 	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
 	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -108,6 +112,38 @@ func TestEventLoopUpdateMessageFromLoop(t *testing.T) {
 	}, time.Second, 10*time.Millisecond)
 }
 
+func TestEventLoopEmitsNewMessageInInbox(t *testing.T) {
+	m, clear := initMocks(t)
+	defer clear()
+
+	m.newStoreNoEvents(true)
+
+	m.client.EXPECT().Addresses().Return(pmapi.AddressList{{ID: addrID1, Email: addr1}}).AnyTimes()
+	m.events.EXPECT().Emit(bridgeEvents.NewMessageEvent, `{"userID":"userID","address":"`+addr1+`","messageID":"msg1","sender":"sender@example.com","subject":"Hello"}`)
+
+	err := m.store.eventLoop.processMessages(log, []*pmapi.EventMessage{
+		{
+			EventItem: pmapi.EventItem{ID: "msg1", Action: pmapi.EventCreate},
+			Created: &pmapi.Message{
+				ID:        "msg1",
+				AddressID: addrID1,
+				Subject:   "Hello",
+				Sender:    &mail.Address{Address: "sender@example.com"},
+				LabelIDs:  []string{pmapi.AllMailLabel, pmapi.InboxLabel},
+			},
+		},
+		{
+			EventItem: pmapi.EventItem{ID: "msg2", Action: pmapi.EventCreate},
+			Created: &pmapi.Message{
+				ID:        "msg2",
+				AddressID: addrID1,
+				LabelIDs:  []string{pmapi.AllMailLabel, pmapi.SentLabel},
+			},
+		},
+	})
+	require.NoError(t, err)
+}
+
 func TestEventLoopUpdateMessage(t *testing.T) {
 	address1 := &mail.Address{Address: "user1@example.com"}
 	address2 := &mail.Address{Address: "user2@example.com"}
@@ -151,3 +187,79 @@ func TestEventLoopUpdateMessage(t *testing.T) {
 
 	require.Equal(t, newMsg, msg)
 }
+
+func TestEventLoopNextPollInterval(t *testing.T) {
+	loop := &eventLoop{
+		pollLock:   &sync.Mutex{},
+		lastChange: time.Now(),
+	}
+
+	// Recently changed without any activity uses standard interval.
+	require.Equal(t, pollInterval, loop.nextPollInterval())
+
+	// IDLE session makes polling faster until it is finished.
+	loop.idleStarted()
+	require.Equal(t, fastPollInterval, loop.nextPollInterval())
+	loop.idleFinished()
+	require.Equal(t, pollInterval, loop.nextPollInterval())
+
+	// Sending a message makes polling faster for a while.
+	loop.speedUp()
+	require.Equal(t, fastPollInterval, loop.nextPollInterval())
+	loop.fastPollUntil = time.Now().Add(-time.Second)
+
+	// Nothing changed for long time, polling backs off up to maximum.
+	loop.lastChange = time.Now().Add(-idleBackoffAfter)
+	require.Equal(t, 2*pollInterval, loop.nextPollInterval())
+	require.Equal(t, 4*pollInterval, loop.nextPollInterval())
+	for i := 0; i < 10; i++ {
+		loop.nextPollInterval()
+	}
+	require.Equal(t, maxPollInterval, loop.nextPollInterval())
+
+	// Change resets the back off.
+	loop.setChanged()
+	require.Equal(t, pollInterval, loop.nextPollInterval())
+}
+
+func TestEventLoopWake(t *testing.T) {
+	loop := &eventLoop{
+		pollCh:    make(chan chan struct{}),
+		stopCh:    make(chan struct{}),
+		isRunning: true,
+	}
+
+	// Loop confirms the processed event without anybody waiting for it.
+	loop.wake()
+	select {
+	case eventProcessedCh := <-loop.pollCh:
+		eventProcessedCh <- struct{}{}
+	case <-time.After(time.Second):
+		require.Fail(t, "wake did not poll")
+	}
+
+	// Stopped loop does not receive the poll.
+	loop.wake()
+	close(loop.stopCh)
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-loop.pollCh:
+		require.Fail(t, "stopped loop was polled")
+	default:
+	}
+}
+
+func newUnprocessableEntityError(code int) error {
+	return pmapi.Res{
+		Code:       code,
+		StatusCode: http.StatusUnprocessableEntity,
+		ResError:   &pmapi.ResError{Error: "unprocessable"},
+	}.Err()
+}
+
+func TestIsInvalidEventIDError(t *testing.T) {
+	require.True(t, isInvalidEventIDError(newUnprocessableEntityError(pmapi.InvalidID)))
+	require.True(t, isInvalidEventIDError(errors.Wrap(newUnprocessableEntityError(pmapi.NotExists), "failed")))
+	require.False(t, isInvalidEventIDError(newUnprocessableEntityError(2001)))
+	require.False(t, isInvalidEventIDError(errors.New("no internet")))
+}
@@ -0,0 +1,278 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/mail"
+	"strconv"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// OutgoingMessage is a message accepted by SMTP which could not be sent yet,
+// typically because the API was not reachable.
+type OutgoingMessage struct {
+	ID        string
+	AddressID string
+	QueuedAt  time.Time
+
+	// Attempts is the number of failed delivery attempts and NextAttempt
+	// is the time after which the delivery should be tried again.
+	Attempts    int
+	NextAttempt time.Time
+	LastError   string
+
+	// Failed is set when the delivery failed for other reason than
+	// connection issues. Such message is not retried automatically.
+	Failed bool
+
+	// Fields below are stored encrypted by the address key.
+	From    string   `json:"-"`
+	To      []string `json:"-"`
+	Subject string   `json:"-"`
+	Body    []byte   `json:"-"`
+
+	// NotifySuccess and NotifyFailure are recipients which asked
+	// for delivery status notification (see RFC 3461).
+	NotifySuccess []string `json:"-"`
+	NotifyFailure []string `json:"-"`
+
+	// SendAt is the release time requested by the SMTP client.
+	// Zero time means the message should be sent as soon as possible.
+	SendAt time.Time `json:"-"`
+}
+
+// outboxRecord is the stored form of OutgoingMessage.
+type outboxRecord struct {
+	OutgoingMessage
+	Payload []byte
+}
+
+// outboxPayload is the encrypted part of outboxRecord.
+type outboxPayload struct {
+	From          string
+	To            []string
+	Body          []byte
+	NotifySuccess []string
+	NotifyFailure []string
+	SendAt        time.Time
+}
+
+// QueueOutgoing stores the message `msg` to be sent later. The envelope and
+// the body are encrypted by the key of the address `msg.AddressID`.
+// Bookkeeping fields of `msg` are ignored.
+func (store *Store) QueueOutgoing(msg *OutgoingMessage) (string, error) {
+	enc, err := store.encryptForAddress(msg.AddressID, outboxPayload{
+		From:          msg.From,
+		To:            msg.To,
+		Body:          msg.Body,
+		NotifySuccess: msg.NotifySuccess,
+		NotifyFailure: msg.NotifyFailure,
+		SendAt:        msg.SendAt,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encrypt outgoing message")
+	}
+
+	var id string
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(outboxBucket)
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		id = strconv.FormatUint(seq, 10)
+
+		now := time.Now()
+		return putOutboxRecord(b, &outboxRecord{
+			OutgoingMessage: OutgoingMessage{
+				ID:          id,
+				AddressID:   msg.AddressID,
+				QueuedAt:    now,
+				NextAttempt: now,
+			},
+			Payload: enc,
+		})
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to store outgoing message")
+	}
+
+	store.log.WithField("id", id).Info("Outgoing message queued")
+	return id, nil
+}
+
+// ListOutgoing returns all queued messages in the order they were queued.
+// Messages which cannot be decrypted (e.g. when the address key is not
+// unlocked) are returned without the encrypted fields.
+func (store *Store) ListOutgoing() (msgs []*OutgoingMessage, err error) {
+	var records []*outboxRecord
+	err = store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(outboxBucket).ForEach(func(k, v []byte) error {
+			record := &outboxRecord{}
+			if err := json.Unmarshal(v, record); err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read outbox")
+	}
+
+	for _, record := range records {
+		if err := store.decryptOutboxRecord(record); err != nil {
+			store.log.WithError(err).WithField("id", record.ID).Warn("Cannot decrypt outgoing message")
+		}
+		msg := record.OutgoingMessage
+		msgs = append(msgs, &msg)
+	}
+
+	return msgs, nil
+}
+
+// CancelOutgoing removes the message from the queue.
+func (store *Store) CancelOutgoing(id string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(outboxBucket)
+		key, err := getOutboxKey(id)
+		if err != nil {
+			return err
+		}
+		if b.Get(key) == nil {
+			return errors.New("no queued message with ID " + id)
+		}
+		return b.Delete(key)
+	})
+}
+
+// RecordOutgoingFailure stores the result of failed delivery attempt. When
+// the failure is `permanent`, the message is not retried automatically.
+func (store *Store) RecordOutgoingFailure(id string, sendErr error, nextAttempt time.Time, permanent bool) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(outboxBucket)
+		key, err := getOutboxKey(id)
+		if err != nil {
+			return err
+		}
+
+		raw := b.Get(key)
+		if raw == nil {
+			return errors.New("no queued message with ID " + id)
+		}
+
+		record := &outboxRecord{}
+		if err := json.Unmarshal(raw, record); err != nil {
+			return err
+		}
+
+		record.Attempts++
+		record.NextAttempt = nextAttempt
+		record.LastError = sendErr.Error()
+		record.Failed = permanent
+
+		return putOutboxRecord(b, record)
+	})
+}
+
+func (store *Store) decryptOutboxRecord(record *outboxRecord) error {
+	payload := outboxPayload{}
+	if err := store.decryptForAddress(record.AddressID, record.Payload, &payload); err != nil {
+		return err
+	}
+
+	record.From = payload.From
+	record.To = payload.To
+	record.Body = payload.Body
+	record.NotifySuccess = payload.NotifySuccess
+	record.NotifyFailure = payload.NotifyFailure
+	record.SendAt = payload.SendAt
+
+	if msg, err := mail.ReadMessage(bytes.NewReader(payload.Body)); err == nil {
+		record.Subject = msg.Header.Get("Subject")
+	}
+
+	return nil
+}
+
+// encryptForAddress marshals `v` and encrypts it by the key of the address
+// with `addressID`, so the data is not stored on disk in plain text.
+func (store *Store) encryptForAddress(addressID string, v interface{}) ([]byte, error) {
+	kr, err := store.client().KeyRingForAddressID(addressID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get address keyring")
+	}
+
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	enc, err := kr.Encrypt(crypto.NewPlainMessage(plain), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return enc.GetBinary(), nil
+}
+
+// decryptForAddress is the counterpart of encryptForAddress.
+func (store *Store) decryptForAddress(addressID string, data []byte, v interface{}) error {
+	kr, err := store.client().KeyRingForAddressID(addressID)
+	if err != nil {
+		return err
+	}
+
+	dec, err := kr.Decrypt(crypto.NewPGPMessage(data), nil, 0)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(dec.GetBinary(), v)
+}
+
+func putOutboxRecord(b *bolt.Bucket, record *outboxRecord) error {
+	key, err := getOutboxKey(record.ID)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return b.Put(key, raw)
+}
+
+// getOutboxKey returns the key of the outbox record. Sequence is stored
+// in big endian so the records are iterated in order they were queued.
+func getOutboxKey(id string) ([]byte, error) {
+	seq, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid outgoing message ID")
+	}
+	return itob(uint32(seq)), nil
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAddressKeyRing(t *testing.T) *crypto.KeyRing {
+	key, err := crypto.GenerateKey("Test", addr1, "x25519", 0)
+	require.NoError(t, err)
+
+	kr, err := crypto.NewKeyRing(key)
+	require.NoError(t, err)
+
+	return kr
+}
+
+func TestOutboxQueueListCancel(t *testing.T) {
+	m, clear := initMocks(t)
+	defer clear()
+
+	m.newStoreNoEvents(true)
+	m.client.EXPECT().KeyRingForAddressID(addrID1).Return(newTestAddressKeyRing(t), nil).AnyTimes()
+
+	body := []byte("Subject: Hello\r\n\r\nHello world\r\n")
+
+	sendAt := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	id1, err := m.store.QueueOutgoing(&OutgoingMessage{
+		AddressID:     addrID1,
+		From:          addr1,
+		To:            []string{"a@pm.me"},
+		NotifyFailure: []string{"a@pm.me"},
+		Body:          body,
+	})
+	require.NoError(t, err)
+	id2, err := m.store.QueueOutgoing(&OutgoingMessage{
+		AddressID:     addrID1,
+		From:          addr1,
+		To:            []string{"b@pm.me", "c@pm.me"},
+		NotifySuccess: []string{"c@pm.me"},
+		SendAt:        sendAt,
+		Body:          body,
+	})
+	require.NoError(t, err)
+
+	msgs, err := m.store.ListOutgoing()
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, id1, msgs[0].ID)
+	require.Equal(t, addr1, msgs[0].From)
+	require.Equal(t, []string{"a@pm.me"}, msgs[0].To)
+	require.Equal(t, "Hello", msgs[0].Subject)
+	require.Equal(t, body, msgs[0].Body)
+	require.Equal(t, []string{"a@pm.me"}, msgs[0].NotifyFailure)
+	require.True(t, msgs[0].SendAt.IsZero())
+	require.Equal(t, []string{"b@pm.me", "c@pm.me"}, msgs[1].To)
+	require.Equal(t, []string{"c@pm.me"}, msgs[1].NotifySuccess)
+	require.True(t, sendAt.Equal(msgs[1].SendAt))
+
+	next := time.Now().Add(time.Minute)
+	require.NoError(t, m.store.RecordOutgoingFailure(id2, errors.New("rejected"), next, true))
+
+	require.NoError(t, m.store.CancelOutgoing(id1))
+	require.Error(t, m.store.CancelOutgoing(id1))
+
+	msgs, err = m.store.ListOutgoing()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, id2, msgs[0].ID)
+	require.Equal(t, 1, msgs[0].Attempts)
+	require.Equal(t, "rejected", msgs[0].LastError)
+	require.True(t, msgs[0].Failed)
+	require.True(t, next.Equal(msgs[0].NextAttempt))
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ScheduledSend is a draft which should be sent at the given time.
+type ScheduledSend struct {
+	// ID is the API ID of the draft.
+	ID        string
+	AddressID string
+	SendAt    time.Time
+
+	// Attempts is the number of failed dispatch attempts and NextAttempt
+	// is the time after which the dispatch should be tried again.
+	Attempts    int
+	NextAttempt time.Time
+	LastError   string
+	Failed      bool
+
+	// Fields below are stored encrypted by the address key.
+	Subject string                `json:"-"`
+	To      []string              `json:"-"`
+	Request *pmapi.SendMessageReq `json:"-"`
+
+	// NotifySuccess and NotifyFailure are recipients which asked
+	// for delivery status notification (see RFC 3461).
+	NotifySuccess []string `json:"-"`
+	NotifyFailure []string `json:"-"`
+}
+
+// scheduledSendRecord is the stored form of ScheduledSend.
+type scheduledSendRecord struct {
+	ScheduledSend
+	Payload []byte
+}
+
+// scheduledSendPayload is the encrypted part of scheduledSendRecord.
+// Send request contains session keys of the draft, so it must not be
+// stored in plain text.
+type scheduledSendPayload struct {
+	Subject       string
+	To            []string
+	Request       *pmapi.SendMessageReq
+	NotifySuccess []string
+	NotifyFailure []string
+}
+
+// ScheduleSend stores the send request of the draft `send.ID` to be
+// dispatched at `send.SendAt`. Bookkeeping fields of `send` are ignored.
+func (store *Store) ScheduleSend(send *ScheduledSend) error {
+	enc, err := store.encryptForAddress(send.AddressID, scheduledSendPayload{
+		Subject:       send.Subject,
+		To:            send.To,
+		Request:       send.Request,
+		NotifySuccess: send.NotifySuccess,
+		NotifyFailure: send.NotifyFailure,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to encrypt send request")
+	}
+
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		return putScheduledSendRecord(tx.Bucket(scheduledSendsBucket), &scheduledSendRecord{
+			ScheduledSend: ScheduledSend{
+				ID:          send.ID,
+				AddressID:   send.AddressID,
+				SendAt:      send.SendAt,
+				NextAttempt: send.SendAt,
+			},
+			Payload: enc,
+		})
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to store scheduled send")
+	}
+
+	store.log.WithField("messageID", send.ID).WithField("sendAt", send.SendAt).Info("Message scheduled")
+	return nil
+}
+
+// ListScheduledSends returns all scheduled sends ordered by the time of sending.
+// Sends which cannot be decrypted are returned without the encrypted fields.
+func (store *Store) ListScheduledSends() (sends []*ScheduledSend, err error) {
+	var records []*scheduledSendRecord
+	err = store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(scheduledSendsBucket).ForEach(func(k, v []byte) error {
+			record := &scheduledSendRecord{}
+			if err := json.Unmarshal(v, record); err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read scheduled sends")
+	}
+
+	for _, record := range records {
+		payload := scheduledSendPayload{}
+		if err := store.decryptForAddress(record.AddressID, record.Payload, &payload); err != nil {
+			store.log.WithError(err).WithField("messageID", record.ID).Warn("Cannot decrypt scheduled send")
+		} else {
+			record.Subject = payload.Subject
+			record.To = payload.To
+			record.Request = payload.Request
+			record.NotifySuccess = payload.NotifySuccess
+			record.NotifyFailure = payload.NotifyFailure
+		}
+		send := record.ScheduledSend
+		sends = append(sends, &send)
+	}
+
+	sort.SliceStable(sends, func(i, j int) bool {
+		return sends[i].SendAt.Before(sends[j].SendAt)
+	})
+
+	return sends, nil
+}
+
+// IsScheduledSend returns whether the draft with `messageID` is scheduled to be sent.
+func (store *Store) IsScheduledSend(messageID string) (isScheduled bool, err error) {
+	err = store.db.View(func(tx *bolt.Tx) error {
+		isScheduled = tx.Bucket(scheduledSendsBucket).Get([]byte(messageID)) != nil
+		return nil
+	})
+	return
+}
+
+// CancelScheduledSend removes the draft from the schedule. The draft itself is kept.
+func (store *Store) CancelScheduledSend(messageID string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(scheduledSendsBucket)
+		if b.Get([]byte(messageID)) == nil {
+			return errors.New("no scheduled send of message " + messageID)
+		}
+		return b.Delete([]byte(messageID))
+	})
+}
+
+// RecordScheduledSendFailure stores the result of failed dispatch. When
+// the failure is `permanent`, the dispatch is not retried automatically.
+func (store *Store) RecordScheduledSendFailure(messageID string, sendErr error, nextAttempt time.Time, permanent bool) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(scheduledSendsBucket)
+
+		raw := b.Get([]byte(messageID))
+		if raw == nil {
+			return errors.New("no scheduled send of message " + messageID)
+		}
+
+		record := &scheduledSendRecord{}
+		if err := json.Unmarshal(raw, record); err != nil {
+			return err
+		}
+
+		record.Attempts++
+		record.NextAttempt = nextAttempt
+		record.LastError = sendErr.Error()
+		record.Failed = permanent
+
+		return putScheduledSendRecord(b, record)
+	})
+}
+
+func putScheduledSendRecord(b *bolt.Bucket, record *scheduledSendRecord) error {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(record.ID), raw)
+}
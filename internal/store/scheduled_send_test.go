@@ -0,0 +1,87 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduledSends(t *testing.T) {
+	m, clear := initMocks(t)
+	defer clear()
+
+	m.newStoreNoEvents(true)
+	m.client.EXPECT().KeyRingForAddressID(addrID1).Return(newTestAddressKeyRing(t), nil).AnyTimes()
+
+	later := time.Now().Add(time.Hour)
+	sooner := time.Now().Add(time.Minute)
+	req := &pmapi.SendMessageReq{Packages: []*pmapi.MessagePackage{{MIMEType: pmapi.ContentTypeHTML}}}
+
+	require.NoError(t, m.store.ScheduleSend(&ScheduledSend{
+		ID:        "draft1",
+		AddressID: addrID1,
+		SendAt:    later,
+		Subject:   "Later",
+		To:        []string{"a@pm.me"},
+		Request:   req,
+	}))
+	require.NoError(t, m.store.ScheduleSend(&ScheduledSend{
+		ID:            "draft2",
+		AddressID:     addrID1,
+		SendAt:        sooner,
+		Subject:       "Sooner",
+		To:            []string{"b@pm.me"},
+		Request:       req,
+		NotifySuccess: []string{"b@pm.me"},
+	}))
+
+	isScheduled, err := m.store.IsScheduledSend("draft1")
+	require.NoError(t, err)
+	require.True(t, isScheduled)
+
+	sends, err := m.store.ListScheduledSends()
+	require.NoError(t, err)
+	require.Len(t, sends, 2)
+	require.Equal(t, "draft2", sends[0].ID)
+	require.Equal(t, "Sooner", sends[0].Subject)
+	require.Equal(t, []string{"b@pm.me"}, sends[0].To)
+	require.Equal(t, req, sends[0].Request)
+	require.Equal(t, []string{"b@pm.me"}, sends[0].NotifySuccess)
+	require.True(t, sooner.Equal(sends[0].SendAt))
+	require.Equal(t, "draft1", sends[1].ID)
+
+	require.NoError(t, m.store.RecordScheduledSendFailure("draft2", errors.New("offline"), later, false))
+	require.NoError(t, m.store.CancelScheduledSend("draft1"))
+	require.Error(t, m.store.CancelScheduledSend("draft1"))
+
+	isScheduled, err = m.store.IsScheduledSend("draft1")
+	require.NoError(t, err)
+	require.False(t, isScheduled)
+
+	sends, err = m.store.ListScheduledSends()
+	require.NoError(t, err)
+	require.Len(t, sends, 1)
+	require.Equal(t, 1, sends[0].Attempts)
+	require.Equal(t, "offline", sends[0].LastError)
+	require.False(t, sends[0].Failed)
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// sendRecord remembers which draft was created for the message with the hash.
+type sendRecord struct {
+	MessageID string
+	Time      time.Time
+}
+
+// GetSendRecord returns the ID of draft created for the message with `hash`
+// and the time it was recorded. Empty messageID means there is no record.
+func (store *Store) GetSendRecord(hash string) (messageID string, recordedAt time.Time, err error) {
+	err = store.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(sendRecordsBucket).Get([]byte(hash))
+		if raw == nil {
+			return nil
+		}
+
+		record := sendRecord{}
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return err
+		}
+
+		messageID = record.MessageID
+		recordedAt = record.Time
+		return nil
+	})
+	if err != nil {
+		err = errors.Wrap(err, "failed to read send record")
+	}
+	return
+}
+
+// AddSendRecord records the ID of draft created for the message with `hash`.
+func (store *Store) AddSendRecord(hash, messageID string) error {
+	raw, err := json.Marshal(sendRecord{MessageID: messageID, Time: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sendRecordsBucket).Put([]byte(hash), raw)
+	})
+}
+
+// DeleteSendRecordsBefore removes all records older than `before`.
+func (store *Store) DeleteSendRecordsBefore(before time.Time) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(sendRecordsBucket)
+
+		var expired [][]byte
+		if err := b.ForEach(func(k, v []byte) error {
+			record := sendRecord{}
+			if err := json.Unmarshal(v, &record); err != nil || record.Time.Before(before) {
+				expired = append(expired, k)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
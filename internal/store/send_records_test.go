@@ -0,0 +1,53 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendRecords(t *testing.T) {
+	m, clear := initMocks(t)
+	defer clear()
+
+	m.newStoreNoEvents(true)
+
+	messageID, _, err := m.store.GetSendRecord("hash1")
+	require.NoError(t, err)
+	require.Equal(t, "", messageID)
+
+	require.NoError(t, m.store.AddSendRecord("hash1", "draft1"))
+
+	messageID, recordedAt, err := m.store.GetSendRecord("hash1")
+	require.NoError(t, err)
+	require.Equal(t, "draft1", messageID)
+	require.WithinDuration(t, time.Now(), recordedAt, time.Minute)
+
+	require.NoError(t, m.store.DeleteSendRecordsBefore(time.Now().Add(-time.Minute)))
+	messageID, _, err = m.store.GetSendRecord("hash1")
+	require.NoError(t, err)
+	require.Equal(t, "draft1", messageID)
+
+	require.NoError(t, m.store.DeleteSendRecordsBefore(time.Now().Add(time.Minute)))
+	messageID, _, err = m.store.GetSendRecord("hash1")
+	require.NoError(t, err)
+	require.Equal(t, "", messageID)
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// GetSenderAliases returns all aliases of the user as a map from alias
+// to the owned address used to send messages from the alias.
+func (store *Store) GetSenderAliases() (map[string]string, error) {
+	aliases := map[string]string{}
+
+	err := store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(senderAliasesBucket).ForEach(func(k, v []byte) error {
+			aliases[string(k)] = string(v)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read sender aliases")
+	}
+
+	return aliases, nil
+}
+
+// SetSenderAlias allows to send messages from `alias` using the owned `address`.
+func (store *Store) SetSenderAlias(alias, address string) error {
+	alias = strings.ToLower(strings.TrimSpace(alias))
+	if alias == "" || !strings.Contains(alias, "@") {
+		return errors.New("invalid alias address")
+	}
+
+	addr := store.client().Addresses().ByEmail(address)
+	if addr == nil {
+		return errors.New("address " + address + " is not owned by the user")
+	}
+
+	if store.client().Addresses().ByEmail(alias) != nil {
+		return errors.New("alias " + alias + " is already owned by the user")
+	}
+
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(senderAliasesBucket).Put([]byte(alias), []byte(strings.ToLower(addr.Email)))
+	})
+}
+
+// DeleteSenderAlias removes the `alias`.
+func (store *Store) DeleteSenderAlias(alias string) error {
+	alias = strings.ToLower(strings.TrimSpace(alias))
+
+	return store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(senderAliasesBucket)
+		if b.Get([]byte(alias)) == nil {
+			return errors.New("alias " + alias + " does not exist")
+		}
+		return b.Delete([]byte(alias))
+	})
+}
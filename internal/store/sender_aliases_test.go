@@ -0,0 +1,56 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"testing"
+
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSenderAliases(t *testing.T) {
+	m, clear := initMocks(t)
+	defer clear()
+
+	m.newStoreNoEvents(true)
+
+	m.client.EXPECT().Addresses().Return(pmapi.AddressList{
+		{ID: addrID1, Email: addr1},
+	}).AnyTimes()
+
+	aliases, err := m.store.GetSenderAliases()
+	require.NoError(t, err)
+	require.Empty(t, aliases)
+
+	require.NoError(t, m.store.SetSenderAlias("Me@Example.com", addr1))
+	require.Error(t, m.store.SetSenderAlias("other@example.com", "unknown@pm.me"))
+	require.Error(t, m.store.SetSenderAlias(addr1, addr1))
+	require.Error(t, m.store.SetSenderAlias("not an address", addr1))
+
+	aliases, err = m.store.GetSenderAliases()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"me@example.com": addr1}, aliases)
+
+	require.NoError(t, m.store.DeleteSenderAlias("me@example.com"))
+	require.Error(t, m.store.DeleteSenderAlias("me@example.com"))
+
+	aliases, err = m.store.GetSenderAliases()
+	require.NoError(t, err)
+	require.Empty(t, aliases)
+}
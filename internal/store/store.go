@@ -58,27 +58,45 @@ var (
 	//   * {index} -> {address, addressID}
 	// * address_mode
 	//   * mode -> string split or combined
+	// * credentials
+	//   * extension -> "1" when credentials of the user were saved with the extension item
 	// * mailboxes_version
 	//     * version -> uint32 value
+	// * schema_version
+	//     * version -> uint32 value of the last applied migration
 	// * sync_state
 	//   * sync_state -> string timestamp when it was last synced (when missing, sync should be ongoing)
 	//   * ids_ranges -> json array of groups with start and end message ID (when missing, there is no ongoing sync)
 	//   * ids_to_be_deleted -> json array of message IDs to be deleted after sync (when missing, there is no ongoing sync)
+	// * outbox
+	//   * {sequence} -> json of queued outgoing message (envelope and body encrypted by address key)
+	// * scheduled_sends
+	//   * {messageID} -> json of draft to be sent later (send request encrypted by address key)
+	// * send_records
+	//   * {hash of message} -> json with ID of draft created for sending and time of the record
+	// * sender_aliases
+	//   * {alias address} -> string owned address used to send messages from the alias
 	// * mailboxes
 	//   * {addressID+mailboxID}
 	//     * imap_ids
 	//       * {imapUID} -> string messageID
 	//     * api_ids
 	//       * {messageID} -> uint32 imapUID
-	metadataBucket    = []byte("metadata")          //nolint[gochecknoglobals]
-	countsBucket      = []byte("counts")            //nolint[gochecknoglobals]
-	addressInfoBucket = []byte("address_info")      //nolint[gochecknoglobals]
-	addressModeBucket = []byte("address_mode")      //nolint[gochecknoglobals]
-	syncStateBucket   = []byte("sync_state")        //nolint[gochecknoglobals]
-	mailboxesBucket   = []byte("mailboxes")         //nolint[gochecknoglobals]
-	imapIDsBucket     = []byte("imap_ids")          //nolint[gochecknoglobals]
-	apiIDsBucket      = []byte("api_ids")           //nolint[gochecknoglobals]
-	mboxVersionBucket = []byte("mailboxes_version") //nolint[gochecknoglobals]
+	metadataBucket       = []byte("metadata")          //nolint[gochecknoglobals]
+	countsBucket         = []byte("counts")            //nolint[gochecknoglobals]
+	addressInfoBucket    = []byte("address_info")      //nolint[gochecknoglobals]
+	addressModeBucket    = []byte("address_mode")      //nolint[gochecknoglobals]
+	credentialsBucket    = []byte("credentials")       //nolint[gochecknoglobals]
+	syncStateBucket      = []byte("sync_state")        //nolint[gochecknoglobals]
+	mailboxesBucket      = []byte("mailboxes")         //nolint[gochecknoglobals]
+	outboxBucket         = []byte("outbox")            //nolint[gochecknoglobals]
+	sendRecordsBucket    = []byte("send_records")      //nolint[gochecknoglobals]
+	scheduledSendsBucket = []byte("scheduled_sends")   //nolint[gochecknoglobals]
+	senderAliasesBucket  = []byte("sender_aliases")    //nolint[gochecknoglobals]
+	imapIDsBucket        = []byte("imap_ids")          //nolint[gochecknoglobals]
+	apiIDsBucket         = []byte("api_ids")           //nolint[gochecknoglobals]
+	mboxVersionBucket    = []byte("mailboxes_version") //nolint[gochecknoglobals]
+	schemaVersionBucket  = []byte("schema_version")    //nolint[gochecknoglobals]
 
 	// ErrNoSuchAPIID when mailbox does not have API ID.
 	ErrNoSuchAPIID = errors.New("no such api id") //nolint[gochecknoglobals]
@@ -94,6 +112,7 @@ type Store struct {
 	eventLoop     *eventLoop
 	user          BridgeUser
 	clientManager ClientManager
+	events        listener.Listener
 
 	log *logrus.Entry
 
@@ -139,9 +158,19 @@ func New(
 		return
 	}
 
+	if err = initSchemaVersion(bdb, firstInit); err != nil {
+		l.WithError(err).Error("Could not migrate store database, attempting to close")
+		if dbCloseErr := bdb.Close(); dbCloseErr != nil {
+			l.WithError(dbCloseErr).Warn("Could not close unmigrated store database")
+		}
+		err = errors.Wrap(err, "failed to migrate store database")
+		return
+	}
+
 	store = &Store{
 		panicHandler:  panicHandler,
 		clientManager: clientManager,
+		events:        events,
 		user:          user,
 		cache:         cache,
 		filePath:      path,
@@ -363,6 +392,30 @@ func (store *Store) CloseEventLoop() {
 	}
 }
 
+// NotifyIdleStarted tells the event loop that an IMAP client entered IDLE
+// and is waiting for updates, so the events should be polled faster.
+func (store *Store) NotifyIdleStarted() {
+	if store.eventLoop != nil {
+		store.eventLoop.idleStarted()
+	}
+}
+
+// NotifyIdleFinished tells the event loop that an IMAP client left IDLE.
+func (store *Store) NotifyIdleFinished() {
+	if store.eventLoop != nil {
+		store.eventLoop.idleFinished()
+	}
+}
+
+// WakeEventLoop triggers polling of events right away without waiting
+// for the result. It is meant to be called by push notification sources
+// which know there are new events on the API.
+func (store *Store) WakeEventLoop() {
+	if store.eventLoop != nil {
+		store.eventLoop.wake()
+	}
+}
+
 func (store *Store) close() error {
 	store.CloseEventLoop()
 	return store.db.Close()
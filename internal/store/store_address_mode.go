@@ -72,7 +72,8 @@ func (store *Store) UseCombinedMode(useCombined bool) (err error) {
 	return
 }
 
-// switchAddressMode sets the address mode to the given value and rebuilds the mailboxes.
+// switchAddressMode sets the address mode to the given value and remaps the mailboxes.
+// UIDs assigned to messages are kept, so the UIDVALIDITY does not have to change.
 func (store *Store) switchAddressMode(mode addressMode) (err error) {
 	if store.addressMode == mode {
 		log.Debug("The store is using the correct address mode")
@@ -84,8 +85,8 @@ func (store *Store) switchAddressMode(mode addressMode) (err error) {
 		return
 	}
 
-	if err = store.RebuildMailboxes(); err != nil {
-		log.WithError(err).Error("Could not rebuild mailboxes after switching address mode")
+	if err = store.remapMailboxes(); err != nil {
+		log.WithError(err).Error("Could not remap mailboxes after switching address mode")
 		return
 	}
 
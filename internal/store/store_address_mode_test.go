@@ -0,0 +1,88 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"testing"
+
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/stretchr/testify/require"
+)
+
+func insertAddressMessage(t *testing.T, m *mocksForStore, id, addressID string) {
+	msg := getTestMessage(id, "Test message", addr1, 0, []string{pmapi.AllMailLabel, pmapi.InboxLabel})
+	msg.AddressID = addressID
+	require.Nil(t, m.store.createOrUpdateMessageEvent(msg))
+}
+
+func checkInboxUIDs(t *testing.T, m *mocksForStore, addressID string, wantUIDs map[string]uint32) {
+	inbox := m.store.addresses[addressID].mailboxes[pmapi.InboxLabel]
+
+	total, _, _, err := inbox.GetCounts()
+	require.NoError(t, err)
+	require.Equal(t, uint(len(wantUIDs)), total)
+
+	for apiID, wantUID := range wantUIDs {
+		uid, err := inbox.getUID(apiID)
+		require.NoError(t, err)
+		require.Equal(t, wantUID, uid, "UID of %v", apiID)
+	}
+}
+
+func TestSwitchAddressModeKeepsUIDs(t *testing.T) {
+	m, clear := initMocks(t)
+	defer clear()
+
+	m.newStoreNoEvents(true)
+
+	// Switching the mode initialises the store addresses again.
+	m.client.EXPECT().ListLabels().AnyTimes()
+	m.client.EXPECT().CountMessages("").AnyTimes()
+	m.client.EXPECT().Addresses().Return(pmapi.AddressList{
+		{ID: addrID1, Email: addr1, Type: pmapi.OriginalAddress, Receive: pmapi.CanReceive},
+		{ID: addrID2, Email: addr2, Type: pmapi.AliasAddress, Receive: pmapi.CanReceive},
+	}).AnyTimes()
+
+	insertAddressMessage(t, m, "msg1", addrID1)
+	insertAddressMessage(t, m, "msg2", addrID2)
+	insertAddressMessage(t, m, "msg3", addrID1)
+
+	uidValidity := m.store.addresses[addrID1].mailboxes[pmapi.InboxLabel].UIDValidity()
+	checkInboxUIDs(t, m, addrID1, map[string]uint32{"msg1": 1, "msg2": 2, "msg3": 3})
+
+	// Combined to split: primary address keeps UIDs of its messages.
+	require.NoError(t, m.store.UseCombinedMode(false))
+	require.Equal(t, uidValidity, m.store.addresses[addrID1].mailboxes[pmapi.InboxLabel].UIDValidity())
+	checkInboxUIDs(t, m, addrID1, map[string]uint32{"msg1": 1, "msg3": 3})
+	checkInboxUIDs(t, m, addrID2, map[string]uint32{"msg2": 1})
+
+	insertAddressMessage(t, m, "msg4", addrID2)
+	require.NoError(t, m.store.deleteMessageEvent("msg1"))
+	checkInboxUIDs(t, m, addrID2, map[string]uint32{"msg2": 1, "msg4": 2})
+
+	// Split to combined: existing UIDs are kept and others get new ones.
+	require.NoError(t, m.store.UseCombinedMode(true))
+	require.Equal(t, uidValidity, m.store.addresses[addrID1].mailboxes[pmapi.InboxLabel].UIDValidity())
+	checkInboxUIDs(t, m, addrID1, map[string]uint32{"msg2": 4, "msg3": 3, "msg4": 5})
+
+	// Changes done in combined mode are applied to kept split mailboxes.
+	require.NoError(t, m.store.deleteMessageEvent("msg2"))
+	require.NoError(t, m.store.UseCombinedMode(false))
+	checkInboxUIDs(t, m, addrID1, map[string]uint32{"msg3": 3})
+	checkInboxUIDs(t, m, addrID2, map[string]uint32{"msg4": 2})
+}
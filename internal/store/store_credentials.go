@@ -0,0 +1,47 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	bolt "go.etcd.io/bbolt"
+)
+
+const credentialsExtensionKey = "extension"
+
+// HadCredentialsExtension returns whether credentials of the user were seen
+// with the extension item. Older versions drop the extension when they save
+// the credentials, so credentials without it which had it before lost data.
+func (store *Store) HadCredentialsExtension() (had bool) {
+	_ = store.db.View(func(tx *bolt.Tx) error {
+		had = tx.Bucket(credentialsBucket).Get([]byte(credentialsExtensionKey)) != nil
+		return nil
+	})
+	return
+}
+
+// SetHadCredentialsExtension records whether credentials of the user were
+// seen with the extension item.
+func (store *Store) SetHadCredentialsExtension(had bool) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(credentialsBucket)
+		if !had {
+			return b.Delete([]byte(credentialsExtensionKey))
+		}
+		return b.Put([]byte(credentialsExtensionKey), []byte("1"))
+	})
+}
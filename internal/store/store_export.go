@@ -0,0 +1,225 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/crypto/openpgp"
+)
+
+// storeArchiveVersion is increased whenever the archive envelope changes.
+const storeArchiveVersion = 2
+
+// storeArchiveHeader is the first line of an exported store. The whole
+// database follows it, so metadata, counts, address info and IMAP UID maps
+// are restored exactly as they were; event ID lets the event loop continue
+// from the point at which the store was exported instead of doing a full sync.
+type storeArchiveHeader struct {
+	Version int
+	UserID  string
+	EventID string
+}
+
+// machineBuckets hold state which belongs to the machine and is cleared on
+// import. Otherwise messages queued or scheduled for sending would be sent
+// by both machines.
+var machineBuckets = [][]byte{ //nolint[gochecknoglobals]
+	outboxBucket,
+	scheduledSendsBucket,
+	sendRecordsBucket,
+	credentialsBucket,
+}
+
+// Export writes the store of the user to `w` as an archive encrypted by
+// `passphrase`. The archive can be loaded on another machine by ImportStore.
+// The database is streamed to `w` so it is never held in memory.
+func (store *Store) Export(w io.Writer, passphrase []byte) error {
+	if len(passphrase) == 0 {
+		return errors.New("missing passphrase")
+	}
+
+	userID := store.user.ID()
+	header := storeArchiveHeader{
+		Version: storeArchiveVersion,
+		UserID:  userID,
+		EventID: store.cache.getEventID(userID),
+	}
+
+	rawHeader, err := json.Marshal(header)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal archive header")
+	}
+
+	enc, err := openpgp.SymmetricallyEncrypt(w, passphrase, &openpgp.FileHints{IsBinary: true}, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to encrypt archive")
+	}
+
+	if _, err := enc.Write(append(rawHeader, '\n')); err != nil {
+		return errors.Wrap(err, "failed to write archive header")
+	}
+
+	if err := store.db.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(enc)
+		return err
+	}); err != nil {
+		return errors.Wrap(err, "failed to write database")
+	}
+
+	if err := enc.Close(); err != nil {
+		return errors.Wrap(err, "failed to finish archive")
+	}
+
+	store.log.WithField("eventID", header.EventID).Info("Store exported")
+	return nil
+}
+
+// ImportStore loads the archive created by Export from `r` into the database
+// file at `path` and sets the last event ID of the user to the exported one.
+// Buckets with state of the exporting machine are cleared.
+// It must be called before the store of the user is opened.
+func ImportStore(cache *Cache, path, userID string, r io.Reader, passphrase []byte) error {
+	body, err := decryptStoreArchive(r, passphrase)
+	if err != nil {
+		return err
+	}
+
+	header, err := readStoreArchiveHeader(body)
+	if err != nil {
+		return err
+	}
+
+	if header.UserID != userID {
+		return errors.New("archive belongs to a different user")
+	}
+
+	// Write to a temporary file first so a broken import never leaves
+	// a half-written database behind.
+	tmpPath := path + ".import"
+	if err := writeImportedDatabase(tmpPath, body); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return errors.Wrap(err, "failed to move imported database")
+	}
+
+	if err := cache.setEventID(userID, header.EventID); err != nil {
+		return errors.Wrap(err, "failed to set event ID")
+	}
+
+	log.WithField("user", userID).WithField("eventID", header.EventID).Info("Store imported")
+	return nil
+}
+
+func decryptStoreArchive(r io.Reader, passphrase []byte) (*bufio.Reader, error) {
+	prompted := false
+	prompt := func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		if prompted {
+			return nil, errors.New("wrong passphrase")
+		}
+		prompted = true
+		return passphrase, nil
+	}
+
+	md, err := openpgp.ReadMessage(r, nil, prompt, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt archive")
+	}
+
+	return bufio.NewReader(md.UnverifiedBody), nil
+}
+
+func readStoreArchiveHeader(body *bufio.Reader) (*storeArchiveHeader, error) {
+	rawHeader, err := body.ReadBytes('\n')
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read archive header")
+	}
+
+	header := &storeArchiveHeader{}
+	if err := json.Unmarshal(rawHeader, header); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal archive header")
+	}
+
+	if header.Version != storeArchiveVersion {
+		return nil, errors.Errorf("unsupported archive version %d", header.Version)
+	}
+
+	return header, nil
+}
+
+// writeImportedDatabase writes the database from `body` to `path` and clears
+// the machine buckets. The body is read to the end, which verifies integrity
+// of the whole archive.
+func writeImportedDatabase(path string, body io.Reader) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to create database")
+	}
+
+	if _, err := io.Copy(f, body); err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "failed to read database")
+	}
+
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "failed to write database")
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return errors.Wrap(err, "archive does not contain valid database")
+	}
+
+	if err := db.Update(clearMachineBuckets); err != nil {
+		_ = db.Close()
+		return errors.Wrap(err, "failed to clear imported database")
+	}
+
+	if err := db.Close(); err != nil {
+		return errors.Wrap(err, "failed to close imported database")
+	}
+
+	return nil
+}
+
+// clearMachineBuckets empties the machine buckets which exist in the database.
+// Missing ones are created later by migrations.
+func clearMachineBuckets(tx *bolt.Tx) error {
+	for _, name := range machineBuckets {
+		if tx.Bucket(name) == nil {
+			continue
+		}
+		if err := tx.DeleteBucket(name); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
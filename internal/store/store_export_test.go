@@ -0,0 +1,84 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestStoreExportImport(t *testing.T) {
+	m, clear := initMocks(t)
+	defer clear()
+
+	m.newStoreNoEvents(true)
+	insertMessage(t, m, "msg1", "Test message 1", addr1, 0, []string{pmapi.AllMailLabel, pmapi.InboxLabel})
+	insertMessage(t, m, "msg2", "Test message 2", addr1, 0, []string{pmapi.AllMailLabel, pmapi.InboxLabel})
+	require.NoError(t, m.cache.setEventID("userID", "exportedEventID"))
+
+	// Machine state must not be imported, otherwise both machines would send.
+	require.NoError(t, m.store.AddSendRecord("hash1", "draft1"))
+	require.NoError(t, m.store.SetHadCredentialsExtension(true))
+	require.NoError(t, m.store.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(outboxBucket).Put(itob(1), []byte("queued")); err != nil {
+			return err
+		}
+		return tx.Bucket(scheduledSendsBucket).Put([]byte("draft2"), []byte("scheduled"))
+	}))
+
+	passphrase := []byte("hashed mailbox passphrase")
+
+	var archive bytes.Buffer
+	require.NoError(t, m.store.Export(&archive, passphrase))
+
+	cache := NewCache(filepath.Join(m.tmpDir, "imported-cache.json"))
+	path := filepath.Join(m.tmpDir, "mailbox-imported.db")
+
+	require.Error(t, ImportStore(cache, path, "userID", bytes.NewReader(archive.Bytes()), []byte("wrong")))
+	require.Error(t, ImportStore(cache, path, "otherUserID", bytes.NewReader(archive.Bytes()), passphrase))
+	require.NoError(t, ImportStore(cache, path, "userID", bytes.NewReader(archive.Bytes()), passphrase))
+
+	require.Equal(t, "exportedEventID", cache.getEventID("userID"))
+
+	db, err := bolt.Open(path, 0600, nil)
+	require.NoError(t, err)
+	defer db.Close() //nolint[errcheck]
+
+	require.NoError(t, db.View(func(tx *bolt.Tx) error {
+		metadata := tx.Bucket(metadataBucket)
+		require.NotNil(t, metadata.Get([]byte("msg1")))
+		require.NotNil(t, metadata.Get([]byte("msg2")))
+
+		inbox := tx.Bucket(mailboxesBucket).Bucket(getMailboxBucketName(addrID1, pmapi.InboxLabel))
+		require.NotNil(t, inbox)
+		require.Equal(t, itob(1), inbox.Bucket(apiIDsBucket).Get([]byte("msg1")))
+		require.Equal(t, itob(2), inbox.Bucket(apiIDsBucket).Get([]byte("msg2")))
+
+		for _, name := range machineBuckets {
+			b := tx.Bucket(name)
+			require.NotNil(t, b, string(name))
+			require.Equal(t, 0, b.Stats().KeyN, string(name))
+		}
+		return nil
+	}))
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const schemaVersionKey = "version"
+
+// errMigrationDryRun is used to roll back the migration transaction
+// when only a dry run was requested.
+var errMigrationDryRun = errors.New("migration dry run") //nolint[gochecknoglobals]
+
+// storeMigration is one step upgrading the database layout from
+// `version-1` to `version`. Each step runs inside the same transaction
+// as all other pending steps, so either all of them succeed or none.
+type storeMigration struct {
+	version     uint32
+	description string
+	migrate     func(tx *bolt.Tx) error
+}
+
+// storeMigrations is the ordered registry of all database layout changes.
+// New steps must be appended with the next version number; existing steps
+// must never be changed once released.
+var storeMigrations = []storeMigration{ //nolint[gochecknoglobals]
+	{
+		version:     1,
+		description: "record schema version of existing layout",
+		migrate:     func(tx *bolt.Tx) error { return nil },
+	},
+	{
+		version:     2,
+		description: "create outbox bucket",
+		migrate:     createBucketMigration(outboxBucket),
+	},
+	{
+		version:     3,
+		description: "create send records bucket",
+		migrate:     createBucketMigration(sendRecordsBucket),
+	},
+	{
+		version:     4,
+		description: "create scheduled sends bucket",
+		migrate:     createBucketMigration(scheduledSendsBucket),
+	},
+	{
+		version:     5,
+		description: "create sender aliases bucket",
+		migrate:     createBucketMigration(senderAliasesBucket),
+	},
+	{
+		version:     6,
+		description: "create credentials bucket",
+		migrate:     createBucketMigration(credentialsBucket),
+	},
+}
+
+// createBucketMigration returns migration step adding a top level bucket.
+func createBucketMigration(name []byte) func(tx *bolt.Tx) error {
+	return func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(name)
+		return err
+	}
+}
+
+// latestSchemaVersion returns the version the database has after all
+// the given migrations are applied.
+func latestSchemaVersion(migrations []storeMigration) uint32 {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].version
+}
+
+// readSchemaVersion returns the schema version stored in the database.
+// Database created before the migrations were introduced has version zero.
+func readSchemaVersion(tx *bolt.Tx) uint32 {
+	b := tx.Bucket(schemaVersionBucket)
+	if b == nil {
+		return 0
+	}
+	if verRaw := b.Get([]byte(schemaVersionKey)); verRaw != nil {
+		return btoi(verRaw)
+	}
+	return 0
+}
+
+func writeSchemaVersion(tx *bolt.Tx, version uint32) error {
+	b, err := tx.CreateBucketIfNotExists(schemaVersionBucket)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(schemaVersionKey), itob(version))
+}
+
+// pendingMigrations returns the steps which were not applied yet
+// to the database with the given version.
+func pendingMigrations(migrations []storeMigration, version uint32) (pending []storeMigration) {
+	for _, migration := range migrations {
+		if migration.version > version {
+			pending = append(pending, migration)
+		}
+	}
+	return
+}
+
+// getMigrationBackupPath returns the path of the database backup
+// made before migrating from the given version.
+func getMigrationBackupPath(path string, version uint32) string {
+	return fmt.Sprintf("%v.v%d.bak", path, version)
+}
+
+// migrateDatabase applies all pending `migrations` to the database in order.
+// If `backup` is set, the database file is copied next to the original one
+// before any change is made. If `dryRun` is set, all steps are executed but
+// the transaction is rolled back at the end so the database stays untouched.
+// It returns descriptions of the steps which were (or would be) applied.
+func migrateDatabase(db *bolt.DB, migrations []storeMigration, backup, dryRun bool) (applied []string, err error) {
+	var version uint32
+	if err = db.View(func(tx *bolt.Tx) error {
+		version = readSchemaVersion(tx)
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to read schema version")
+	}
+
+	l := log.WithField("path", db.Path()).WithField("version", version)
+
+	latest := latestSchemaVersion(migrations)
+	if version > latest {
+		return nil, fmt.Errorf("database schema version %d is newer than supported version %d", version, latest)
+	}
+
+	pending := pendingMigrations(migrations, version)
+	if len(pending) == 0 {
+		l.Debug("Store database schema is up to date")
+		return nil, nil
+	}
+
+	if backup && !dryRun {
+		backupPath := getMigrationBackupPath(db.Path(), version)
+		l.WithField("backup", backupPath).Info("Backing up store database before migration")
+		if err = db.View(func(tx *bolt.Tx) error {
+			return tx.CopyFile(backupPath, 0600)
+		}); err != nil {
+			return nil, errors.Wrap(err, "failed to back up database")
+		}
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, migration := range pending {
+			l.WithFields(logrus.Fields{
+				"to":          migration.version,
+				"description": migration.description,
+				"dryRun":      dryRun,
+			}).Info("Migrating store database")
+
+			if err := migration.migrate(tx); err != nil {
+				return errors.Wrapf(err, "migration to version %d failed", migration.version)
+			}
+			if err := writeSchemaVersion(tx, migration.version); err != nil {
+				return errors.Wrapf(err, "failed to write schema version %d", migration.version)
+			}
+
+			applied = append(applied, migration.description)
+		}
+
+		if dryRun {
+			return errMigrationDryRun
+		}
+		return nil
+	})
+
+	if err == errMigrationDryRun {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// DryRunMigrations opens the store database at `path` and runs all pending
+// migrations without persisting them. It returns descriptions of the steps
+// which would be applied when the store is opened next time.
+func DryRunMigrations(path string) (applied []string, err error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open store database")
+	}
+	defer func() {
+		if closeErr := db.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	return migrateDatabase(db, storeMigrations, false, true)
+}
+
+// initSchemaVersion makes sure the database has the latest layout.
+// Newly created database gets all steps without backup, existing
+// database is backed up first.
+func initSchemaVersion(db *bolt.DB, firstInit bool) error {
+	_, err := migrateDatabase(db, storeMigrations, !firstInit, false)
+	return err
+}
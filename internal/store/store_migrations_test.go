@@ -0,0 +1,172 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+var testBucket = []byte("test") //nolint[gochecknoglobals]
+
+func newTestMigrations(calls *[]uint32) []storeMigration {
+	step := func(version uint32) storeMigration {
+		return storeMigration{
+			version:     version,
+			description: "step",
+			migrate: func(tx *bolt.Tx) error {
+				*calls = append(*calls, version)
+				b, err := tx.CreateBucketIfNotExists(testBucket)
+				if err != nil {
+					return err
+				}
+				return b.Put(itob(version), []byte("done"))
+			},
+		}
+	}
+	return []storeMigration{step(1), step(2), step(3)}
+}
+
+func openTestMigrationDB(t *testing.T) (*bolt.DB, func()) {
+	dir, err := ioutil.TempDir("", "store-migration-test")
+	require.NoError(t, err)
+
+	db, err := openBoltDatabase(filepath.Join(dir, "mailbox-test.db"))
+	require.NoError(t, err)
+
+	return db, func() {
+		require.NoError(t, db.Close())
+		require.NoError(t, os.RemoveAll(dir))
+	}
+}
+
+func getTestSchemaVersion(t *testing.T, db *bolt.DB) (version uint32) {
+	require.NoError(t, db.View(func(tx *bolt.Tx) error {
+		version = readSchemaVersion(tx)
+		return nil
+	}))
+	return
+}
+
+func TestMigrateDatabaseRunsPendingStepsInOrder(t *testing.T) {
+	db, clear := openTestMigrationDB(t)
+	defer clear()
+
+	calls := []uint32{}
+	migrations := newTestMigrations(&calls)
+
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		return writeSchemaVersion(tx, 1)
+	}))
+
+	applied, err := migrateDatabase(db, migrations, false, false)
+	require.NoError(t, err)
+	require.Len(t, applied, 2)
+	require.Equal(t, []uint32{2, 3}, calls)
+	require.Equal(t, uint32(3), getTestSchemaVersion(t, db))
+
+	// Second run has nothing to do.
+	applied, err = migrateDatabase(db, migrations, false, false)
+	require.NoError(t, err)
+	require.Len(t, applied, 0)
+	require.Equal(t, []uint32{2, 3}, calls)
+}
+
+func TestMigrateDatabaseDryRun(t *testing.T) {
+	db, clear := openTestMigrationDB(t)
+	defer clear()
+
+	calls := []uint32{}
+
+	applied, err := migrateDatabase(db, newTestMigrations(&calls), true, true)
+	require.NoError(t, err)
+	require.Len(t, applied, 3)
+	require.Equal(t, []uint32{1, 2, 3}, calls)
+
+	require.Equal(t, uint32(0), getTestSchemaVersion(t, db))
+	require.NoError(t, db.View(func(tx *bolt.Tx) error {
+		require.Nil(t, tx.Bucket(testBucket))
+		return nil
+	}))
+
+	_, err = os.Stat(getMigrationBackupPath(db.Path(), 0))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestMigrateDatabaseBackupAndRollback(t *testing.T) {
+	db, clear := openTestMigrationDB(t)
+	defer clear()
+
+	calls := []uint32{}
+	migrations := newTestMigrations(&calls)
+	migrations[2].migrate = func(tx *bolt.Tx) error {
+		return errors.New("broken step")
+	}
+
+	_, err := migrateDatabase(db, migrations, true, false)
+	require.Error(t, err)
+	require.Equal(t, uint32(0), getTestSchemaVersion(t, db))
+
+	backupPath := getMigrationBackupPath(db.Path(), 0)
+	_, err = os.Stat(backupPath)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(backupPath))
+}
+
+func TestMigrateDatabaseRefusesNewerVersion(t *testing.T) {
+	db, clear := openTestMigrationDB(t)
+	defer clear()
+
+	calls := []uint32{}
+
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		return writeSchemaVersion(tx, 10)
+	}))
+
+	_, err := migrateDatabase(db, newTestMigrations(&calls), false, false)
+	require.Error(t, err)
+	require.Empty(t, calls)
+}
+
+func TestInitSchemaVersionCreatesBucketsOfLaterSchemas(t *testing.T) {
+	for _, firstInit := range []bool{true, false} {
+		db, clear := openTestMigrationDB(t)
+
+		require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+			return writeSchemaVersion(tx, 1)
+		}))
+
+		require.NoError(t, initSchemaVersion(db, firstInit))
+		require.Equal(t, latestSchemaVersion(storeMigrations), getTestSchemaVersion(t, db))
+
+		require.NoError(t, db.View(func(tx *bolt.Tx) error {
+			for _, name := range [][]byte{outboxBucket, sendRecordsBucket, scheduledSendsBucket, senderAliasesBucket} {
+				require.NotNil(t, tx.Bucket(name), string(name))
+			}
+			return nil
+		}))
+
+		clear()
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"sync"
 	"testing"
 
+	bridgeEvents "github.com/ProtonMail/proton-bridge/internal/events"
 	storemocks "github.com/ProtonMail/proton-bridge/internal/store/mocks"
 	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
 	pmapimocks "github.com/ProtonMail/proton-bridge/pkg/pmapi/mocks"
@@ -107,6 +108,8 @@ func (mocks *mocksForStore) newStoreNoEvents(combinedMode bool) { //nolint[unpar
 			EventID: "latestEventID",
 		}, nil).AnyTimes()
 
+	mocks.events.EXPECT().Emit(bridgeEvents.SyncFinishedEvent, "userID").AnyTimes()
+
 	// We want to wait until first sync has finished.
 	firstSyncWaiter := sync.WaitGroup{}
 	firstSyncWaiter.Add(1)
@@ -0,0 +1,145 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"sort"
+
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/pkg/errors"
+)
+
+// storeRecoverer is the part of the store needed to bring the local database
+// up to date without the full sync.
+type storeRecoverer interface {
+	getAllMessageIDs() ([]string, error)
+	getMessageFromDB(apiID string) (*pmapi.Message, error)
+	createOrUpdateMessagesEvent([]*pmapi.Message) error
+	deleteMessagesEvent([]string) error
+}
+
+// recoverByDiff compares local metadata with the message list on the API
+// and applies only the differences. It is used when the event ID is not valid
+// anymore, for example when bridge was offline for a long time, so not all
+// events can be replayed but most of the database is still correct.
+// All Mail contains every message with all its LabelIDs, so no other label
+// has to be listed.
+func recoverByDiff(store storeRecoverer, api messageLister) error {
+	messages, err := listAllMessages(pmapi.AllMailLabel, api)
+	if err != nil {
+		return errors.Wrap(err, "failed to list messages of All Mail")
+	}
+
+	remote := map[string]*pmapi.Message{}
+	for _, message := range messages {
+		remote[message.ID] = message
+	}
+
+	localIDs, err := store.getAllMessageIDs()
+	if err != nil {
+		return errors.Wrap(err, "failed to load local message IDs")
+	}
+
+	idsToDelete := []string{}
+	for _, id := range localIDs {
+		if _, ok := remote[id]; !ok {
+			idsToDelete = append(idsToDelete, id)
+		}
+	}
+
+	changed := []*pmapi.Message{}
+	for id, message := range remote {
+		local, err := store.getMessageFromDB(id)
+		if err != nil && err != ErrNoSuchAPIID {
+			return errors.Wrap(err, "failed to load local message")
+		}
+		if err == ErrNoSuchAPIID || isMetadataChanged(local, message) {
+			changed = append(changed, message)
+		}
+	}
+
+	log.WithField("changed", len(changed)).
+		WithField("deleted", len(idsToDelete)).
+		Info("Recovering store from differences")
+
+	if len(changed) != 0 {
+		if err := store.createOrUpdateMessagesEvent(changed); err != nil {
+			return errors.Wrap(err, "failed to update changed messages")
+		}
+	}
+
+	if len(idsToDelete) != 0 {
+		if err := store.deleteMessagesEvent(idsToDelete); err != nil {
+			return errors.Wrap(err, "failed to delete messages")
+		}
+	}
+
+	return nil
+}
+
+// listAllMessages returns metadata of all messages in the given label.
+func listAllMessages(labelID string, api messageLister) (messages []*pmapi.Message, err error) {
+	desc := false
+	for page := 0; ; page++ {
+		filter := &pmapi.MessagesFilter{
+			LabelID:  labelID,
+			Sort:     "ID",
+			Desc:     &desc,
+			PageSize: maxFilterPageSize,
+			Page:     page,
+		}
+
+		pageMessages, _, err := api.ListMessages(filter)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, pageMessages...)
+
+		if len(pageMessages) < maxFilterPageSize {
+			return messages, nil
+		}
+	}
+}
+
+// isMetadataChanged returns whether the fields which can be changed on
+// the message after it was created differ.
+func isMetadataChanged(local, remote *pmapi.Message) bool {
+	if local.Unread != remote.Unread ||
+		local.Flags != remote.Flags ||
+		local.Time != remote.Time ||
+		local.Subject != remote.Subject {
+		return true
+	}
+
+	if len(local.LabelIDs) != len(remote.LabelIDs) {
+		return true
+	}
+
+	localLabels := append([]string{}, local.LabelIDs...)
+	remoteLabels := append([]string{}, remote.LabelIDs...)
+	sort.Strings(localLabels)
+	sort.Strings(remoteLabels)
+	for i := range localLabels {
+		if localLabels[i] != remoteLabels[i] {
+			return true
+		}
+	}
+
+	return false
+}
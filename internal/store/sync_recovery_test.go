@@ -0,0 +1,146 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type mockLabelLister struct {
+	err      error
+	byLabels map[string][]*pmapi.Message
+	listed   map[string]bool
+}
+
+func (m *mockLabelLister) ListMessages(filter *pmapi.MessagesFilter) ([]*pmapi.Message, int, error) {
+	if m.listed == nil {
+		m.listed = map[string]bool{}
+	}
+	m.listed[filter.LabelID] = true
+	if m.err != nil {
+		return nil, 0, m.err
+	}
+	messages := m.byLabels[filter.LabelID]
+	start := filter.Page * filter.PageSize
+	if start >= len(messages) {
+		return []*pmapi.Message{}, len(messages), nil
+	}
+	stop := start + filter.PageSize
+	if stop > len(messages) {
+		stop = len(messages)
+	}
+	return messages[start:stop], len(messages), nil
+}
+
+type mockStoreRecoverer struct {
+	local      map[string]*pmapi.Message
+	updatedIDs []string
+	deletedIDs []string
+}
+
+func (m *mockStoreRecoverer) getAllMessageIDs() (ids []string, err error) {
+	for id := range m.local {
+		ids = append(ids, id)
+	}
+	return
+}
+
+func (m *mockStoreRecoverer) getMessageFromDB(apiID string) (*pmapi.Message, error) {
+	if msg, ok := m.local[apiID]; ok {
+		return msg, nil
+	}
+	return nil, ErrNoSuchAPIID
+}
+
+func (m *mockStoreRecoverer) createOrUpdateMessagesEvent(msgs []*pmapi.Message) error {
+	for _, msg := range msgs {
+		m.updatedIDs = append(m.updatedIDs, msg.ID)
+	}
+	return nil
+}
+
+func (m *mockStoreRecoverer) deleteMessagesEvent(ids []string) error {
+	m.deletedIDs = append(m.deletedIDs, ids...)
+	return nil
+}
+
+func TestRecoverByDiff(t *testing.T) {
+	unchanged := &pmapi.Message{ID: "unchanged", LabelIDs: []string{pmapi.InboxLabel, pmapi.AllMailLabel}}
+	read := &pmapi.Message{ID: "read", Unread: 1, LabelIDs: []string{pmapi.InboxLabel, pmapi.AllMailLabel}}
+	moved := &pmapi.Message{ID: "moved", LabelIDs: []string{pmapi.InboxLabel, pmapi.AllMailLabel}}
+	deleted := &pmapi.Message{ID: "deleted", LabelIDs: []string{pmapi.InboxLabel, pmapi.AllMailLabel}}
+
+	readOnAPI := *read
+	readOnAPI.Unread = 0
+	movedOnAPI := *moved
+	movedOnAPI.LabelIDs = []string{pmapi.AllMailLabel, pmapi.ArchiveLabel}
+	created := &pmapi.Message{ID: "created", LabelIDs: []string{pmapi.InboxLabel, pmapi.AllMailLabel}}
+
+	store := &mockStoreRecoverer{
+		local: map[string]*pmapi.Message{
+			unchanged.ID: unchanged,
+			read.ID:      read,
+			moved.ID:     moved,
+			deleted.ID:   deleted,
+		},
+	}
+	api := &mockLabelLister{
+		byLabels: map[string][]*pmapi.Message{
+			pmapi.InboxLabel:   {unchanged, &readOnAPI, created},
+			pmapi.ArchiveLabel: {&movedOnAPI},
+			pmapi.AllMailLabel: {unchanged, &readOnAPI, &movedOnAPI, created},
+		},
+	}
+
+	require.NoError(t, recoverByDiff(store, api))
+
+	sort.Strings(store.updatedIDs)
+	require.Equal(t, []string{"created", "moved", "read"}, store.updatedIDs)
+	require.Equal(t, []string{"deleted"}, store.deletedIDs)
+	require.Equal(t, map[string]bool{pmapi.AllMailLabel: true}, api.listed)
+}
+
+func TestRecoverByDiffPaging(t *testing.T) {
+	messages := []*pmapi.Message{}
+	for _, id := range generateIDs(1, 2*maxFilterPageSize+1) {
+		messages = append(messages, &pmapi.Message{ID: id})
+	}
+
+	store := &mockStoreRecoverer{local: map[string]*pmapi.Message{}}
+	api := &mockLabelLister{byLabels: map[string][]*pmapi.Message{pmapi.AllMailLabel: messages}}
+
+	require.NoError(t, recoverByDiff(store, api))
+	require.Len(t, store.updatedIDs, len(messages))
+	require.Empty(t, store.deletedIDs)
+}
+
+func TestRecoverByDiffFailsWithoutChanges(t *testing.T) {
+	store := &mockStoreRecoverer{
+		local: map[string]*pmapi.Message{"msg": {ID: "msg"}},
+	}
+	api := &mockLabelLister{err: errors.New("no internet")}
+
+	require.Error(t, recoverByDiff(store, api))
+	require.Empty(t, store.updatedIDs)
+	require.Empty(t, store.deletedIDs)
+}
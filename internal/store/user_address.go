@@ -74,6 +74,72 @@ func (store *Store) RebuildMailboxes() (err error) {
 	return store.initMailboxesBucket()
 }
 
+// remapMailboxes recreates address objects for the current address mode and
+// reconciles their mailboxes with the metadata bucket. Unlike RebuildMailboxes
+// it keeps the UID assignment: messages staying in a mailbox keep their UIDs,
+// messages not belonging there anymore are removed and missing ones are added
+// with new UIDs. That is all allowed without changing UIDVALIDITY.
+// Mailboxes of addresses not used in the current mode are kept in the database
+// so they can be reused when switching the mode back.
+func (store *Store) remapMailboxes() (err error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	log.WithField("user", store.UserID()).Trace("Remapping mailboxes")
+
+	store.addresses = nil
+
+	if err = store.truncateAddressInfoBucket(); err != nil {
+		log.WithError(err).Error("Could not truncate address info bucket")
+		return
+	}
+
+	if err = store.init(false); err != nil {
+		log.WithError(err).Error("Could not init store")
+		return
+	}
+
+	if err = store.pruneMailboxesBucket(); err != nil {
+		log.WithError(err).Error("Could not prune mailboxes")
+		return
+	}
+
+	return store.initMailboxesBucket()
+}
+
+// pruneMailboxesBucket removes messages which are not in the metadata bucket
+// anymore from mailboxes of all current addresses. Mailboxes of addresses
+// not used in the current mode are not updated by events, so they can contain
+// messages deleted in the meantime.
+func (store *Store) pruneMailboxesBucket() error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		metaBucket := tx.Bucket(metadataBucket)
+
+		for _, address := range store.addresses {
+			for _, mailbox := range address.mailboxes {
+				apiIDsToDelete := []string{}
+				err := mailbox.txGetAPIIDsBucket(tx).ForEach(func(apiID, _ []byte) error {
+					if metaBucket.Get(apiID) == nil {
+						apiIDsToDelete = append(apiIDsToDelete, string(apiID))
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+
+				for _, apiID := range apiIDsToDelete {
+					if err := mailbox.txDeleteMessage(tx, apiID); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
 // createOrDeleteAddressesEvent creates address objects in the store for each necessary address
 // and deletes any address objects that shouldn't be there.
 // It doesn't do anything to addresses that are rightfully there.
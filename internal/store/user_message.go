@@ -115,7 +115,11 @@ func (store *Store) createAttachment(kr *crypto.KeyRing, attachment *pmapi.Attac
 
 // SendMessage sends the message.
 func (store *Store) SendMessage(messageID string, req *pmapi.SendMessageReq) error {
-	defer store.eventLoop.pollNow()
+	// The sent message and possible replies are expected soon after the send.
+	if store.eventLoop != nil {
+		store.eventLoop.speedUp()
+		defer store.eventLoop.pollNow()
+	}
 	_, _, err := store.client().SendMessage(messageID, req)
 	return err
 }
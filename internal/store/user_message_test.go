@@ -22,6 +22,7 @@ import (
 	"testing"
 
 	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/golang/mock/gomock"
 	a "github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -154,3 +155,15 @@ func checkAllMessageIDs(t *testing.T, m *mocksForStore, wantIDs []string) {
 	require.Nil(t, allErr)
 	require.Equal(t, wantIDs, allIds)
 }
+
+func TestSendMessageWithoutEventLoop(t *testing.T) {
+	m, clear := initMocks(t)
+	defer clear()
+
+	m.user.EXPECT().ID().Return("userID").AnyTimes()
+	m.clientManager.EXPECT().GetClient("userID").Return(m.client)
+	m.client.EXPECT().SendMessage("msg1", gomock.Any()).Return(nil, nil, nil)
+
+	store := &Store{user: m.user, clientManager: m.clientManager}
+	require.NoError(t, store.SendMessage("msg1", &pmapi.SendMessageReq{}))
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"strconv"
 
+	bridgeEvents "github.com/ProtonMail/proton-bridge/internal/events"
 	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -161,6 +162,48 @@ func (store *Store) triggerSync() {
 
 		store.syncCooldown.reset()
 		syncState.setFinishTime()
+		store.events.Emit(bridgeEvents.SyncFinishedEvent, store.user.ID())
+	}()
+}
+
+// triggerRecovery brings the database up to date after the event ID was
+// refused by the API. When the previous sync was not finished or the
+// recovery fails, the full sync is triggered instead.
+func (store *Store) triggerRecovery() {
+	if !store.isSyncFinished() {
+		store.log.Info("Sync not finished, recovering by full sync")
+		store.triggerSync()
+		return
+	}
+
+	// We don't want recovery to block.
+	go func() {
+		defer store.panicHandler.HandlePanic()
+
+		store.lock.Lock()
+		if store.isSyncRunning {
+			store.lock.Unlock()
+			store.log.Info("Store sync is already ongoing, skipping recovery")
+			return
+		}
+		store.isSyncRunning = true
+		store.lock.Unlock()
+
+		store.log.Info("Store recovery started")
+
+		err := recoverByDiff(store, store.client())
+
+		store.lock.Lock()
+		store.isSyncRunning = false
+		store.lock.Unlock()
+
+		if err != nil {
+			store.log.WithError(err).Warn("Store recovery failed, triggering full sync")
+			store.triggerSync()
+			return
+		}
+
+		store.log.Info("Store recovery finished")
 	}()
 }
 
@@ -0,0 +1,164 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package credentials
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAppPasswordExists   = errors.New("app password with this name already exists")
+	ErrAppPasswordNotFound = errors.New("no active app password with this name")
+)
+
+// AppPassword is a bridge password of one mail client, such as "phone".
+// Revoked passwords are kept to show when they were revoked.
+type AppPassword struct {
+	Name     string
+	Password string
+	Created  int64
+	LastUsed int64
+	Revoked  int64
+}
+
+// IsRevoked returns whether the password cannot be used anymore.
+func (p *AppPassword) IsRevoked() bool {
+	return p.Revoked != 0
+}
+
+// FindAppPassword returns active app password matching `password` or nil.
+func (s *Credentials) FindAppPassword(password string) *AppPassword {
+	for i := range s.AppPasswords {
+		appPassword := &s.AppPasswords[i]
+		if appPassword.IsRevoked() {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(appPassword.Password), []byte(password)) == 1 {
+			return appPassword
+		}
+	}
+	return nil
+}
+
+// findActiveAppPassword returns active app password called `name` or nil.
+func (s *Credentials) findActiveAppPassword(name string) *AppPassword {
+	for i := range s.AppPasswords {
+		appPassword := &s.AppPasswords[i]
+		if !appPassword.IsRevoked() && strings.EqualFold(appPassword.Name, name) {
+			return appPassword
+		}
+	}
+	return nil
+}
+
+// AddAppPassword generates new app password called `name`.
+func (s *Store) AddAppPassword(userID, name string) (password string, err error) {
+	storeLocker.Lock()
+	defer storeLocker.Unlock()
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("app password name is empty")
+	}
+
+	credentials, err := s.get(userID)
+	if err != nil {
+		return "", err
+	}
+
+	if credentials.findActiveAppPassword(name) != nil {
+		return "", ErrAppPasswordExists
+	}
+
+	password = generatePassword()
+	credentials.AppPasswords = append(credentials.AppPasswords, AppPassword{
+		Name:     name,
+		Password: password,
+		Created:  time.Now().Unix(),
+	})
+
+	if err := s.saveCredentials(credentials); err != nil {
+		return "", err
+	}
+
+	return password, nil
+}
+
+// RevokeAppPassword makes the app password called `name` unusable.
+func (s *Store) RevokeAppPassword(userID, name string) error {
+	storeLocker.Lock()
+	defer storeLocker.Unlock()
+
+	credentials, err := s.get(userID)
+	if err != nil {
+		return err
+	}
+
+	appPassword := credentials.findActiveAppPassword(name)
+	if appPassword == nil {
+		return ErrAppPasswordNotFound
+	}
+	appPassword.Revoked = time.Now().Unix()
+	appPassword.Password = ""
+
+	return s.saveCredentials(credentials)
+}
+
+// UpdateAppPasswordLastUsed stores the time of the last login with the app password called `name`.
+func (s *Store) UpdateAppPasswordLastUsed(userID, name string, lastUsed int64) error {
+	storeLocker.Lock()
+	defer storeLocker.Unlock()
+
+	credentials, err := s.get(userID)
+	if err != nil {
+		return err
+	}
+
+	appPassword := credentials.findActiveAppPassword(name)
+	if appPassword == nil {
+		return ErrAppPasswordNotFound
+	}
+	appPassword.LastUsed = lastUsed
+
+	return s.saveCredentials(credentials)
+}
+
+// RotateBridgePassword generates new bridge password of `address`. In split
+// mode only the address gets new password if it has its own one; otherwise
+// the password shared by all addresses is changed.
+func (s *Store) RotateBridgePassword(userID, address string) error {
+	storeLocker.Lock()
+	defer storeLocker.Unlock()
+
+	credentials, err := s.get(userID)
+	if err != nil {
+		return err
+	}
+
+	address = strings.ToLower(address)
+	if _, ok := credentials.AddressPasswords[address]; ok && !credentials.IsCombinedAddressMode {
+		credentials.AddressPasswords[address] = generatePassword()
+	} else {
+		credentials.BridgePassword = generatePassword()
+	}
+
+	return s.saveCredentials(credentials)
+}
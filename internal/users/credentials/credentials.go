@@ -18,13 +18,24 @@
 // Package credentials implements our struct stored in keychain.
 // Store struct is kind of like a database client.
 // Credentials struct is kind of like one record from the database.
+//
+// Credentials keep the positional format of released versions instead of
+// a self-describing one: released versions delete secrets which do not have
+// the expected number of items as malformed, so going back to one would log
+// out all users. Fields added since then are stored as base64 of JSON in the
+// item of the deprecated IsHidden flag, which released versions read as
+// false unless it is "1". The drawback is that released versions drop the
+// item when they save the credentials; package users detects it by
+// HasExtension and warns that the extension fields were lost.
 package credentials
 
 import (
 	"crypto/subtle"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -35,25 +46,70 @@ const (
 
 	itemLengthBridge       = 9
 	itemLengthImportExport = 6 // Old format for Import/Export.
+
+	// itemExtension is the item of the deprecated IsHidden flag. Older
+	// versions read anything but "1" as false, so it holds base64 of JSON
+	// object with the fields which are not stored positionally.
+	itemExtension = 7
+
+	// formatVersion is the version of the extension. New fields can be
+	// added without changing it; it must be increased only when meaning of
+	// an existing field changes so that older versions stop reading it.
+	formatVersion    = 1
+	formatVersionKey = "FormatVersion"
 )
 
 var (
 	log = logrus.WithField("pkg", "credentials") //nolint[gochecknoglobals]
 
 	ErrWrongFormat = errors.New("malformed credentials")
+	ErrNewerFormat = errors.New("credentials were saved by a newer version")
 )
 
+// Credentials are stored in the positional format of older versions, so
+// that going back to an older version does not log the user out. Fields
+// not tagged by `json:"-"` are stored in the extension item; older versions
+// drop them when they save the credentials again.
 type Credentials struct {
-	UserID, // Do not marshal; used as a key.
-	Name,
-	Emails,
-	APIToken,
-	MailboxPassword,
-	BridgePassword,
-	Version string
-	Timestamp int64
-	IsHidden, // Deprecated.
-	IsCombinedAddressMode bool
+	UserID string `json:"-"` // Do not marshal; used as a key.
+
+	Name                  string `json:"-"`
+	Emails                string `json:"-"`
+	APIToken              string `json:"-"`
+	MailboxPassword       string `json:"-"`
+	BridgePassword        string `json:"-"`
+	Version               string `json:"-"`
+	Timestamp             int64  `json:"-"`
+	IsHidden              bool   `json:",omitempty"` // Deprecated.
+	IsCombinedAddressMode bool   `json:"-"`
+
+	// AddressPasswords holds bridge password of each address (lower-cased)
+	// in split mode. Addresses without one use BridgePassword.
+	AddressPasswords map[string]string `json:",omitempty"`
+
+	// AppPasswords are passwords of individual mail clients; accepted
+	// for all addresses in both modes.
+	AppPasswords []AppPassword `json:",omitempty"`
+
+	// LoginPassword and TOTPSecret are stored only when automatic
+	// re-authentication is enabled to log in again without the user
+	// when the refresh token expires.
+	LoginPassword string `json:",omitempty"`
+	TOTPSecret    string `json:",omitempty"`
+
+	// APIURL, APINoPinning and APINoProxy select the API host and the dialer
+	// used for the account. Zero values mean the default ones.
+	APIURL       string `json:",omitempty"`
+	APINoPinning bool   `json:",omitempty"`
+	APINoProxy   bool   `json:",omitempty"`
+
+	// unknownFields are extension fields added by newer versions. They are
+	// kept untouched so that going back and forth between versions does not
+	// lose them.
+	unknownFields map[string]json.RawMessage
+
+	// hasExtension is set when the unmarshaled secret contained the extension.
+	hasExtension bool
 }
 
 func (s *Credentials) Marshal() string {
@@ -71,9 +127,7 @@ func (s *Credentials) Marshal() string {
 
 	items[6] = fmt.Sprint(s.Timestamp)
 
-	if s.IsHidden {
-		items[7] = "1"
-	}
+	items[itemExtension] = s.marshalExtension()
 
 	if s.IsCombinedAddressMode {
 		items[8] = "1"
@@ -83,6 +137,25 @@ func (s *Credentials) Marshal() string {
 	return base64.StdEncoding.EncodeToString([]byte(str))
 }
 
+// marshalExtension encodes fields which are not stored positionally.
+func (s *Credentials) marshalExtension() string {
+	fields := make(map[string]json.RawMessage, len(s.unknownFields)+1)
+	for key, value := range s.unknownFields {
+		fields[key] = value
+	}
+
+	// Strings, numbers and maps of strings cannot fail to (un)marshal.
+	known, _ := json.Marshal(s)
+	_ = json.Unmarshal(known, &fields)
+	fields[formatVersionKey], _ = json.Marshal(formatVersion)
+
+	b, _ := json.Marshal(fields)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// Unmarshal decodes credentials written by this or older versions.
+// Credentials written by a newer version with an incompatible extension
+// return ErrNewerFormat.
 func (s *Credentials) Unmarshal(secret string) error {
 	b, err := base64.StdEncoding.DecodeString(secret)
 	if err != nil {
@@ -106,12 +179,12 @@ func (s *Credentials) Unmarshal(secret string) error {
 		if _, err = fmt.Sscan(items[6], &s.Timestamp); err != nil {
 			s.Timestamp = 0
 		}
-		if s.IsHidden = false; items[7] == "1" {
-			s.IsHidden = true
-		}
 		if s.IsCombinedAddressMode = false; items[8] == "1" {
 			s.IsCombinedAddressMode = true
 		}
+		if err := s.unmarshalExtension(items[itemExtension]); err != nil {
+			return err
+		}
 
 	case itemLengthImportExport:
 		s.Version = items[4]
@@ -122,6 +195,83 @@ func (s *Credentials) Unmarshal(secret string) error {
 	return nil
 }
 
+// unmarshalExtension reads the extension item. Older versions store
+// the IsHidden flag there instead.
+func (s *Credentials) unmarshalExtension(item string) error {
+	s.IsHidden = false
+	s.unknownFields = nil
+	s.hasExtension = false
+
+	switch item {
+	case "":
+		return nil
+	case "1":
+		s.IsHidden = true
+		return nil
+	}
+
+	b, err := base64.StdEncoding.DecodeString(item)
+	if err != nil {
+		return ErrWrongFormat
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return ErrWrongFormat
+	}
+
+	var version int
+	if err := json.Unmarshal(fields[formatVersionKey], &version); err != nil || version < 1 {
+		return ErrWrongFormat
+	}
+
+	if version > formatVersion {
+		return ErrNewerFormat
+	}
+
+	if err := json.Unmarshal(b, s); err != nil {
+		return ErrWrongFormat
+	}
+
+	for key := range fields {
+		if isKnownField(key) {
+			delete(fields, key)
+		}
+	}
+
+	if len(fields) > 0 {
+		s.unknownFields = fields
+	}
+
+	s.hasExtension = true
+
+	return nil
+}
+
+// HasExtension returns whether the credentials were read from a secret with
+// the extension item. It is false for credentials saved by older versions,
+// including the ones which were saved by this version before and then saved
+// again by an older one which dropped the extension.
+func (s *Credentials) HasExtension() bool {
+	return s.hasExtension
+}
+
+// isKnownField matches the key the same way as encoding/json does.
+func isKnownField(key string) bool {
+	if strings.EqualFold(key, formatVersionKey) {
+		return true
+	}
+
+	t := reflect.TypeOf(Credentials{})
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); field.PkgPath == "" && field.Tag.Get("json") != "-" && strings.EqualFold(field.Name, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (s *Credentials) SetEmailList(list []string) {
 	s.Emails = strings.Join(list, ";")
 }
@@ -130,10 +280,53 @@ func (s *Credentials) EmailList() []string {
 	return strings.Split(s.Emails, ";")
 }
 
-func (s *Credentials) CheckPassword(password string) error {
-	if subtle.ConstantTimeCompare([]byte(s.BridgePassword), []byte(password)) != 1 {
+// GetBridgePassword returns the bridge password to log in as `address`.
+func (s *Credentials) GetBridgePassword(address string) string {
+	if !s.IsCombinedAddressMode {
+		if password, ok := s.AddressPasswords[strings.ToLower(address)]; ok {
+			return password
+		}
+	}
+	return s.BridgePassword
+}
+
+// GenerateAddressPasswords makes sure every address has its own password.
+// Passwords of removed addresses are dropped.
+func (s *Credentials) GenerateAddressPasswords() {
+	addressPasswords := make(map[string]string)
+	for _, email := range s.EmailList() {
+		email = strings.ToLower(email)
+		if email == "" {
+			continue
+		}
+		if password, ok := s.AddressPasswords[email]; ok {
+			addressPasswords[email] = password
+		} else {
+			addressPasswords[email] = generatePassword()
+		}
+	}
+	s.AddressPasswords = addressPasswords
+}
+
+// getLoginPassword returns the bridge password accepted to log in as `address`.
+// In split mode with address passwords, only addresses of the user have one;
+// the shared password is not accepted for anything else.
+func (s *Credentials) getLoginPassword(address string) (string, bool) {
+	if s.IsCombinedAddressMode || len(s.AddressPasswords) == 0 {
+		return s.BridgePassword, true
+	}
+	password, ok := s.AddressPasswords[strings.ToLower(address)]
+	return password, ok
+}
+
+// CheckPassword checks the bridge `password` used to log in as `address`.
+// Active app passwords are accepted as well.
+func (s *Credentials) CheckPassword(address, password string) error {
+	loginPassword, ok := s.getLoginPassword(address)
+	if (!ok || subtle.ConstantTimeCompare([]byte(loginPassword), []byte(password)) != 1) && s.FindAppPassword(password) == nil {
 		log.WithFields(logrus.Fields{
-			"userID": s.UserID,
+			"userID":  s.UserID,
+			"address": address,
 		}).Debug("Incorrect bridge password")
 
 		return fmt.Errorf("backend/credentials: incorrect password")
@@ -144,6 +337,14 @@ func (s *Credentials) CheckPassword(password string) error {
 func (s *Credentials) Logout() {
 	s.APIToken = ""
 	s.MailboxPassword = ""
+	s.LoginPassword = ""
+	s.TOTPSecret = ""
+}
+
+// HasAutoReauth returns whether the user can be logged in again
+// automatically with the stored login password.
+func (s *Credentials) HasAutoReauth() bool {
+	return s.LoginPassword != ""
 }
 
 func (s *Credentials) IsConnected() bool {
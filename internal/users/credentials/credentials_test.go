@@ -44,7 +44,11 @@ func TestUnmarshallBridge(t *testing.T) {
 	encoded := wantCredentials.Marshal()
 	haveCredentials := Credentials{UserID: "1"}
 	r.NoError(t, haveCredentials.Unmarshal(encoded))
-	r.Equal(t, wantCredentials, haveCredentials)
+	r.True(t, haveCredentials.HasExtension())
+
+	creds := wantCredentials
+	creds.hasExtension = true
+	r.Equal(t, creds, haveCredentials)
 }
 
 func TestUnmarshallImportExport(t *testing.T) {
@@ -64,4 +68,190 @@ func TestUnmarshallImportExport(t *testing.T) {
 	haveCredentials.BridgePassword = wantCredentials.BridgePassword // This one is not used.
 	r.NoError(t, haveCredentials.Unmarshal(encoded))
 	r.Equal(t, wantCredentials, haveCredentials)
+	r.False(t, haveCredentials.HasExtension())
+}
+
+func TestUnmarshallBridgeWithoutAddressPasswords(t *testing.T) {
+	items := []string{
+		wantCredentials.Name,
+		wantCredentials.Emails,
+		wantCredentials.APIToken,
+		wantCredentials.MailboxPassword,
+		wantCredentials.BridgePassword,
+		"k11",
+		fmt.Sprint(wantCredentials.Timestamp),
+		"",
+		"",
+	}
+
+	str := strings.Join(items, sep)
+	encoded := base64.StdEncoding.EncodeToString([]byte(str))
+
+	haveCredentials := Credentials{UserID: "1"}
+	r.NoError(t, haveCredentials.Unmarshal(encoded))
+	r.Equal(t, wantCredentials, haveCredentials)
+	r.False(t, haveCredentials.HasExtension())
+}
+
+func TestAddressPasswords(t *testing.T) {
+	creds := wantCredentials
+	creds.hasExtension = true
+	creds.GenerateAddressPasswords()
+	r.Len(t, creds.AddressPasswords, 2)
+	r.NotEqual(t, creds.AddressPasswords["email1"], creds.AddressPasswords["email2"])
+
+	haveCredentials := Credentials{UserID: "1"}
+	r.NoError(t, haveCredentials.Unmarshal(creds.Marshal()))
+	r.Equal(t, creds, haveCredentials)
+
+	// Each address accepts only its own password in split mode.
+	r.NoError(t, creds.CheckPassword("Email1", creds.AddressPasswords["email1"]))
+	r.Error(t, creds.CheckPassword("email1", creds.AddressPasswords["email2"]))
+	r.Error(t, creds.CheckPassword("email1", creds.BridgePassword))
+	r.Error(t, creds.CheckPassword("unknown", creds.BridgePassword))
+	r.Error(t, creds.CheckPassword("unknown", creds.AddressPasswords["email1"]))
+
+	// Removed address loses its password, the other keeps it.
+	email2Password := creds.AddressPasswords["email2"]
+	creds.SetEmailList([]string{"email2", "email3"})
+	creds.GenerateAddressPasswords()
+	r.Len(t, creds.AddressPasswords, 2)
+	r.Equal(t, email2Password, creds.AddressPasswords["email2"])
+	r.NotContains(t, creds.AddressPasswords, "email1")
+
+	// Combined mode uses the shared password for all addresses.
+	creds.IsCombinedAddressMode = true
+	r.NoError(t, creds.CheckPassword("email2", creds.BridgePassword))
+}
+
+func TestAppPasswords(t *testing.T) {
+	creds := wantCredentials
+	creds.hasExtension = true
+	creds.AppPasswords = []AppPassword{
+		{Name: "phone", Password: "phone pass", Created: 1, LastUsed: 2},
+		{Name: "laptop", Created: 1, Revoked: 3},
+	}
+
+	haveCredentials := Credentials{UserID: "1"}
+	r.NoError(t, haveCredentials.Unmarshal(creds.Marshal()))
+	r.Equal(t, creds, haveCredentials)
+
+	r.NoError(t, creds.CheckPassword("email1", "phone pass"))
+	r.NoError(t, creds.CheckPassword("email2", "phone pass"))
+	r.Error(t, creds.CheckPassword("email1", ""))
+	r.Equal(t, "phone", creds.FindAppPassword("phone pass").Name)
+	r.Nil(t, creds.FindAppPassword("other"))
+
+	creds.AppPasswords[0].Revoked = 4
+	r.Error(t, creds.CheckPassword("email1", "phone pass"))
+}
+
+// unmarshalReleased reads the secret the same way as versions without
+// the extension item do.
+func unmarshalReleased(t *testing.T, secret string) []string {
+	b, err := base64.StdEncoding.DecodeString(secret)
+	r.NoError(t, err)
+
+	items := strings.Split(string(b), sep)
+	r.Len(t, items, itemLengthBridge)
+	return items
+}
+
+func encodeWithExtension(extension string) string {
+	items := []string{"name", "email1", "token", "mailbox pass", "bridge pass", "k11", "1", "", ""}
+	items[itemExtension] = base64.StdEncoding.EncodeToString([]byte(extension))
+	return base64.StdEncoding.EncodeToString([]byte(strings.Join(items, sep)))
+}
+
+func TestMarshalReadableByOlderVersions(t *testing.T) {
+	creds := wantCredentials
+	creds.hasExtension = true
+	creds.IsCombinedAddressMode = true
+	creds.AddressPasswords = map[string]string{"email1": "pass1"}
+	creds.AppPasswords = []AppPassword{{Name: "phone", Password: "phone pass", Created: 1}}
+	creds.LoginPassword = "login pass"
+
+	items := unmarshalReleased(t, creds.Marshal())
+	r.Equal(t, []string{
+		creds.Name,
+		creds.Emails,
+		creds.APIToken,
+		creds.MailboxPassword,
+		creds.BridgePassword,
+		creds.Version,
+		fmt.Sprint(creds.Timestamp),
+	}, items[:7])
+	r.NotEqual(t, "1", items[itemExtension], "older versions must not read it as hidden")
+	r.Equal(t, "1", items[8])
+	r.NotContains(t, items[itemExtension], "pass")
+
+	haveCredentials := Credentials{UserID: "1"}
+	r.NoError(t, haveCredentials.Unmarshal(creds.Marshal()))
+	r.Equal(t, creds, haveCredentials)
+
+	// Older version saving the credentials drops the extension.
+	items[itemExtension] = ""
+	older := base64.StdEncoding.EncodeToString([]byte(strings.Join(items, sep)))
+
+	haveCredentials = Credentials{UserID: "1"}
+	r.NoError(t, haveCredentials.Unmarshal(older))
+	r.Equal(t, creds.BridgePassword, haveCredentials.BridgePassword)
+	r.Nil(t, haveCredentials.AddressPasswords)
+	r.Nil(t, haveCredentials.AppPasswords)
+	r.False(t, haveCredentials.HasExtension())
+}
+
+func TestUnmarshallHidden(t *testing.T) {
+	creds := wantCredentials
+	creds.hasExtension = true
+	creds.IsHidden = true
+
+	haveCredentials := Credentials{UserID: "1"}
+	r.NoError(t, haveCredentials.Unmarshal(creds.Marshal()))
+	r.Equal(t, creds, haveCredentials)
+
+	items := unmarshalReleased(t, creds.Marshal())
+	items[itemExtension] = "1"
+	released := base64.StdEncoding.EncodeToString([]byte(strings.Join(items, sep)))
+
+	haveCredentials = Credentials{UserID: "1"}
+	r.NoError(t, haveCredentials.Unmarshal(released))
+	r.True(t, haveCredentials.IsHidden)
+	r.False(t, haveCredentials.HasExtension())
+}
+
+func TestUnmarshallKeepsUnknownFields(t *testing.T) {
+	encoded := encodeWithExtension(`{"FormatVersion":1,"NewField":{"a":1},"OtherField":"x"}`)
+
+	haveCredentials := Credentials{UserID: "1"}
+	r.NoError(t, haveCredentials.Unmarshal(encoded))
+	r.Equal(t, "name", haveCredentials.Name)
+	r.Equal(t, "1", haveCredentials.UserID)
+
+	haveCredentials.Name = "changed"
+	items := unmarshalReleased(t, haveCredentials.Marshal())
+	r.Equal(t, "changed", items[0])
+
+	b, err := base64.StdEncoding.DecodeString(items[itemExtension])
+	r.NoError(t, err)
+	r.Contains(t, string(b), `"NewField":{"a":1}`)
+	r.Contains(t, string(b), `"OtherField":"x"`)
+	r.NotContains(t, string(b), "Name")
+	r.NotContains(t, string(b), "UserID")
+}
+
+func TestUnmarshallNewerOrWrongFormat(t *testing.T) {
+	for extension, wantErr := range map[string]error{
+		`{"FormatVersion":2}`:                  ErrNewerFormat,
+		`{"LoginPassword":"pass"}`:             ErrWrongFormat,
+		`{"FormatVersion":"1"}`:                ErrWrongFormat,
+		`{"FormatVersion":1,"AppPasswords":1}`: ErrWrongFormat,
+		`not JSON`:                             ErrWrongFormat,
+	} {
+		haveCredentials := Credentials{UserID: "1"}
+		r.Equal(t, wantErr, haveCredentials.Unmarshal(encodeWithExtension(extension)), extension)
+	}
+
+	haveCredentials := Credentials{UserID: "1"}
+	r.Equal(t, ErrWrongFormat, haveCredentials.Unmarshal(base64.StdEncoding.EncodeToString([]byte("a\x00b"))))
 }
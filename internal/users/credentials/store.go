@@ -31,15 +31,57 @@ var storeLocker = sync.RWMutex{} //nolint[gochecknoglobals]
 
 // Store is an encrypted credentials store.
 type Store struct {
-	secrets *keychain.Access
+	secrets      *keychain.Access
+	openKeychain func() (*keychain.Access, error)
 }
 
 // NewStore creates a new encrypted credentials store.
 func NewStore(appName string) (*Store, error) {
 	secrets, err := keychain.NewAccess(appName)
+	return NewStoreWithKeychain(secrets), err
+}
+
+// NewStoreWithKeychain creates a new encrypted credentials store using `secrets`.
+func NewStoreWithKeychain(secrets *keychain.Access) *Store {
 	return &Store{
 		secrets: secrets,
-	}, err
+	}
+}
+
+// NewStoreWithOpener creates a new encrypted credentials store using `secrets`.
+// When `secrets` is nil because the keychain could not be opened, Reconnect
+// tries to open it again by `openKeychain`.
+func NewStoreWithOpener(secrets *keychain.Access, openKeychain func() (*keychain.Access, error)) *Store {
+	return &Store{
+		secrets:      secrets,
+		openKeychain: openKeychain,
+	}
+}
+
+// Reconnect opens the keychain if it could not be opened before and checks
+// that it is not locked. The keychain is opened without holding the store
+// lock, so reading credentials is not blocked in the meantime.
+func (s *Store) Reconnect() error {
+	storeLocker.RLock()
+	secrets := s.secrets
+	storeLocker.RUnlock()
+
+	if secrets == nil && s.openKeychain != nil {
+		var err error
+		if secrets, err = s.openKeychain(); err != nil {
+			return err
+		}
+
+		storeLocker.Lock()
+		s.secrets = secrets
+		storeLocker.Unlock()
+	}
+
+	if secrets == nil {
+		return keychain.ErrNoKeychainInstalled
+	}
+
+	return secrets.CheckUnlocked()
 }
 
 func (s *Store) Add(userID, userName, apiToken, mailboxPassword string, emails []string) (creds *Credentials, err error) {
@@ -71,7 +113,12 @@ func (s *Store) Add(userID, userName, apiToken, mailboxPassword string, emails [
 		log.Info("Updating credentials of existing user")
 		creds.BridgePassword = currentCredentials.BridgePassword
 		creds.IsCombinedAddressMode = currentCredentials.IsCombinedAddressMode
+		creds.AddressPasswords = currentCredentials.AddressPasswords
+		creds.AppPasswords = currentCredentials.AppPasswords
 		creds.Timestamp = currentCredentials.Timestamp
+		if creds.AddressPasswords != nil {
+			creds.GenerateAddressPasswords()
+		}
 	} else {
 		log.Info("Generating credentials for new user")
 		creds.BridgePassword = generatePassword()
@@ -98,6 +145,12 @@ func (s *Store) SwitchAddressMode(userID string) error {
 	credentials.IsCombinedAddressMode = !credentials.IsCombinedAddressMode
 	credentials.BridgePassword = generatePassword()
 
+	// Each address gets its own password in split mode.
+	credentials.AddressPasswords = nil
+	if !credentials.IsCombinedAddressMode {
+		credentials.GenerateAddressPasswords()
+	}
+
 	return s.saveCredentials(credentials)
 }
 
@@ -112,6 +165,12 @@ func (s *Store) UpdateEmails(userID string, emails []string) error {
 
 	credentials.SetEmailList(emails)
 
+	// Credentials from older versions do not have address passwords;
+	// all addresses keep using the shared one until the mode is switched.
+	if credentials.AddressPasswords != nil {
+		credentials.GenerateAddressPasswords()
+	}
+
 	return s.saveCredentials(credentials)
 }
 
@@ -143,6 +202,45 @@ func (s *Store) UpdateToken(userID, apiToken string) error {
 	return s.saveCredentials(credentials)
 }
 
+// SetAutoReauth stores the login password and the TOTP secret (empty for
+// accounts without 2FA) for automatic re-authentication. Empty password
+// disables it and removes both.
+func (s *Store) SetAutoReauth(userID, loginPassword, totpSecret string) error {
+	storeLocker.Lock()
+	defer storeLocker.Unlock()
+
+	credentials, err := s.get(userID)
+	if err != nil {
+		return err
+	}
+
+	if loginPassword == "" {
+		totpSecret = ""
+	}
+
+	credentials.LoginPassword = loginPassword
+	credentials.TOTPSecret = totpSecret
+
+	return s.saveCredentials(credentials)
+}
+
+// SetAPIHost sets the API host and dialer settings of the account.
+func (s *Store) SetAPIHost(userID, apiURL string, noPinning, noProxy bool) error {
+	storeLocker.Lock()
+	defer storeLocker.Unlock()
+
+	credentials, err := s.get(userID)
+	if err != nil {
+		return err
+	}
+
+	credentials.APIURL = apiURL
+	credentials.APINoPinning = noPinning
+	credentials.APINoProxy = noProxy
+
+	return s.saveCredentials(credentials)
+}
+
 func (s *Store) Logout(userID string) error {
 	storeLocker.Lock()
 	defer storeLocker.Unlock()
@@ -175,8 +273,18 @@ func (s *Store) List() (userIDs []string, err error) {
 	}
 
 	credentialList := []*Credentials{}
+	unreadableUserIDs := []string{}
 	for _, userID := range allUserIDs {
-		creds, getErr := s.get(userID)
+		secret, readErr := s.secrets.Get(userID)
+		if readErr != nil {
+			// Locked collection lists secrets which cannot be read. They are
+			// listed anyway so that loading them fails until it is unlocked.
+			log.WithField("userID", userID).WithError(readErr).Warn("Failed to read credentials")
+			unreadableUserIDs = append(unreadableUserIDs, userID)
+			continue
+		}
+
+		creds, getErr := s.unmarshal(userID, secret)
 		if getErr != nil {
 			log.WithField("userID", userID).WithError(getErr).Warn("Failed to get credentials")
 			continue
@@ -197,10 +305,10 @@ func (s *Store) List() (userIDs []string, err error) {
 		userIDs = append(userIDs, credentials.UserID)
 	}
 
-	return userIDs, err
+	return append(userIDs, unreadableUserIDs...), err
 }
 
-func (s *Store) GetAndCheckPassword(userID, password string) (creds *Credentials, err error) {
+func (s *Store) GetAndCheckPassword(userID, address, password string) (creds *Credentials, err error) {
 	storeLocker.RLock()
 	defer storeLocker.RUnlock()
 
@@ -213,7 +321,7 @@ func (s *Store) GetAndCheckPassword(userID, password string) (creds *Credentials
 		return nil, err
 	}
 
-	if err := credentials.CheckPassword(password); err != nil {
+	if err := credentials.CheckPassword(address, password); err != nil {
 		log.WithFields(logrus.Fields{
 			"userID": userID,
 			"err":    err,
@@ -245,8 +353,20 @@ func (s *Store) get(userID string) (creds *Credentials, err error) {
 		return
 	}
 
+	return s.unmarshal(userID, secret)
+}
+
+func (s *Store) unmarshal(userID, secret string) (creds *Credentials, err error) {
+	log := log.WithField("user", userID)
+
 	credentials := &Credentials{UserID: userID}
 	if err = credentials.Unmarshal(secret); err != nil {
+		if err == ErrNewerFormat {
+			// Keep the secret for the newer version; the user can upgrade
+			// back without logging in again.
+			log.WithError(err).Error("Could not read credentials saved by a newer version")
+			return nil, err
+		}
 		err = fmt.Errorf("backend/credentials: malformed secret: %v", err)
 		_ = s.secrets.Delete(userID)
 		log.WithError(err).Error("Could not unmarshal secret")
@@ -22,10 +22,15 @@ import (
 	"encoding/base64"
 	"encoding/gob"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/ProtonMail/proton-bridge/pkg/keychain"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -293,5 +298,125 @@ func TestMarshal(t *testing.T) {
 	output := Credentials{APIToken: "refresh"}
 	require.NoError(t, output.Unmarshal(secret))
 	fmt.Printf("output %#v\n", output)
+	assert.True(t, output.HasExtension())
+
+	input.hasExtension = true
 	assert.Equal(t, input, output)
 }
+
+func newTestStore(t *testing.T, dir string) (*Store, *keychain.Access) {
+	helper, err := keychain.NewFileHelper(filepath.Join(dir, "keychain.asc"), []byte("passphrase"))
+	require.NoError(t, err)
+
+	secrets := keychain.NewAccessWithHelper("bridge", helper)
+	return NewStoreWithKeychain(secrets), secrets
+}
+
+func TestStoreDoesNotRewriteSecretOnRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint[errcheck]
+
+	store, secrets := newTestStore(t, dir)
+
+	items := []string{"name", "email1", "token", "mailbox pass", "bridge pass", "k11", "1", "", ""}
+	released := base64.StdEncoding.EncodeToString([]byte(strings.Join(items, sep)))
+	require.NoError(t, secrets.Put("1", released))
+
+	creds, err := store.Get("1")
+	require.NoError(t, err)
+	require.Equal(t, "bridge pass", creds.BridgePassword)
+
+	secret, err := secrets.Get("1")
+	require.NoError(t, err)
+	require.Equal(t, released, secret)
+}
+
+func TestStoreKeepsNewerSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint[errcheck]
+
+	store, secrets := newTestStore(t, dir)
+
+	newer := encodeWithExtension(`{"FormatVersion":100}`)
+	require.NoError(t, secrets.Put("1", newer))
+
+	_, err = store.Get("1")
+	require.Equal(t, ErrNewerFormat, err)
+
+	secret, err := secrets.Get("1")
+	require.NoError(t, err)
+	require.Equal(t, newer, secret)
+}
+
+func TestStoreReconnect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint[errcheck]
+
+	errLocked := errors.New("keychain is locked")
+	unlocked := false
+	store := NewStoreWithOpener(nil, func() (*keychain.Access, error) {
+		if !unlocked {
+			return nil, errLocked
+		}
+		_, secrets := newTestStore(t, dir)
+		return secrets, nil
+	})
+
+	_, err = store.List()
+	require.Error(t, err)
+	require.Equal(t, errLocked, store.Reconnect())
+
+	unlocked = true
+	require.NoError(t, store.Reconnect())
+
+	userIDs, err := store.List()
+	require.NoError(t, err)
+	require.Empty(t, userIDs)
+}
+
+// lockedHelper lists secrets of a locked collection but cannot read them.
+type lockedHelper struct {
+	*keychain.FileHelper
+	locked bool
+	reads  int
+}
+
+func (h *lockedHelper) Get(serverURL string) (string, string, error) {
+	h.reads++
+	if h.locked {
+		return "", "", errors.New("collection is locked")
+	}
+	return h.FileHelper.Get(serverURL)
+}
+
+func TestStoreReconnectLockedCollection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint[errcheck]
+
+	fileHelper, err := keychain.NewFileHelper(filepath.Join(dir, "keychain.asc"), []byte("passphrase"))
+	require.NoError(t, err)
+
+	helper := &lockedHelper{FileHelper: fileHelper}
+	store := NewStoreWithKeychain(keychain.NewAccessWithHelper("bridge", helper))
+
+	_, err = store.Add("user", "name", "token", "mailbox pass", []string{"user@pm.me"})
+	require.NoError(t, err)
+	_, err = store.Add("user2", "name2", "token", "mailbox pass", []string{"user2@pm.me"})
+	require.NoError(t, err)
+
+	// Checking the lock reads only one secret.
+	helper.reads = 0
+	require.NoError(t, store.Reconnect())
+	require.Equal(t, 1, helper.reads)
+
+	helper.locked = true
+
+	userIDs, err := store.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{"user", "user2"}, userIDs)
+	require.EqualError(t, store.Reconnect(), "collection is locked")
+}
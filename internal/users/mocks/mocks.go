@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	io "io"
 	reflect "reflect"
 
 	store "github.com/ProtonMail/proton-bridge/internal/store"
@@ -188,6 +189,20 @@ func (mr *MockClientManagerMockRecorder) GetAnonymousClient() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAnonymousClient", reflect.TypeOf((*MockClientManager)(nil).GetAnonymousClient))
 }
 
+// GetAnonymousClientWithHost mocks base method
+func (m *MockClientManager) GetAnonymousClientWithHost(arg0 pmapi.HostSettings) pmapi.Client {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAnonymousClientWithHost", arg0)
+	ret0, _ := ret[0].(pmapi.Client)
+	return ret0
+}
+
+// GetAnonymousClientWithHost indicates an expected call of GetAnonymousClientWithHost
+func (mr *MockClientManagerMockRecorder) GetAnonymousClientWithHost(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAnonymousClientWithHost", reflect.TypeOf((*MockClientManager)(nil).GetAnonymousClientWithHost), arg0)
+}
+
 // GetAuthUpdateChannel mocks base method
 func (m *MockClientManager) GetAuthUpdateChannel() chan pmapi.ClientAuth {
 	m.ctrl.T.Helper()
@@ -216,6 +231,30 @@ func (mr *MockClientManagerMockRecorder) GetClient(arg0 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetClient", reflect.TypeOf((*MockClientManager)(nil).GetClient), arg0)
 }
 
+// SetAutoReauth mocks base method
+func (m *MockClientManager) SetAutoReauth(arg0 string, arg1 bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetAutoReauth", arg0, arg1)
+}
+
+// SetAutoReauth indicates an expected call of SetAutoReauth
+func (mr *MockClientManagerMockRecorder) SetAutoReauth(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAutoReauth", reflect.TypeOf((*MockClientManager)(nil).SetAutoReauth), arg0, arg1)
+}
+
+// SetHostSettings mocks base method
+func (m *MockClientManager) SetHostSettings(arg0 string, arg1 pmapi.HostSettings) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetHostSettings", arg0, arg1)
+}
+
+// SetHostSettings indicates an expected call of SetHostSettings
+func (mr *MockClientManagerMockRecorder) SetHostSettings(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetHostSettings", reflect.TypeOf((*MockClientManager)(nil).SetHostSettings), arg0, arg1)
+}
+
 // SetUserAgent mocks base method
 func (m *MockClientManager) SetUserAgent(arg0, arg1, arg2 string) {
 	m.ctrl.T.Helper()
@@ -266,6 +305,21 @@ func (mr *MockCredentialsStorerMockRecorder) Add(arg0, arg1, arg2, arg3, arg4 in
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockCredentialsStorer)(nil).Add), arg0, arg1, arg2, arg3, arg4)
 }
 
+// AddAppPassword mocks base method
+func (m *MockCredentialsStorer) AddAppPassword(arg0, arg1 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAppPassword", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddAppPassword indicates an expected call of AddAppPassword
+func (mr *MockCredentialsStorerMockRecorder) AddAppPassword(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAppPassword", reflect.TypeOf((*MockCredentialsStorer)(nil).AddAppPassword), arg0, arg1)
+}
+
 // Delete mocks base method
 func (m *MockCredentialsStorer) Delete(arg0 string) error {
 	m.ctrl.T.Helper()
@@ -324,6 +378,76 @@ func (mr *MockCredentialsStorerMockRecorder) Logout(arg0 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockCredentialsStorer)(nil).Logout), arg0)
 }
 
+// Reconnect mocks base method
+func (m *MockCredentialsStorer) Reconnect() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reconnect")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reconnect indicates an expected call of Reconnect
+func (mr *MockCredentialsStorerMockRecorder) Reconnect() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reconnect", reflect.TypeOf((*MockCredentialsStorer)(nil).Reconnect))
+}
+
+// RevokeAppPassword mocks base method
+func (m *MockCredentialsStorer) RevokeAppPassword(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAppPassword", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAppPassword indicates an expected call of RevokeAppPassword
+func (mr *MockCredentialsStorerMockRecorder) RevokeAppPassword(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAppPassword", reflect.TypeOf((*MockCredentialsStorer)(nil).RevokeAppPassword), arg0, arg1)
+}
+
+// RotateBridgePassword mocks base method
+func (m *MockCredentialsStorer) RotateBridgePassword(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateBridgePassword", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RotateBridgePassword indicates an expected call of RotateBridgePassword
+func (mr *MockCredentialsStorerMockRecorder) RotateBridgePassword(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateBridgePassword", reflect.TypeOf((*MockCredentialsStorer)(nil).RotateBridgePassword), arg0, arg1)
+}
+
+// SetAPIHost mocks base method
+func (m *MockCredentialsStorer) SetAPIHost(arg0, arg1 string, arg2, arg3 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAPIHost", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAPIHost indicates an expected call of SetAPIHost
+func (mr *MockCredentialsStorerMockRecorder) SetAPIHost(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAPIHost", reflect.TypeOf((*MockCredentialsStorer)(nil).SetAPIHost), arg0, arg1, arg2, arg3)
+}
+
+// SetAutoReauth mocks base method
+func (m *MockCredentialsStorer) SetAutoReauth(arg0, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAutoReauth", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAutoReauth indicates an expected call of SetAutoReauth
+func (mr *MockCredentialsStorerMockRecorder) SetAutoReauth(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAutoReauth", reflect.TypeOf((*MockCredentialsStorer)(nil).SetAutoReauth), arg0, arg1, arg2)
+}
+
 // SwitchAddressMode mocks base method
 func (m *MockCredentialsStorer) SwitchAddressMode(arg0 string) error {
 	m.ctrl.T.Helper()
@@ -338,6 +462,20 @@ func (mr *MockCredentialsStorerMockRecorder) SwitchAddressMode(arg0 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SwitchAddressMode", reflect.TypeOf((*MockCredentialsStorer)(nil).SwitchAddressMode), arg0)
 }
 
+// UpdateAppPasswordLastUsed mocks base method
+func (m *MockCredentialsStorer) UpdateAppPasswordLastUsed(arg0, arg1 string, arg2 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAppPasswordLastUsed", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAppPasswordLastUsed indicates an expected call of UpdateAppPasswordLastUsed
+func (mr *MockCredentialsStorerMockRecorder) UpdateAppPasswordLastUsed(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAppPasswordLastUsed", reflect.TypeOf((*MockCredentialsStorer)(nil).UpdateAppPasswordLastUsed), arg0, arg1, arg2)
+}
+
 // UpdateEmails mocks base method
 func (m *MockCredentialsStorer) UpdateEmails(arg0 string, arg1 []string) error {
 	m.ctrl.T.Helper()
@@ -403,6 +541,20 @@ func (m *MockStoreMaker) EXPECT() *MockStoreMakerMockRecorder {
 	return m.recorder
 }
 
+// Import mocks base method
+func (m *MockStoreMaker) Import(arg0 string, arg1 io.Reader, arg2 []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Import", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Import indicates an expected call of Import
+func (mr *MockStoreMakerMockRecorder) Import(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Import", reflect.TypeOf((*MockStoreMaker)(nil).Import), arg0, arg1, arg2)
+}
+
 // New mocks base method
 func (m *MockStoreMaker) New(arg0 store.BridgeUser) (*store.Store, error) {
 	m.ctrl.T.Helper()
@@ -18,6 +18,8 @@
 package users
 
 import (
+	"io"
+
 	"github.com/ProtonMail/proton-bridge/internal/store"
 	"github.com/ProtonMail/proton-bridge/internal/users/credentials"
 	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
@@ -41,6 +43,13 @@ type CredentialsStorer interface {
 	UpdateEmails(userID string, emails []string) error
 	UpdatePassword(userID, password string) error
 	UpdateToken(userID, apiToken string) error
+	AddAppPassword(userID, name string) (string, error)
+	RevokeAppPassword(userID, name string) error
+	UpdateAppPasswordLastUsed(userID, name string, lastUsed int64) error
+	RotateBridgePassword(userID, address string) error
+	SetAutoReauth(userID, loginPassword, totpSecret string) error
+	SetAPIHost(userID, apiURL string, noPinning, noProxy bool) error
+	Reconnect() error
 	Logout(userID string) error
 	Delete(userID string) error
 }
@@ -48,6 +57,9 @@ type CredentialsStorer interface {
 type ClientManager interface {
 	GetClient(userID string) pmapi.Client
 	GetAnonymousClient() pmapi.Client
+	GetAnonymousClientWithHost(settings pmapi.HostSettings) pmapi.Client
+	SetHostSettings(userID string, settings pmapi.HostSettings)
+	SetAutoReauth(userID string, enabled bool)
 	AllowProxy()
 	DisallowProxy()
 	GetAuthUpdateChannel() chan pmapi.ClientAuth
@@ -58,4 +70,5 @@ type ClientManager interface {
 type StoreMaker interface {
 	New(user store.BridgeUser) (*store.Store, error)
 	Remove(userID string) error
+	Import(userID string, archive io.Reader, passphrase []byte) error
 }
@@ -18,15 +18,18 @@
 package users
 
 import (
+	"io"
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ProtonMail/proton-bridge/internal/events"
 	"github.com/ProtonMail/proton-bridge/internal/store"
 	"github.com/ProtonMail/proton-bridge/internal/users/credentials"
 	"github.com/ProtonMail/proton-bridge/pkg/listener"
 	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/ProtonMail/proton-bridge/pkg/totp"
 	imapBackend "github.com/emersion/go-imap/backend"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -35,6 +38,10 @@ import (
 // ErrLoggedOutUser is sent to IMAP and SMTP if user exists, password is OK but user is logged out from the app.
 var ErrLoggedOutUser = errors.New("account is logged out, use the app to login again")
 
+// appPasswordLastUsedPrecision limits how often the last use of an app
+// password is saved; clients log in very often and keychain is slow.
+const appPasswordLastUsedPrecision = time.Hour
+
 // User is a struct on top of API client and credentials store.
 type User struct {
 	log           *logrus.Entry
@@ -83,6 +90,14 @@ func newUser(
 		creds:         creds,
 	}
 
+	if host := u.GetHostSettings(); !host.IsDefault() {
+		clientManager.SetHostSettings(userID, host)
+	}
+
+	if creds.HasAutoReauth() {
+		clientManager.SetAutoReauth(userID, true)
+	}
+
 	return
 }
 
@@ -134,12 +149,32 @@ func (u *User) init(idleUpdates chan imapBackend.Update) (err error) {
 	}
 	u.store = store
 
+	u.checkCredentialsExtension()
+
 	// Save the imap updates channel here so it can be set later when imap connects.
 	u.imapUpdatesChannel = idleUpdates
 
 	return err
 }
 
+// checkCredentialsExtension warns when the credentials lost their extension
+// item because an older version saved them after this one.
+func (u *User) checkCredentialsExtension() {
+	if u.creds.HasExtension() == u.store.HadCredentialsExtension() {
+		return
+	}
+
+	if !u.creds.HasExtension() {
+		u.log.Error("Credentials were saved by an older version: app passwords, " +
+			"passwords of addresses in split mode, automatic re-authentication " +
+			"and API settings of the account were lost and must be set up again")
+	}
+
+	if err := u.store.SetHadCredentialsExtension(u.creds.HasExtension()); err != nil {
+		u.log.WithError(err).Warn("Could not save whether credentials have extension")
+	}
+}
+
 func (u *User) SetIMAPIdleUpdateChannel() {
 	if u.store == nil {
 		return
@@ -210,7 +245,14 @@ func (u *User) authorizeAndUnlock() (err error) {
 	}
 
 	if _, err := u.client().AuthRefresh(u.creds.APIToken); err != nil {
-		return errors.Wrap(err, "failed to refresh API auth")
+		if !u.canReauthorize(err) {
+			return errors.Wrap(err, "failed to refresh API auth")
+		}
+
+		u.log.WithError(err).Warn("Could not refresh API auth, logging in again")
+		if err := u.reauthorize(); err != nil {
+			return errors.Wrap(err, "failed to re-authenticate")
+		}
 	}
 
 	if err := u.client().Unlock([]byte(u.creds.MailboxPassword)); err != nil {
@@ -220,6 +262,169 @@ func (u *User) authorizeAndUnlock() (err error) {
 	return nil
 }
 
+// canReauthorize returns whether the failed auth can be fixed by logging
+// in again with the stored login password.
+func (u *User) canReauthorize(err error) bool {
+	switch errors.Cause(err) {
+	case pmapi.ErrAPINotReachable, pmapi.ErrUpgradeApplication:
+		return false
+	}
+
+	return u.creds.HasAutoReauth()
+}
+
+// reauthorize logs the user in again with the stored login password and
+// TOTP secret. The new auth is saved by Users.watchAPIAuths.
+func (u *User) reauthorize() error {
+	if _, err := authWithSecrets(u.client(), u.creds.Name, u.creds.LoginPassword, u.creds.TOTPSecret); err != nil {
+		return err
+	}
+
+	u.log.Info("User was re-authenticated automatically")
+
+	return nil
+}
+
+// reauthorizeOrLogout is called when the refresh token is not valid anymore.
+func (u *User) reauthorizeOrLogout() {
+	defer u.panicHandler.HandlePanic()
+
+	u.lock.Lock()
+	err := u.reauthorize()
+	if err == nil {
+		err = u.unlockIfNecessary()
+	}
+	u.lock.Unlock()
+
+	if err == nil {
+		return
+	}
+
+	u.log.WithError(err).Error("Automatic re-authentication failed")
+
+	if err := u.logout(); err != nil {
+		u.log.WithError(err).Error("User logout failed after automatic re-authentication")
+	}
+}
+
+// authWithSecrets authenticates the client and completes two factor
+// authentication with code generated from the TOTP secret if needed.
+func authWithSecrets(client pmapi.Client, username, password, totpSecret string) (*pmapi.Auth, error) {
+	auth, err := client.Auth(username, password, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to authenticate")
+	}
+
+	if !auth.HasTwoFactor() {
+		return auth, nil
+	}
+
+	if !auth.HasTOTP() {
+		return nil, errors.New("only security key is enabled as second factor, it cannot be used automatically")
+	}
+
+	if totpSecret == "" {
+		return nil, errors.New("two factor code is required but no TOTP secret is stored")
+	}
+
+	code, err := totp.Generate(totpSecret, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := client.Auth2FA(code, auth); err != nil {
+		return nil, errors.Wrap(err, "failed to authenticate with two factor code")
+	}
+
+	return auth, nil
+}
+
+// GetHostSettings returns the API host and dialer settings of the account.
+func (u *User) GetHostSettings() pmapi.HostSettings {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	return pmapi.HostSettings{
+		URL:       u.creds.APIURL,
+		NoPinning: u.creds.APINoPinning,
+		NoProxy:   u.creds.APINoProxy,
+	}
+}
+
+// HasAutoReauth returns whether automatic re-authentication is enabled.
+func (u *User) HasAutoReauth() bool {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	return u.creds.HasAutoReauth()
+}
+
+// EnableAutoReauth stores the login password and the TOTP secret (empty
+// for accounts without 2FA) in the keychain so that the user is logged in
+// again automatically when the refresh token expires. The password is
+// verified by a test login; the two factor step is not done because
+// the code could have been just used by the user.
+func (u *User) EnableAutoReauth(loginPassword, totpSecret string) error {
+	if loginPassword == "" {
+		return errors.New("login password is required")
+	}
+
+	if totpSecret != "" {
+		if _, err := totp.ParseSecret(totpSecret); err != nil {
+			return err
+		}
+	}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	client := u.clientManager.GetAnonymousClient()
+	defer client.Logout()
+
+	auth, err := client.Auth(u.creds.Name, loginPassword, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to authenticate")
+	}
+
+	if err := client.DeleteAuth(); err != nil {
+		u.log.WithError(err).Warn("Could not delete test login session")
+	}
+
+	if auth.HasTwoFactor() && !auth.HasTOTP() {
+		return errors.New("account uses security key as the only second factor, it cannot be used automatically")
+	}
+
+	if auth.HasTwoFactor() && totpSecret == "" {
+		return errors.New("account uses two factor authentication, TOTP secret is required")
+	}
+
+	if err := u.credStorer.SetAutoReauth(u.userID, loginPassword, totpSecret); err != nil {
+		return err
+	}
+
+	u.clientManager.SetAutoReauth(u.userID, true)
+
+	u.refreshFromCredentials()
+
+	return nil
+}
+
+// DisableAutoReauth removes the login password and the TOTP secret.
+func (u *User) DisableAutoReauth() error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if err := u.credStorer.SetAutoReauth(u.userID, "", ""); err != nil {
+		return err
+	}
+
+	u.clientManager.SetAutoReauth(u.userID, false)
+
+	u.refreshFromCredentials()
+
+	return nil
+}
+
 func (u *User) updateAuthToken(auth *pmapi.Auth) {
 	u.log.Debug("User received auth")
 
@@ -369,6 +574,109 @@ func (u *User) GetAddressID(address string) (id string, err error) {
 	return u.store.GetAddressID(address)
 }
 
+// ExportStore writes the local store of the user to `w` encrypted by the hashed
+// mailbox password, so it can be imported on another machine during login.
+func (u *User) ExportStore(w io.Writer) error {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	if u.store == nil {
+		return errors.New("store is not initialised")
+	}
+
+	if u.creds == nil || u.creds.MailboxPassword == "" {
+		return errors.New("mailbox password is not available, login first")
+	}
+
+	return u.store.Export(w, []byte(u.creds.MailboxPassword))
+}
+
+// ListOutgoing returns messages waiting in the outbox to be sent.
+func (u *User) ListOutgoing() ([]*store.OutgoingMessage, error) {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	if u.store == nil {
+		return nil, errors.New("store is not initialised")
+	}
+
+	return u.store.ListOutgoing()
+}
+
+// CancelOutgoing removes the message with `id` from the outbox so it is never sent.
+func (u *User) CancelOutgoing(id string) error {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	if u.store == nil {
+		return errors.New("store is not initialised")
+	}
+
+	return u.store.CancelOutgoing(id)
+}
+
+// ListScheduledSends returns drafts waiting to be sent at the scheduled time.
+func (u *User) ListScheduledSends() ([]*store.ScheduledSend, error) {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	if u.store == nil {
+		return nil, errors.New("store is not initialised")
+	}
+
+	return u.store.ListScheduledSends()
+}
+
+// CancelScheduledSend removes the draft with `messageID` from the schedule.
+// The draft is kept in the Drafts folder.
+func (u *User) CancelScheduledSend(messageID string) error {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	if u.store == nil {
+		return errors.New("store is not initialised")
+	}
+
+	return u.store.CancelScheduledSend(messageID)
+}
+
+// GetSenderAliases returns aliases the user can send messages from as a map
+// from alias to the owned address used for sending.
+func (u *User) GetSenderAliases() (map[string]string, error) {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	if u.store == nil {
+		return nil, errors.New("store is not initialised")
+	}
+
+	return u.store.GetSenderAliases()
+}
+
+// SetSenderAlias allows to send messages from `alias` using the owned `address`.
+func (u *User) SetSenderAlias(alias, address string) error {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	if u.store == nil {
+		return errors.New("store is not initialised")
+	}
+
+	return u.store.SetSenderAlias(alias, address)
+}
+
+// DeleteSenderAlias removes the sender `alias`.
+func (u *User) DeleteSenderAlias(alias string) error {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	if u.store == nil {
+		return errors.New("store is not initialised")
+	}
+
+	return u.store.DeleteSenderAlias(alias)
+}
+
 // GetBridgePassword returns bridge password. This is not a password of the PM
 // account, but generated password for local purposes to not use a PM account
 // in the clients (such as Thunderbird).
@@ -379,24 +687,159 @@ func (u *User) GetBridgePassword() string {
 	return u.creds.BridgePassword
 }
 
+// GetAddressBridgePassword returns bridge password to log in as `address`.
+// In split mode each address has its own password.
+func (u *User) GetAddressBridgePassword(address string) string {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	return u.creds.GetBridgePassword(address)
+}
+
 // CheckBridgeLogin checks whether the user is logged in and the bridge
-// IMAP/SMTP password is correct.
-func (u *User) CheckBridgeLogin(password string) error {
+// IMAP/SMTP password of `address` used to log in is correct. App passwords
+// are accepted as well; the name of the used one is returned (empty for
+// the bridge password).
+func (u *User) CheckBridgeLogin(address, password string) (string, error) {
 	if isApplicationOutdated {
 		u.listener.Emit(events.UpgradeApplicationEvent, "")
-		return pmapi.ErrUpgradeApplication
+		return "", pmapi.ErrUpgradeApplication
 	}
 
+	appPassword, err := u.checkBridgeLogin(address, password)
+	if err != nil {
+		return "", err
+	}
+
+	if appPassword == nil {
+		return "", nil
+	}
+
+	if time.Since(time.Unix(appPassword.LastUsed, 0)) > appPasswordLastUsedPrecision {
+		u.updateAppPasswordLastUsed(appPassword.Name)
+	}
+
+	return appPassword.Name, nil
+}
+
+func (u *User) checkBridgeLogin(address, password string) (*credentials.AppPassword, error) {
 	u.lock.RLock()
 	defer u.lock.RUnlock()
 
 	// True here because users should be notified by popup of auth failure.
 	if err := u.authorizeIfNecessary(true); err != nil {
 		u.log.WithError(err).Error("Failed to authorize user")
+		return nil, err
+	}
+
+	// Login name is resolved to an address the same way as by Users.GetUser.
+	// Other login names, such as username, have no address password.
+	address, _ = matchAddress(u.creds.EmailList(), address)
+
+	if err := u.creds.CheckPassword(address, password); err != nil {
+		return nil, err
+	}
+
+	if appPassword := u.creds.FindAppPassword(password); appPassword != nil {
+		appPasswordCopy := *appPassword
+		return &appPasswordCopy, nil
+	}
+
+	return nil, nil
+}
+
+func (u *User) updateAppPasswordLastUsed(name string) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if err := u.credStorer.UpdateAppPasswordLastUsed(u.userID, name, time.Now().Unix()); err != nil {
+		u.log.WithError(err).WithField("name", name).Warn("Cannot save last use of app password")
+		return
+	}
+
+	u.refreshFromCredentials()
+}
+
+// ListAppPasswords returns app passwords of the user without the secrets.
+func (u *User) ListAppPasswords() []credentials.AppPassword {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	appPasswords := []credentials.AppPassword{}
+	for _, appPassword := range u.creds.AppPasswords {
+		appPassword.Password = ""
+		appPasswords = append(appPasswords, appPassword)
+	}
+
+	return appPasswords
+}
+
+// AddAppPassword generates new app password for the mail client `name`.
+// The password is returned only here; it is not listed later.
+func (u *User) AddAppPassword(name string) (string, error) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	password, err := u.credStorer.AddAppPassword(u.userID, name)
+	if err != nil {
+		return "", err
+	}
+
+	u.refreshFromCredentials()
+
+	return password, nil
+}
+
+// RevokeAppPassword makes the app password `name` unusable and closes
+// connections which logged in with it. Other clients of the user stay
+// connected.
+func (u *User) RevokeAppPassword(name string) error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if err := u.credStorer.RevokeAppPassword(u.userID, name); err != nil {
 		return err
 	}
 
-	return u.creds.CheckPassword(password)
+	u.refreshFromCredentials()
+
+	u.log.WithField("name", name).Info("Closing connections of revoked app password")
+	u.listener.Emit(events.RevokedAppPasswordEvent, events.AppPasswordEventData{UserID: u.userID, Name: name}.String())
+
+	return nil
+}
+
+// RotateBridgePassword generates new bridge password of `address` and closes
+// connections which used the old one. Connections logged in with app
+// passwords stay open.
+func (u *User) RotateBridgePassword(address string) error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	isUserAddress := false
+	for _, email := range u.creds.EmailList() {
+		if strings.EqualFold(email, address) {
+			isUserAddress = true
+		}
+	}
+	if !isUserAddress {
+		return errors.New("address " + address + " does not belong to the account")
+	}
+
+	if err := u.credStorer.RotateBridgePassword(u.userID, address); err != nil {
+		return err
+	}
+
+	rotated := events.BridgePasswordEventData{UserID: u.userID}
+	if u.creds.GetBridgePassword(address) != u.creds.BridgePassword {
+		rotated.Address = address
+	}
+
+	u.refreshFromCredentials()
+
+	u.listener.Emit(events.RotatedBridgePasswordEvent, rotated.String())
+
+	return nil
 }
 
 // UpdateUser updates user details from API and saves to the credentials.
@@ -491,6 +934,10 @@ func (u *User) Logout() (err error) {
 		return
 	}
 
+	if u.creds.HasAutoReauth() {
+		u.clientManager.SetAutoReauth(u.userID, false)
+	}
+
 	u.client().Logout()
 
 	if err = u.credStorer.Logout(u.userID); err != nil {
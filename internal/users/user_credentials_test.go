@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	"github.com/ProtonMail/proton-bridge/internal/events"
+	"github.com/ProtonMail/proton-bridge/internal/users/credentials"
 	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
 	gomock "github.com/golang/mock/gomock"
 	"github.com/pkg/errors"
@@ -158,7 +159,7 @@ func TestCheckBridgeLoginOK(t *testing.T) {
 		m.pmapiClient.EXPECT().Unlock([]byte("pass")).Return(nil),
 	)
 
-	err := user.CheckBridgeLogin(testCredentials.BridgePassword)
+	_, err := user.CheckBridgeLogin("user@pm.me", testCredentials.BridgePassword)
 
 	waitForEvents()
 
@@ -178,11 +179,11 @@ func TestCheckBridgeLoginTwiceOK(t *testing.T) {
 		m.pmapiClient.EXPECT().IsUnlocked().Return(true),
 	)
 
-	err := user.CheckBridgeLogin(testCredentials.BridgePassword)
+	_, err := user.CheckBridgeLogin("user@pm.me", testCredentials.BridgePassword)
 	waitForEvents()
 	assert.NoError(t, err)
 
-	err = user.CheckBridgeLogin(testCredentials.BridgePassword)
+	_, err = user.CheckBridgeLogin("user@pm.me", testCredentials.BridgePassword)
 	waitForEvents()
 	assert.NoError(t, err)
 }
@@ -198,7 +199,7 @@ func TestCheckBridgeLoginUpgradeApplication(t *testing.T) {
 
 	isApplicationOutdated = true
 
-	err := user.CheckBridgeLogin("any-pass")
+	_, err := user.CheckBridgeLogin("user@pm.me", "any-pass")
 	waitForEvents()
 	assert.Equal(t, pmapi.ErrUpgradeApplication, err)
 
@@ -228,7 +229,7 @@ func TestCheckBridgeLoginLoggedOut(t *testing.T) {
 
 	m.eventListener.EXPECT().Emit(events.LogoutEvent, "user")
 
-	err = user.CheckBridgeLogin(testCredentialsDisconnected.BridgePassword)
+	_, err = user.CheckBridgeLogin("user@pm.me", testCredentialsDisconnected.BridgePassword)
 	waitForEvents()
 	assert.Equal(t, ErrLoggedOutUser, err)
 }
@@ -245,7 +246,174 @@ func TestCheckBridgeLoginBadPassword(t *testing.T) {
 		m.pmapiClient.EXPECT().Unlock([]byte("pass")).Return(nil),
 	)
 
-	err := user.CheckBridgeLogin("wrong!")
+	_, err := user.CheckBridgeLogin("user@pm.me", "wrong!")
 	waitForEvents()
 	assert.Equal(t, "backend/credentials: incorrect password", err.Error())
 }
+
+func TestCheckBridgeLoginAppPassword(t *testing.T) {
+	m := initMocks(t)
+	defer m.ctrl.Finish()
+
+	user := testNewUser(m)
+	defer cleanUpUserData(user)
+
+	creds := *testCredentials
+	creds.AppPasswords = []credentials.AppPassword{{Name: "phone", Password: "phone-pass"}}
+	user.creds = &creds
+
+	gomock.InOrder(
+		m.pmapiClient.EXPECT().IsUnlocked().Return(false),
+		m.pmapiClient.EXPECT().Unlock([]byte("pass")).Return(nil),
+		m.credentialsStore.EXPECT().UpdateAppPasswordLastUsed("user", "phone", gomock.Any()).Return(nil),
+		m.credentialsStore.EXPECT().Get("user").Return(&creds, nil),
+	)
+
+	appPassword, err := user.CheckBridgeLogin("user@pm.me", "phone-pass")
+	waitForEvents()
+	assert.NoError(t, err)
+	assert.Equal(t, "phone", appPassword)
+}
+
+func TestCheckBridgeLoginSplitModeUsername(t *testing.T) {
+	m := initMocks(t)
+	defer m.ctrl.Finish()
+
+	user := testNewUser(m)
+	defer cleanUpUserData(user)
+
+	creds := *testCredentials
+	creds.IsCombinedAddressMode = false
+	creds.AddressPasswords = map[string]string{"user@pm.me": "address-pass"}
+	user.creds = &creds
+
+	m.pmapiClient.EXPECT().IsUnlocked().Return(false)
+	m.pmapiClient.EXPECT().Unlock([]byte("pass")).Return(nil)
+	m.pmapiClient.EXPECT().IsUnlocked().Return(true).Times(3)
+
+	// Username is not an address, so neither shared nor address password works.
+	_, err := user.CheckBridgeLogin("username", creds.BridgePassword)
+	assert.Error(t, err)
+	_, err = user.CheckBridgeLogin("username", "address-pass")
+	assert.Error(t, err)
+
+	// Address is matched case-insensitively and accepts only its own password.
+	_, err = user.CheckBridgeLogin("User@PM.me", creds.BridgePassword)
+	assert.Error(t, err)
+	appPassword, err := user.CheckBridgeLogin("User@PM.me", "address-pass")
+	assert.NoError(t, err)
+	assert.Equal(t, "", appPassword)
+}
+
+func TestRevokeAppPassword(t *testing.T) {
+	m := initMocks(t)
+	defer m.ctrl.Finish()
+
+	user := testNewUser(m)
+	defer cleanUpUserData(user)
+
+	gomock.InOrder(
+		m.credentialsStore.EXPECT().RevokeAppPassword("user", "phone").Return(nil),
+		m.credentialsStore.EXPECT().Get("user").Return(testCredentials, nil),
+		m.eventListener.EXPECT().Emit(events.RevokedAppPasswordEvent, `{"userID":"user","name":"phone"}`),
+	)
+
+	assert.NoError(t, user.RevokeAppPassword("phone"))
+}
+
+func TestRotateBridgePassword(t *testing.T) {
+	m := initMocks(t)
+	defer m.ctrl.Finish()
+
+	user := testNewUser(m)
+	defer cleanUpUserData(user)
+
+	gomock.InOrder(
+		m.credentialsStore.EXPECT().RotateBridgePassword("user", "user@pm.me").Return(nil),
+		m.credentialsStore.EXPECT().Get("user").Return(testCredentials, nil),
+		m.eventListener.EXPECT().Emit(events.RotatedBridgePasswordEvent, `{"userID":"user"}`),
+	)
+
+	assert.NoError(t, user.RotateBridgePassword("user@pm.me"))
+}
+
+func TestRotateBridgePasswordSplitMode(t *testing.T) {
+	m := initMocks(t)
+	defer m.ctrl.Finish()
+
+	user := testNewUser(m)
+	defer cleanUpUserData(user)
+
+	creds := *testCredentials
+	creds.IsCombinedAddressMode = false
+	creds.AddressPasswords = map[string]string{"user@pm.me": "address-pass"}
+	user.creds = &creds
+
+	gomock.InOrder(
+		m.credentialsStore.EXPECT().RotateBridgePassword("user", "user@pm.me").Return(nil),
+		m.credentialsStore.EXPECT().Get("user").Return(&creds, nil),
+		m.eventListener.EXPECT().Emit(events.RotatedBridgePasswordEvent, `{"userID":"user","address":"user@pm.me"}`),
+	)
+
+	assert.NoError(t, user.RotateBridgePassword("user@pm.me"))
+}
+
+func TestEnableAutoReauth(t *testing.T) {
+	m := initMocks(t)
+	defer m.ctrl.Finish()
+
+	user := testNewUser(m)
+	defer cleanUpUserData(user)
+
+	twoFactorAuth := &pmapi.Auth{TwoFA: &pmapi.TwoFactorInfo{Enabled: 1}}
+	securityKeyAuth := &pmapi.Auth{TwoFA: &pmapi.TwoFactorInfo{Enabled: 2}}
+
+	gomock.InOrder(
+		// Wrong password is not stored.
+		m.clientManager.EXPECT().GetAnonymousClient().Return(m.pmapiClient),
+		m.pmapiClient.EXPECT().Auth("username", "wrong", nil).Return(nil, errors.New("wrong password")),
+		m.pmapiClient.EXPECT().Logout(),
+
+		// Account with 2FA needs the TOTP secret.
+		m.clientManager.EXPECT().GetAnonymousClient().Return(m.pmapiClient),
+		m.pmapiClient.EXPECT().Auth("username", "login pass", nil).Return(twoFactorAuth, nil),
+		m.pmapiClient.EXPECT().DeleteAuth().Return(nil),
+		m.pmapiClient.EXPECT().Logout(),
+
+		m.clientManager.EXPECT().GetAnonymousClient().Return(m.pmapiClient),
+		m.pmapiClient.EXPECT().Auth("username", "login pass", nil).Return(twoFactorAuth, nil),
+		m.pmapiClient.EXPECT().DeleteAuth().Return(nil),
+		m.credentialsStore.EXPECT().SetAutoReauth("user", "login pass", "GEZDGNBV").Return(nil),
+		m.clientManager.EXPECT().SetAutoReauth("user", true),
+		m.credentialsStore.EXPECT().Get("user").Return(testCredentials, nil),
+		m.pmapiClient.EXPECT().Logout(),
+
+		// Security key cannot be used without user.
+		m.clientManager.EXPECT().GetAnonymousClient().Return(m.pmapiClient),
+		m.pmapiClient.EXPECT().Auth("username", "login pass", nil).Return(securityKeyAuth, nil),
+		m.pmapiClient.EXPECT().DeleteAuth().Return(nil),
+		m.pmapiClient.EXPECT().Logout(),
+	)
+
+	assert.Error(t, user.EnableAutoReauth("login pass", "not base32!"))
+	assert.Error(t, user.EnableAutoReauth("wrong", ""))
+	assert.Error(t, user.EnableAutoReauth("login pass", ""))
+	assert.NoError(t, user.EnableAutoReauth("login pass", "GEZDGNBV"))
+	assert.Error(t, user.EnableAutoReauth("login pass", "GEZDGNBV"))
+}
+
+func TestDisableAutoReauth(t *testing.T) {
+	m := initMocks(t)
+	defer m.ctrl.Finish()
+
+	user := testNewUser(m)
+	defer cleanUpUserData(user)
+
+	gomock.InOrder(
+		m.credentialsStore.EXPECT().SetAutoReauth("user", "", "").Return(nil),
+		m.clientManager.EXPECT().SetAutoReauth("user", false),
+		m.credentialsStore.EXPECT().Get("user").Return(testCredentials, nil),
+	)
+
+	assert.NoError(t, user.DisableAutoReauth())
+}
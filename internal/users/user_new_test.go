@@ -99,6 +99,66 @@ func TestNewUserAuthRefreshFails(t *testing.T) {
 	checkNewUserHasCredentials(testCredentialsDisconnected, m)
 }
 
+func TestNewUserAuthRefreshFailsWithAutoReauth(t *testing.T) {
+	m := initMocks(t)
+	defer m.ctrl.Finish()
+
+	creds := *testCredentials
+	creds.LoginPassword = "login pass"
+	creds.TOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	auth := &pmapi.Auth{RefreshToken: "tok", TwoFA: &pmapi.TwoFactorInfo{Enabled: 1}}
+
+	m.clientManager.EXPECT().GetClient("user").Return(m.pmapiClient).MinTimes(1)
+
+	gomock.InOrder(
+		m.credentialsStore.EXPECT().Get("user").Return(&creds, nil),
+		m.clientManager.EXPECT().SetAutoReauth("user", true),
+		m.credentialsStore.EXPECT().Get("user").Return(&creds, nil),
+		m.pmapiClient.EXPECT().AuthRefresh("token").Return(nil, errors.New("bad token")),
+
+		// Login again instead of logout.
+		m.pmapiClient.EXPECT().Auth("username", "login pass", nil).Return(auth, nil),
+		m.pmapiClient.EXPECT().Auth2FA(gomock.Any(), auth).Return(nil, nil),
+		m.pmapiClient.EXPECT().Unlock([]byte("pass")).Return(nil),
+
+		m.pmapiClient.EXPECT().ListLabels().Return([]*pmapi.Label{}, nil),
+		m.pmapiClient.EXPECT().CountMessages("").Return([]*pmapi.MessagesCount{}, nil),
+		m.pmapiClient.EXPECT().Addresses().Return([]*pmapi.Address{testPMAPIAddress}),
+	)
+	mockEventLoopNoAction(m)
+
+	checkNewUserHasCredentials(&creds, m)
+}
+
+func TestNewUserAuthRefreshFailsWithWrongAutoReauth(t *testing.T) {
+	m := initMocks(t)
+	defer m.ctrl.Finish()
+
+	creds := *testCredentials
+	creds.LoginPassword = "login pass"
+
+	m.clientManager.EXPECT().GetClient("user").Return(m.pmapiClient).MinTimes(1)
+	m.eventListener.EXPECT().Emit(events.LogoutEvent, "user")
+	m.eventListener.EXPECT().Emit(events.UserRefreshEvent, "user")
+	m.eventListener.EXPECT().Emit(events.CloseConnectionEvent, "user@pm.me")
+
+	gomock.InOrder(
+		m.credentialsStore.EXPECT().Get("user").Return(&creds, nil),
+		m.clientManager.EXPECT().SetAutoReauth("user", true),
+		m.credentialsStore.EXPECT().Get("user").Return(&creds, nil),
+		m.pmapiClient.EXPECT().AuthRefresh("token").Return(nil, errors.New("bad token")),
+		m.pmapiClient.EXPECT().Auth("username", "login pass", nil).Return(nil, errors.New("wrong password")),
+		m.credentialsStore.EXPECT().Logout("user").Return(nil),
+
+		m.clientManager.EXPECT().SetAutoReauth("user", false),
+		m.pmapiClient.EXPECT().Logout(),
+		m.credentialsStore.EXPECT().Logout("user").Return(nil),
+		m.credentialsStore.EXPECT().Get("user").Return(testCredentialsDisconnected, nil),
+	)
+
+	checkNewUserHasCredentials(testCredentialsDisconnected, m)
+}
+
 func TestNewUserUnlockFails(t *testing.T) {
 	m := initMocks(t)
 	defer m.ctrl.Finish()
@@ -20,6 +20,7 @@ package users
 import (
 	"testing"
 
+	"github.com/ProtonMail/proton-bridge/internal/users/credentials"
 	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
 	gomock "github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -99,3 +100,24 @@ func TestClearStoreWithoutStore(t *testing.T) {
 	assert.NotNil(t, user.store)
 	assert.Nil(t, user.clearStore())
 }
+
+func TestCheckCredentialsExtension(t *testing.T) {
+	m := initMocks(t)
+	defer m.ctrl.Finish()
+
+	user := testNewUser(m)
+	defer cleanUpUserData(user)
+
+	require.False(t, user.store.HadCredentialsExtension())
+
+	withExtension := &credentials.Credentials{UserID: "user"}
+	require.NoError(t, withExtension.Unmarshal(testCredentials.Marshal()))
+	user.creds = withExtension
+	user.checkCredentialsExtension()
+	require.True(t, user.store.HadCredentialsExtension())
+
+	// Older version saved the credentials without the extension.
+	user.creds = testCredentials
+	user.checkCredentialsExtension()
+	require.False(t, user.store.HadCredentialsExtension())
+}
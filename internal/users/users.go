@@ -19,11 +19,14 @@
 package users
 
 import (
+	"io"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ProtonMail/proton-bridge/internal/events"
 	"github.com/ProtonMail/proton-bridge/internal/metrics"
+	"github.com/ProtonMail/proton-bridge/internal/users/credentials"
 	"github.com/ProtonMail/proton-bridge/pkg/listener"
 	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
 	imapBackend "github.com/emersion/go-imap/backend"
@@ -35,6 +38,13 @@ import (
 var (
 	log                   = logrus.WithField("pkg", "users") //nolint[gochecknoglobals]
 	isApplicationOutdated = false                            //nolint[gochecknoglobals]
+
+	// keychainRetryInterval is how often the keychain is checked to unlock or lock accounts.
+	keychainRetryInterval = 30 * time.Second //nolint[gochecknoglobals]
+
+	// ErrWrongMailboxPassword is returned by FinishLogin when the keys
+	// cannot be unlocked with the given mailbox password.
+	ErrWrongMailboxPassword = errors.New("wrong mailbox password") //nolint[gochecknoglobals]
 )
 
 // Users is a struct handling users.
@@ -52,6 +62,10 @@ type Users struct {
 	// People are used to that and so we preserve that ordering here.
 	users []*User
 
+	// keychainErr is set when users could not be loaded from the credentials
+	// store; the accounts are locked until the keychain is available.
+	keychainErr error
+
 	// idleUpdates is a channel which the imap backend listens to and which it uses
 	// to send idle updates to the mail client (eg thunderbird).
 	// The user stores should send idle updates on this channel.
@@ -59,6 +73,11 @@ type Users struct {
 
 	lock sync.RWMutex
 
+	// loginHosts holds the host settings of anonymous clients created by
+	// LoginWithHost until the login is finished.
+	loginHosts     map[pmapi.Client]pmapi.HostSettings
+	loginHostsLock sync.Mutex
+
 	// stopAll can be closed to stop all goroutines from looping (watchAppOutdated, watchAPIAuths, heartbeat etc).
 	stopAll chan struct{}
 }
@@ -82,6 +101,7 @@ func New(
 		storeFactory:  storeFactory,
 		idleUpdates:   make(chan imapBackend.Update),
 		lock:          sync.RWMutex{},
+		loginHosts:    make(map[pmapi.Client]pmapi.HostSettings),
 		stopAll:       make(chan struct{}),
 	}
 
@@ -97,13 +117,24 @@ func New(
 
 	if u.credStorer == nil {
 		log.Error("No credentials store is available")
-	} else if err := u.loadUsersFromCredentialsStore(); err != nil {
-		log.WithError(err).Error("Could not load all users from credentials store")
+	} else {
+		if err := u.loadUsersFromCredentialsStore(); err != nil {
+			u.setKeychainLocked(err)
+		}
+
+		interval := keychainRetryInterval
+		go func() {
+			defer panicHandler.HandlePanic()
+			u.watchKeychain(interval)
+		}()
 	}
 
 	return u
 }
 
+// loadUsersFromCredentialsStore loads users which are not loaded yet. Users
+// whose credentials cannot be read are skipped and the error is returned so
+// that they are loaded again once the keychain is unlocked.
 func (u *Users) loadUsersFromCredentialsStore() (err error) {
 	u.lock.Lock()
 	defer u.lock.Unlock()
@@ -114,24 +145,93 @@ func (u *Users) loadUsersFromCredentialsStore() (err error) {
 	}
 
 	for _, userID := range userIDs {
+		if u.isLoaded(userID) {
+			continue
+		}
+
 		l := log.WithField("user", userID)
 
 		user, newUserErr := newUser(u.panicHandler, userID, u.events, u.credStorer, u.clientManager, u.storeFactory)
 		if newUserErr != nil {
-			l.WithField("user", userID).WithError(newUserErr).Warn("Could not load user, skipping")
+			if errors.Cause(newUserErr) == credentials.ErrNewerFormat {
+				l.WithError(newUserErr).Warn("Could not load user, skipping")
+				continue
+			}
+			l.WithError(newUserErr).Warn("Could not load user, account is locked")
+			err = newUserErr
 			continue
 		}
 
 		u.users = append(u.users, user)
 
 		if initUserErr := user.init(u.idleUpdates); initUserErr != nil {
-			l.WithField("user", userID).WithError(initUserErr).Warn("Could not initialise user")
+			l.WithError(initUserErr).Warn("Could not initialise user")
 		}
 	}
 
 	return err
 }
 
+func (u *Users) isLoaded(userID string) bool {
+	for _, user := range u.users {
+		if user.ID() == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// watchKeychain checks the keychain periodically. While it is locked, it
+// tries to load the locked users so accounts come online without restart.
+// Otherwise it checks that the keychain was not locked again.
+func (u *Users) watchKeychain(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if u.GetKeychainError() == nil {
+				if err := u.credStorer.Reconnect(); err != nil {
+					u.setKeychainLocked(err)
+				}
+				continue
+			}
+
+			if err := u.credStorer.Reconnect(); err != nil {
+				log.WithError(err).Debug("Keychain is still not available")
+				continue
+			}
+
+			if err := u.loadUsersFromCredentialsStore(); err != nil {
+				log.WithError(err).Warn("Could not load users from credentials store")
+				continue
+			}
+
+			u.lock.Lock()
+			u.keychainErr = nil
+			u.lock.Unlock()
+
+			log.Info("Keychain is available, accounts are unlocked")
+			u.events.Emit(events.KeychainUnlockedEvent, "")
+			u.events.Emit(events.UserRefreshEvent, "")
+
+		case <-u.stopAll:
+			return
+		}
+	}
+}
+
+func (u *Users) setKeychainLocked(err error) {
+	log.WithError(err).Error("Could not load users from credentials store, accounts are locked")
+
+	u.lock.Lock()
+	u.keychainErr = err
+	u.lock.Unlock()
+
+	u.events.Emit(events.KeychainLockedEvent, err.Error())
+}
+
 func (u *Users) watchAppOutdated() {
 	ch := make(chan string)
 
@@ -164,6 +264,9 @@ func (u *Users) watchAPIAuths() {
 
 			if auth.Auth != nil {
 				user.updateAuthToken(auth.Auth)
+			} else if user.HasAutoReauth() {
+				// New auth is received on this channel so it cannot block it.
+				go user.reauthorizeOrLogout()
 			} else if err := user.logout(); err != nil {
 				log.WithError(err).
 					WithField("userID", auth.UserID).
@@ -190,6 +293,37 @@ func (u *Users) Login(username, password string) (authClient pmapi.Client, auth
 	// We need to use anonymous client because we don't yet have userID and so can't save auth tokens yet.
 	authClient = u.clientManager.GetAnonymousClient()
 
+	auth, err = authenticate(authClient, username, password)
+	return
+}
+
+// LoginWithHost authenticates a user the same way as Login but talks to the API
+// host and uses the dialer given by `host`. FinishLogin keeps the settings for the account.
+func (u *Users) LoginWithHost(username, password string, host pmapi.HostSettings) (authClient pmapi.Client, auth *pmapi.Auth, err error) {
+	if host.IsDefault() {
+		return u.Login(username, password)
+	}
+
+	if err = host.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	u.crashBandicoot(username)
+
+	authClient = u.clientManager.GetAnonymousClientWithHost(host)
+
+	u.loginHostsLock.Lock()
+	u.loginHosts[authClient] = host
+	u.loginHostsLock.Unlock()
+
+	if auth, err = authenticate(authClient, username, password); err != nil {
+		u.takeLoginHost(authClient)
+	}
+
+	return
+}
+
+func authenticate(authClient pmapi.Client, username, password string) (auth *pmapi.Auth, err error) {
 	authInfo, err := authClient.AuthInfo(username)
 	if err != nil {
 		log.WithField("username", username).WithError(err).Error("Could not get auth info for user")
@@ -204,8 +338,26 @@ func (u *Users) Login(username, password string) (authClient pmapi.Client, auth
 	return
 }
 
+// takeLoginHost returns and forgets the host settings used by LoginWithHost for `authClient`.
+func (u *Users) takeLoginHost(authClient pmapi.Client) (host pmapi.HostSettings, ok bool) {
+	u.loginHostsLock.Lock()
+	defer u.loginHostsLock.Unlock()
+
+	host, ok = u.loginHosts[authClient]
+	delete(u.loginHosts, authClient)
+
+	return
+}
+
 // FinishLogin finishes the login procedure and adds the user into the credentials store.
-func (u *Users) FinishLogin(authClient pmapi.Client, auth *pmapi.Auth, mbPassphrase string) (user *User, err error) { //nolint[funlen]
+func (u *Users) FinishLogin(authClient pmapi.Client, auth *pmapi.Auth, mbPassphrase string) (user *User, err error) {
+	return u.FinishLoginWithStore(authClient, auth, mbPassphrase, nil)
+}
+
+// FinishLoginWithStore finishes the login procedure the same way as FinishLogin
+// but if the user is new, the store is loaded from `archive` exported on another
+// machine (see User.ExportStore) instead of doing the full sync.
+func (u *Users) FinishLoginWithStore(authClient pmapi.Client, auth *pmapi.Auth, mbPassphrase string, archive io.Reader) (user *User, err error) { //nolint[funlen]
 	defer func() {
 		if err == pmapi.ErrUpgradeApplication {
 			u.events.Emit(events.UpgradeApplicationEvent, "")
@@ -228,14 +380,27 @@ func (u *Users) FinishLogin(authClient pmapi.Client, auth *pmapi.Auth, mbPassphr
 
 	log.Info("Got API user")
 
+	host, hasHost := u.takeLoginHost(authClient)
+
 	var ok bool
 	if user, ok = u.hasUser(apiUser.ID); ok {
+		if archive != nil {
+			log.Warn("User already has a local store; ignoring the store archive")
+		}
+		// The account might have been used with another host before.
+		if hasHost || !user.GetHostSettings().IsDefault() {
+			u.clientManager.SetHostSettings(apiUser.ID, host)
+			if err = u.credStorer.SetAPIHost(apiUser.ID, host.URL, host.NoPinning, host.NoProxy); err != nil {
+				log.WithError(err).Error("Failed to save API host of existing user")
+				return
+			}
+		}
 		if err = u.connectExistingUser(user, auth, hashedPassphrase); err != nil {
 			log.WithError(err).Error("Failed to connect existing user")
 			return
 		}
 	} else {
-		if err = u.addNewUser(apiUser, auth, hashedPassphrase); err != nil {
+		if err = u.addNewUser(apiUser, auth, hashedPassphrase, archive, host); err != nil {
 			log.WithError(err).Error("Failed to add new user")
 			return
 		}
@@ -276,11 +441,22 @@ func (u *Users) connectExistingUser(user *User, auth *pmapi.Auth, hashedPassphra
 	return
 }
 
-// addNewUser adds a new user.
-func (u *Users) addNewUser(apiUser *pmapi.User, auth *pmapi.Auth, hashedPassphrase string) (err error) {
+// addNewUser adds a new user. If `archive` is not nil, the store of the user
+// is imported from it before the user is initialised.
+func (u *Users) addNewUser(apiUser *pmapi.User, auth *pmapi.Auth, hashedPassphrase string, archive io.Reader, host pmapi.HostSettings) (err error) {
 	u.lock.Lock()
 	defer u.lock.Unlock()
 
+	if archive != nil {
+		if err = u.storeFactory.Import(apiUser.ID, archive, []byte(hashedPassphrase)); err != nil {
+			return errors.Wrap(err, "failed to import store")
+		}
+	}
+
+	if !host.IsDefault() {
+		u.clientManager.SetHostSettings(apiUser.ID, host)
+	}
+
 	client := u.clientManager.GetClient(apiUser.ID)
 
 	if auth, err = client.AuthRefresh(auth.GenToken()); err != nil {
@@ -297,6 +473,12 @@ func (u *Users) addNewUser(apiUser *pmapi.User, auth *pmapi.Auth, hashedPassphra
 		return errors.Wrap(err, "failed to add user to credentials store")
 	}
 
+	if !host.IsDefault() {
+		if err = u.credStorer.SetAPIHost(apiUser.ID, host.URL, host.NoPinning, host.NoProxy); err != nil {
+			return errors.Wrap(err, "failed to save API host")
+		}
+	}
+
 	user, err := newUser(u.panicHandler, apiUser.ID, u.events, u.credStorer, u.clientManager, u.storeFactory)
 	if err != nil {
 		return errors.Wrap(err, "failed to create user")
@@ -328,20 +510,31 @@ func getAPIUser(client pmapi.Client, mbPassphrase string) (user *pmapi.User, has
 		return
 	}
 
-	// We unlock the user's PGP key here to detect if the user's mailbox password is wrong.
-	if err = client.Unlock([]byte(hashedPassphrase)); err != nil {
-		log.WithError(err).Error("Wrong mailbox password")
-		return
-	}
-
+	// User data is loaded first so that API errors are not mistaken for
+	// a wrong mailbox password when unlocking the keys.
 	if user, err = client.CurrentUser(); err != nil {
 		log.WithError(err).Error("Could not load user data")
 		return
 	}
 
+	// We unlock the user's PGP key here to detect if the user's mailbox password is wrong.
+	if err = client.Unlock([]byte(hashedPassphrase)); err != nil {
+		log.WithError(err).Error("Wrong mailbox password")
+		return nil, "", ErrWrongMailboxPassword
+	}
+
 	return
 }
 
+// GetKeychainError returns why accounts could not be loaded from the keychain.
+// It is nil unless accounts are locked waiting for the keychain.
+func (u *Users) GetKeychainError() error {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	return u.keychainErr
+}
+
 // GetUsers returns all added users into keychain (even logged out users).
 func (u *Users) GetUsers() []*User {
 	u.lock.RLock()
@@ -361,16 +554,25 @@ func (u *Users) GetUser(query string) (*User, error) {
 		if strings.EqualFold(user.ID(), query) || strings.EqualFold(user.Username(), query) {
 			return user, nil
 		}
-		for _, address := range user.GetAddresses() {
-			if strings.EqualFold(address, query) {
-				return user, nil
-			}
+		if _, ok := matchAddress(user.GetAddresses(), query); ok {
+			return user, nil
 		}
 	}
 
 	return nil, errors.New("user " + query + " not found")
 }
 
+// matchAddress returns the address from `addresses` which matches `query`
+// case-insensitively.
+func matchAddress(addresses []string, query string) (string, bool) {
+	for _, address := range addresses {
+		if strings.EqualFold(address, query) {
+			return address, true
+		}
+	}
+	return "", false
+}
+
 // ClearData closes all connections (to release db files and so on) and clears all data.
 func (u *Users) ClearData() error {
 	var result *multierror.Error
@@ -418,6 +620,9 @@ func (u *Users) DeleteUser(userID string, clearStore bool) error {
 				log.WithError(err).Error("Cannot remove user")
 				return err
 			}
+			if !user.GetHostSettings().IsDefault() {
+				u.clientManager.SetHostSettings(userID, pmapi.HostSettings{})
+			}
 			u.users = append(u.users[:idx], u.users[idx+1:]...)
 			return nil
 		}
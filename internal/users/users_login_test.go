@@ -18,7 +18,7 @@
 package users
 
 import (
-	"errors"
+	"strings"
 	"testing"
 
 	"github.com/ProtonMail/proton-bridge/internal/events"
@@ -26,6 +26,7 @@ import (
 	"github.com/ProtonMail/proton-bridge/internal/users/credentials"
 	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
 	gomock "github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -40,12 +41,13 @@ func TestUsersFinishLoginBadMailboxPassword(t *testing.T) {
 
 		// Set up mocks for FinishLogin.
 		m.pmapiClient.EXPECT().AuthSalt().Return("", nil),
+		m.pmapiClient.EXPECT().CurrentUser().Return(testPMAPIUser, nil),
 		m.pmapiClient.EXPECT().Unlock([]byte(testCredentials.MailboxPassword)).Return(err),
 		m.pmapiClient.EXPECT().DeleteAuth(),
 		m.pmapiClient.EXPECT().Logout(),
 	)
 
-	checkUsersFinishLogin(t, m, testAuth, testCredentials.MailboxPassword, "", err)
+	checkUsersFinishLogin(t, m, testAuth, testCredentials.MailboxPassword, "", ErrWrongMailboxPassword)
 }
 
 func TestUsersFinishLoginUpgradeApplication(t *testing.T) {
@@ -59,7 +61,7 @@ func TestUsersFinishLoginUpgradeApplication(t *testing.T) {
 
 		// Set up mocks for FinishLogin.
 		m.pmapiClient.EXPECT().AuthSalt().Return("", nil),
-		m.pmapiClient.EXPECT().Unlock([]byte(testCredentials.MailboxPassword)).Return(pmapi.ErrUpgradeApplication),
+		m.pmapiClient.EXPECT().CurrentUser().Return(nil, pmapi.ErrUpgradeApplication),
 
 		m.eventListener.EXPECT().Emit(events.UpgradeApplicationEvent, ""),
 		m.pmapiClient.EXPECT().DeleteAuth().Return(err),
@@ -95,8 +97,8 @@ func TestUsersFinishLoginNewUser(t *testing.T) {
 
 		// getAPIUser() loads user info from API (e.g. userID).
 		m.pmapiClient.EXPECT().AuthSalt().Return("", nil),
-		m.pmapiClient.EXPECT().Unlock([]byte(testCredentials.MailboxPassword)).Return(nil),
 		m.pmapiClient.EXPECT().CurrentUser().Return(testPMAPIUser, nil),
+		m.pmapiClient.EXPECT().Unlock([]byte(testCredentials.MailboxPassword)).Return(nil),
 
 		// addNewUser()
 		m.pmapiClient.EXPECT().AuthRefresh(":tok").Return(refreshWithToken("afterLogin"), nil),
@@ -134,6 +136,123 @@ func TestUsersFinishLoginNewUser(t *testing.T) {
 	mockAuthUpdate(user, "afterCredentials", m)
 }
 
+func TestUsersLoginWithHostInvalidURL(t *testing.T) {
+	m := initMocks(t)
+	defer m.ctrl.Finish()
+
+	m.credentialsStore.EXPECT().List().Return([]string{}, nil)
+
+	users := testNewUsers(t, m)
+	defer cleanUpUsersData(users)
+
+	_, _, err := users.LoginWithHost("username", "pass", pmapi.HostSettings{URL: "ftp://api.example.com"})
+	assert.Error(t, err)
+}
+
+func TestUsersFinishLoginWithHostNewUser(t *testing.T) {
+	m := initMocks(t)
+	defer m.ctrl.Finish()
+
+	host := pmapi.HostSettings{URL: "https://api.example.com", NoPinning: true}
+	authInfo := &pmapi.AuthInfo{}
+
+	m.clientManager.EXPECT().GetClient("user").Return(m.pmapiClient).MinTimes(1)
+
+	gomock.InOrder(
+		// users.New() finds no users in keychain.
+		m.credentialsStore.EXPECT().List().Return([]string{}, nil),
+
+		// LoginWithHost() uses anonymous client with the host.
+		m.clientManager.EXPECT().GetAnonymousClientWithHost(host).Return(m.pmapiClient),
+		m.pmapiClient.EXPECT().AuthInfo("username").Return(authInfo, nil),
+		m.pmapiClient.EXPECT().Auth("username", "pass", authInfo).Return(testAuth, nil),
+
+		// getAPIUser() loads user info from API (e.g. userID).
+		m.pmapiClient.EXPECT().AuthSalt().Return("", nil),
+		m.pmapiClient.EXPECT().CurrentUser().Return(testPMAPIUser, nil),
+		m.pmapiClient.EXPECT().Unlock([]byte(testCredentials.MailboxPassword)).Return(nil),
+
+		// addNewUser() sets the host before the client of the user is used.
+		m.clientManager.EXPECT().SetHostSettings("user", host),
+		m.pmapiClient.EXPECT().AuthRefresh(":tok").Return(refreshWithToken("afterLogin"), nil),
+		m.pmapiClient.EXPECT().CurrentUser().Return(testPMAPIUser, nil),
+		m.pmapiClient.EXPECT().Addresses().Return([]*pmapi.Address{testPMAPIAddress}),
+		m.credentialsStore.EXPECT().Add("user", "username", ":afterLogin", testCredentials.MailboxPassword, []string{testPMAPIAddress.Email}),
+		m.credentialsStore.EXPECT().SetAPIHost("user", host.URL, true, false),
+		m.credentialsStore.EXPECT().Get("user").Return(credentialsWithToken(":afterLogin"), nil),
+
+		// user.init() in addNewUser
+		m.credentialsStore.EXPECT().Get("user").Return(credentialsWithToken(":afterLogin"), nil),
+		m.pmapiClient.EXPECT().AuthRefresh(":afterLogin").Return(refreshWithToken("afterCredentials"), nil),
+		m.pmapiClient.EXPECT().Unlock([]byte(testCredentials.MailboxPassword)).Return(nil),
+
+		// store.New() in user.init
+		m.pmapiClient.EXPECT().ListLabels().Return([]*pmapi.Label{}, nil),
+		m.pmapiClient.EXPECT().CountMessages("").Return([]*pmapi.MessagesCount{}, nil),
+		m.pmapiClient.EXPECT().Addresses().Return([]*pmapi.Address{testPMAPIAddress}),
+
+		// Emit event for new user and send metrics.
+		m.clientManager.EXPECT().GetAnonymousClient().Return(m.pmapiClient),
+		m.pmapiClient.EXPECT().SendSimpleMetric(string(metrics.Setup), string(metrics.NewUser), string(metrics.NoLabel)),
+		m.pmapiClient.EXPECT().Logout(),
+
+		// Reload account list in GUI.
+		m.eventListener.EXPECT().Emit(events.UserRefreshEvent, "user"),
+
+		// defer logout anonymous
+		m.pmapiClient.EXPECT().Logout(),
+	)
+
+	mockEventLoopNoAction(m)
+
+	users := testNewUsers(t, m)
+	defer cleanUpUsersData(users)
+
+	authClient, auth, err := users.LoginWithHost("username", "pass", host)
+	assert.NoError(t, err)
+
+	user, err := users.FinishLogin(authClient, auth, testCredentials.MailboxPassword)
+	waitForEvents()
+	assert.NoError(t, err)
+	assert.Equal(t, "user", user.ID())
+	assert.Empty(t, users.loginHosts)
+
+	mockAuthUpdate(user, "afterCredentials", m)
+}
+
+func TestUsersFinishLoginWithStoreImportFailure(t *testing.T) {
+	m := initMocks(t)
+	defer m.ctrl.Finish()
+
+	archive := strings.NewReader("broken archive")
+	err := errors.New("failed to decrypt archive")
+
+	gomock.InOrder(
+		// users.New() finds no users in keychain.
+		m.credentialsStore.EXPECT().List().Return([]string{}, nil),
+
+		// getAPIUser() loads user info from API (e.g. userID).
+		m.pmapiClient.EXPECT().AuthSalt().Return("", nil),
+		m.pmapiClient.EXPECT().CurrentUser().Return(testPMAPIUser, nil),
+		m.pmapiClient.EXPECT().Unlock([]byte(testCredentials.MailboxPassword)).Return(nil),
+
+		// addNewUser() imports store before anything is added to keychain.
+		m.storeMaker.EXPECT().Import("user", archive, []byte(testCredentials.MailboxPassword)).Return(err),
+
+		m.pmapiClient.EXPECT().DeleteAuth(),
+		m.pmapiClient.EXPECT().Logout(),
+	)
+
+	users := testNewUsers(t, m)
+	defer cleanUpUsersData(users)
+
+	user, loginErr := users.FinishLoginWithStore(m.pmapiClient, testAuth, testCredentials.MailboxPassword, archive)
+
+	assert.Equal(t, err, errors.Cause(loginErr))
+	assert.Equal(t, (*User)(nil), user)
+	assert.Equal(t, 0, len(users.users))
+}
+
 func TestUsersFinishLoginExistingDisconnectedUser(t *testing.T) {
 	m := initMocks(t)
 	defer m.ctrl.Finish()
@@ -160,8 +279,8 @@ func TestUsersFinishLoginExistingDisconnectedUser(t *testing.T) {
 
 		// getAPIUser() loads user info from API (e.g. userID).
 		m.pmapiClient.EXPECT().AuthSalt().Return("", nil),
-		m.pmapiClient.EXPECT().Unlock([]byte(testCredentials.MailboxPassword)).Return(nil),
 		m.pmapiClient.EXPECT().CurrentUser().Return(testPMAPIUser, nil),
+		m.pmapiClient.EXPECT().Unlock([]byte(testCredentials.MailboxPassword)).Return(nil),
 
 		// connectExistingUser()
 		m.credentialsStore.EXPECT().UpdatePassword("user", testCredentials.MailboxPassword).Return(nil),
@@ -208,8 +327,8 @@ func TestUsersFinishLoginConnectedUser(t *testing.T) {
 	// Then, try to log in again...
 	gomock.InOrder(
 		m.pmapiClient.EXPECT().AuthSalt().Return("", nil),
-		m.pmapiClient.EXPECT().Unlock([]byte(testCredentials.MailboxPassword)).Return(nil),
 		m.pmapiClient.EXPECT().CurrentUser().Return(testPMAPIUser, nil),
+		m.pmapiClient.EXPECT().Unlock([]byte(testCredentials.MailboxPassword)).Return(nil),
 		m.pmapiClient.EXPECT().DeleteAuth(),
 		m.pmapiClient.EXPECT().Logout(),
 	)
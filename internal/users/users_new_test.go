@@ -20,6 +20,7 @@ package users
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/ProtonMail/proton-bridge/internal/events"
 	"github.com/ProtonMail/proton-bridge/internal/users/credentials"
@@ -33,8 +34,115 @@ func TestNewUsersNoKeychain(t *testing.T) {
 	defer m.ctrl.Finish()
 
 	m.credentialsStore.EXPECT().List().Return([]string{}, errors.New("no keychain"))
+	m.eventListener.EXPECT().Emit(events.KeychainLockedEvent, "no keychain")
 
-	checkUsersNew(t, m, []*credentials.Credentials{})
+	users := testNewUsers(t, m)
+	defer users.StopWatchers()
+
+	assert.Empty(t, users.GetUsers())
+	assert.EqualError(t, users.GetKeychainError(), "no keychain")
+}
+
+func TestNewUsersKeychainUnlockedLater(t *testing.T) {
+	m := initMocks(t)
+	defer m.ctrl.Finish()
+
+	defer func(interval time.Duration) { keychainRetryInterval = interval }(keychainRetryInterval)
+	keychainRetryInterval = 10 * time.Millisecond
+
+	unlocked := make(chan struct{})
+
+	gomock.InOrder(
+		m.credentialsStore.EXPECT().List().Return(nil, errors.New("keychain is locked")),
+		m.eventListener.EXPECT().Emit(events.KeychainLockedEvent, "keychain is locked"),
+		m.credentialsStore.EXPECT().Reconnect().Return(errors.New("keychain is locked")),
+		m.credentialsStore.EXPECT().Reconnect().Return(nil),
+		m.credentialsStore.EXPECT().List().Return([]string{}, nil),
+		m.eventListener.EXPECT().Emit(events.KeychainUnlockedEvent, ""),
+		m.eventListener.EXPECT().Emit(events.UserRefreshEvent, "").Do(func(string, string) { close(unlocked) }),
+	)
+	m.credentialsStore.EXPECT().Reconnect().Return(nil).AnyTimes()
+
+	users := testNewUsers(t, m)
+	defer users.StopWatchers()
+
+	select {
+	case <-unlocked:
+	case <-time.After(time.Second):
+		assert.Fail(t, "accounts were not unlocked")
+	}
+
+	assert.NoError(t, users.GetKeychainError())
+}
+
+func TestNewUsersUnreadableUserUnlockedLater(t *testing.T) {
+	m := initMocks(t)
+	defer m.ctrl.Finish()
+
+	defer func(interval time.Duration) { keychainRetryInterval = interval }(keychainRetryInterval)
+	keychainRetryInterval = 10 * time.Millisecond
+
+	unlocked := make(chan struct{})
+
+	m.clientManager.EXPECT().GetClient("user").Return(m.pmapiClient).MinTimes(1)
+
+	// Locked collection is listed but the credentials cannot be read.
+	gomock.InOrder(
+		m.credentialsStore.EXPECT().List().Return([]string{"user"}, nil),
+		m.credentialsStore.EXPECT().Get("user").Return(nil, errors.New("collection is locked")),
+		m.eventListener.EXPECT().Emit(events.KeychainLockedEvent, "failed to load user credentials: collection is locked"),
+		m.credentialsStore.EXPECT().Reconnect().Return(nil),
+		m.credentialsStore.EXPECT().List().Return([]string{"user"}, nil),
+	)
+	mockConnectedUser(m)
+	mockEventLoopNoAction(m)
+	gomock.InOrder(
+		m.eventListener.EXPECT().Emit(events.KeychainUnlockedEvent, ""),
+		m.eventListener.EXPECT().Emit(events.UserRefreshEvent, "").Do(func(string, string) { close(unlocked) }),
+	)
+	m.credentialsStore.EXPECT().Reconnect().Return(nil).AnyTimes()
+
+	users := testNewUsers(t, m)
+	defer cleanUpUsersData(users)
+	defer users.StopWatchers()
+
+	select {
+	case <-unlocked:
+	case <-time.After(time.Second):
+		assert.Fail(t, "accounts were not unlocked")
+	}
+
+	assert.NoError(t, users.GetKeychainError())
+	assert.Equal(t, 1, len(users.GetUsers()))
+}
+
+func TestNewUsersKeychainLockedAgain(t *testing.T) {
+	m := initMocks(t)
+	defer m.ctrl.Finish()
+
+	defer func(interval time.Duration) { keychainRetryInterval = interval }(keychainRetryInterval)
+	keychainRetryInterval = 10 * time.Millisecond
+
+	locked := make(chan struct{})
+
+	gomock.InOrder(
+		m.credentialsStore.EXPECT().List().Return([]string{}, nil),
+		m.credentialsStore.EXPECT().Reconnect().Return(nil),
+		m.credentialsStore.EXPECT().Reconnect().Return(errors.New("collection is locked")),
+		m.eventListener.EXPECT().Emit(events.KeychainLockedEvent, "collection is locked").Do(func(string, string) { close(locked) }),
+	)
+	m.credentialsStore.EXPECT().Reconnect().Return(errors.New("collection is locked")).AnyTimes()
+
+	users := testNewUsers(t, m)
+	defer users.StopWatchers()
+
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		assert.Fail(t, "accounts were not locked")
+	}
+
+	assert.EqualError(t, users.GetKeychainError(), "collection is locked")
 }
 
 func TestNewUsersWithoutUsersInCredentialsStore(t *testing.T) {
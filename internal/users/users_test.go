@@ -183,6 +183,9 @@ func initMocks(t *testing.T) mocks {
 	// Called during clean-up.
 	m.PanicHandler.EXPECT().HandlePanic().AnyTimes()
 
+	// Emitted by store when sync finishes.
+	m.eventListener.EXPECT().Emit(events.SyncFinishedEvent, gomock.Any()).AnyTimes()
+
 	// Set up store factory.
 	m.storeMaker.EXPECT().New(gomock.Any()).DoAndReturn(func(user store.BridgeUser) (*store.Store, error) {
 		dbFile, err := ioutil.TempFile("", "bridge-store-db-*.db")
@@ -85,8 +85,9 @@ func (c *Config) CreateDirs() error {
 	return nil
 }
 
-// ClearData removes all files except the lock file.
-// The lock file will be removed when the Bridge stops.
+// ClearData removes all files except the lock file and the keychain file.
+// The lock file will be removed when the Bridge stops. The keychain file is
+// kept the same way as credentials in the system keychain are.
 func (c *Config) ClearData() error {
 	dirs := []string{
 		c.appDirs.UserLogs(),
@@ -94,7 +95,7 @@ func (c *Config) ClearData() error {
 		c.appDirs.UserCache(),
 	}
 	shouldRemove := func(filePath string) bool {
-		return filePath != c.GetLockPath()
+		return filePath != c.GetLockPath() && filePath != c.GetKeychainFilePath()
 	}
 	return c.removeAllExcept(dirs, shouldRemove)
 }
@@ -198,6 +199,21 @@ func (c *Config) GetTLSKeyPath() string {
 	return filepath.Join(c.appDirs.UserConfig(), "key.pem")
 }
 
+// GetOutgoingPolicyPath returns path to optional file with rules for messages sent over SMTP.
+func (c *Config) GetOutgoingPolicyPath() string {
+	return filepath.Join(c.appDirs.UserConfig(), "outgoing_policy.json")
+}
+
+// GetHooksPath returns path to optional file with hooks run on bridge events.
+func (c *Config) GetHooksPath() string {
+	return filepath.Join(c.appDirs.UserConfig(), "hooks.json")
+}
+
+// GetKeychainFilePath returns path to the encrypted file keychain; used when no system keychain is available.
+func (c *Config) GetKeychainFilePath() string {
+	return filepath.Join(c.appDirs.UserConfig(), "keychain.asc")
+}
+
 // GetDBDir returns folder for db files.
 func (c *Config) GetDBDir() string {
 	return filepath.Join(c.appDirsVersion.UserCache())
@@ -238,7 +254,12 @@ func (c *Config) GetDefaultIMAPPort() int {
 	return 1143
 }
 
-// GetDefaultSMTPPort returns default Bridge SMTP port.
+// GetDefaultSMTPPort returns default Bridge SMTP port using STARTTLS.
 func (c *Config) GetDefaultSMTPPort() int {
 	return 1025
 }
+
+// GetDefaultSMTPSSLPort returns default Bridge SMTP port using implicit SSL.
+func (c *Config) GetDefaultSMTPSSLPort() int {
+	return 1465
+}
@@ -41,3 +41,8 @@ func SetClientRoundTripper(_ *pmapi.ClientManager, _ *pmapi.ClientConfig, _ list
 func (c *Config) GetRoundTripper(_ *pmapi.ClientManager, _ listener.Listener) http.RoundTripper {
 	return http.DefaultTransport
 }
+
+func (c *Config) GetRoundTripperFactory(_ *pmapi.ClientManager, _ listener.Listener) func(pmapi.HostSettings) http.RoundTripper {
+	// Use the default roundtripper for all host settings.
+	return nil
+}
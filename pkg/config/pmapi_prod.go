@@ -40,18 +40,37 @@ func (c *Config) GetAPIConfig() *pmapi.ClientConfig {
 }
 
 func (c *Config) GetRoundTripper(cm *pmapi.ClientManager, listener listener.Listener) http.RoundTripper {
+	return c.newRoundTripper(cm, listener, pmapi.HostSettings{})
+}
+
+// GetRoundTripperFactory returns function creating round trippers for accounts
+// with their own host settings (see pmapi.ClientManager.SetRoundTripperFactory).
+func (c *Config) GetRoundTripperFactory(cm *pmapi.ClientManager, listener listener.Listener) func(pmapi.HostSettings) http.RoundTripper {
+	return func(settings pmapi.HostSettings) http.RoundTripper {
+		return c.newRoundTripper(cm, listener, settings)
+	}
+}
+
+func (c *Config) newRoundTripper(cm *pmapi.ClientManager, listener listener.Listener, settings pmapi.HostSettings) http.RoundTripper {
 	// We use a TLS dialer.
-	basicDialer := pmapi.NewBasicTLSDialer()
+	var dialer pmapi.TLSDialer = pmapi.NewBasicTLSDialer()
+
+	if !settings.NoPinning {
+		// We wrap the TLS dialer in a layer which enforces connections to trusted servers.
+		pinningDialer := pmapi.NewPinningTLSDialer(dialer)
 
-	// We wrap the TLS dialer in a layer which enforces connections to trusted servers.
-	pinningDialer := pmapi.NewPinningTLSDialer(basicDialer)
+		// We want any pin mismatches to be communicated back to bridge GUI and reported.
+		pinningDialer.SetTLSIssueNotifier(func() { listener.Emit(events.TLSCertIssue, "") })
+		pinningDialer.EnableRemoteTLSIssueReporting(c.GetAPIConfig().AppVersion, c.GetAPIConfig().UserAgent)
 
-	// We want any pin mismatches to be communicated back to bridge GUI and reported.
-	pinningDialer.SetTLSIssueNotifier(func() { listener.Emit(events.TLSCertIssue, "") })
-	pinningDialer.EnableRemoteTLSIssueReporting(c.GetAPIConfig().AppVersion, c.GetAPIConfig().UserAgent)
+		dialer = pinningDialer
+	}
 
-	// We wrap the pinning dialer in a layer which adds "alternative routing" feature.
-	proxyDialer := pmapi.NewProxyTLSDialer(pinningDialer, cm)
+	// We wrap the dialer in a layer which adds "alternative routing" feature.
+	// Proxies are available only for the default API host.
+	if !settings.NoProxy && settings.URL == "" {
+		dialer = pmapi.NewProxyTLSDialer(dialer, cm)
+	}
 
-	return pmapi.CreateTransportWithDialer(proxyDialer)
+	return pmapi.CreateTransportWithDialer(dialer)
 }
@@ -0,0 +1,75 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package fido2
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+)
+
+var (
+	ErrNoAuthenticator     = errors.New("security keys are not supported on this system")
+	ErrSecurityKeyRequired = errors.New("account requires a security key, which is not supported on this system")
+
+	defaultAuthenticator     pmapi.FIDO2Authenticator //nolint[gochecknoglobals]
+	defaultAuthenticatorLock sync.RWMutex             //nolint[gochecknoglobals]
+)
+
+// SetDefault plugs the authenticator used by login flows in, e.g. one
+// talking to hardware keys over CTAP2. Nil removes it.
+func SetDefault(authenticator pmapi.FIDO2Authenticator) {
+	defaultAuthenticatorLock.Lock()
+	defer defaultAuthenticatorLock.Unlock()
+
+	defaultAuthenticator = authenticator
+}
+
+// Default returns the plugged authenticator or ErrNoAuthenticator.
+func Default() (pmapi.FIDO2Authenticator, error) {
+	defaultAuthenticatorLock.RLock()
+	defer defaultAuthenticatorLock.RUnlock()
+
+	if defaultAuthenticator == nil {
+		return nil, ErrNoAuthenticator
+	}
+
+	return defaultAuthenticator, nil
+}
+
+// ForAuth returns the authenticator to answer the second factor of `auth`.
+// It returns nil when the account has no security key or no authenticator is
+// plugged in but a TOTP code can be used instead. When the security key is
+// the only second factor and it cannot be used, ErrSecurityKeyRequired is
+// returned, so the user is not asked for a code which cannot work.
+func ForAuth(auth *pmapi.Auth) (pmapi.FIDO2Authenticator, error) {
+	if !auth.HasFIDO2() {
+		return nil, nil
+	}
+
+	authenticator, err := Default()
+	if err != nil {
+		if !auth.HasTOTP() {
+			return nil, ErrSecurityKeyRequired
+		}
+		return nil, nil
+	}
+
+	return authenticator, nil
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package fido2
+
+import (
+	"testing"
+
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForAuth(t *testing.T) {
+	authenticator, err := NewSoftwareAuthenticator()
+	require.NoError(t, err)
+
+	keyOnly := newAuth([]byte("challenge"), authenticator.CredentialID())
+	keyOrTOTP := newAuth([]byte("challenge"), authenticator.CredentialID())
+	keyOrTOTP.TwoFA.Enabled = 3
+	totpOnly := &pmapi.Auth{TwoFA: &pmapi.TwoFactorInfo{Enabled: 1}}
+
+	// Without authenticator, TOTP is used when possible.
+	SetDefault(nil)
+	_, err = ForAuth(keyOnly)
+	require.Equal(t, ErrSecurityKeyRequired, err)
+
+	for _, auth := range []*pmapi.Auth{keyOrTOTP, totpOnly} {
+		have, err := ForAuth(auth)
+		require.NoError(t, err)
+		require.Nil(t, have)
+	}
+
+	SetDefault(authenticator)
+	defer SetDefault(nil)
+
+	for _, auth := range []*pmapi.Auth{keyOnly, keyOrTOTP} {
+		have, err := ForAuth(auth)
+		require.NoError(t, err)
+		require.Equal(t, authenticator, have)
+	}
+
+	have, err := ForAuth(totpOnly)
+	require.NoError(t, err)
+	require.Nil(t, have)
+}
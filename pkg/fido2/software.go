@@ -0,0 +1,134 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package fido2 provides FIDO2 authenticators for two factor authentication
+// with a security key.
+package fido2
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+)
+
+const (
+	flagUserPresent    = 0x01
+	credentialIDLength = 64
+)
+
+var ErrNoCredentials = errors.New("none of the allowed credentials belongs to this authenticator")
+
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// SoftwareAuthenticator is a security key kept in memory. It answers
+// authenticatorGetAssertion the same way as hardware keys and is meant for tests.
+type SoftwareAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+
+	lock      sync.Mutex
+	signCount uint32
+}
+
+func NewSoftwareAuthenticator() (*SoftwareAuthenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	credentialID := make([]byte, credentialIDLength)
+	if _, err := rand.Read(credentialID); err != nil {
+		return nil, err
+	}
+
+	return &SoftwareAuthenticator{key: key, credentialID: credentialID}, nil
+}
+
+// CredentialID returns the ID of the credential to register with the account.
+func (a *SoftwareAuthenticator) CredentialID() []byte {
+	return a.credentialID
+}
+
+// PublicKey returns the key of the credential to register with the account.
+func (a *SoftwareAuthenticator) PublicKey() *ecdsa.PublicKey {
+	return &a.key.PublicKey
+}
+
+// GetAssertion implements pmapi.FIDO2Authenticator.
+func (a *SoftwareAuthenticator) GetAssertion(rpID string, clientDataHash []byte, allowCredentials [][]byte) (*pmapi.FIDO2Assertion, error) {
+	if !a.isAllowed(allowCredentials) {
+		return nil, ErrNoCredentials
+	}
+
+	a.lock.Lock()
+	a.signCount++
+	signCount := a.signCount
+	a.lock.Unlock()
+
+	authData := authenticatorData(rpID, signCount)
+
+	hash := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash...))
+	r, s, err := ecdsa.Sign(rand.Reader, a.key, hash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := asn1.Marshal(ecdsaSignature{R: r, S: s})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pmapi.FIDO2Assertion{
+		CredentialID:      a.credentialID,
+		AuthenticatorData: authData,
+		Signature:         signature,
+	}, nil
+}
+
+func (a *SoftwareAuthenticator) isAllowed(allowCredentials [][]byte) bool {
+	for _, credentialID := range allowCredentials {
+		if bytes.Equal(credentialID, a.credentialID) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticatorData is the hash of the relying party ID, the flags and the
+// signature counter. Assertions carry no attested credential data.
+func authenticatorData(rpID string, signCount uint32) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flagUserPresent)
+
+	counter := make([]byte, 4)
+	binary.BigEndian.PutUint32(counter, signCount)
+
+	return append(data, counter...)
+}
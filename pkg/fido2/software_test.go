@@ -0,0 +1,107 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package fido2
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/ProtonMail/proton-bridge/pkg/pmapi"
+	"github.com/stretchr/testify/require"
+)
+
+// verify checks the request the same way the server does and returns the signature counter.
+func verify(t *testing.T, publicKey *ecdsa.PublicKey, options pmapi.FIDO2PublicKeyOptions, req *pmapi.FIDO2Req) uint32 {
+	clientData, err := base64.StdEncoding.DecodeString(req.ClientData)
+	require.NoError(t, err)
+
+	var collected map[string]string
+	require.NoError(t, json.Unmarshal(clientData, &collected))
+	require.Equal(t, map[string]string{
+		"type":      "webauthn.get",
+		"challenge": base64.RawURLEncoding.EncodeToString(options.Challenge),
+		"origin":    "https://" + options.RpID,
+	}, collected)
+
+	authData, err := base64.StdEncoding.DecodeString(req.AuthenticatorData)
+	require.NoError(t, err)
+	require.Len(t, authData, 37)
+
+	rpIDHash := sha256.Sum256([]byte(options.RpID))
+	require.Equal(t, rpIDHash[:], authData[:32])
+	require.Equal(t, byte(flagUserPresent), authData[32]&flagUserPresent)
+
+	signature, err := base64.StdEncoding.DecodeString(req.Signature)
+	require.NoError(t, err)
+
+	var sig ecdsaSignature
+	_, err = asn1.Unmarshal(signature, &sig)
+	require.NoError(t, err)
+
+	clientDataHash := sha256.Sum256(clientData)
+	hash := sha256.Sum256(append(authData, clientDataHash[:]...))
+	require.True(t, ecdsa.Verify(publicKey, hash[:], sig.R, sig.S))
+
+	return binary.BigEndian.Uint32(authData[33:])
+}
+
+func newAuth(challenge []byte, credentialIDs ...[]byte) *pmapi.Auth {
+	options := pmapi.FIDO2PublicKeyOptions{Challenge: challenge, RpID: "protonmail.com"}
+	for _, id := range credentialIDs {
+		options.AllowCredentials = append(options.AllowCredentials, pmapi.FIDO2Credential{Type: "public-key", ID: id})
+	}
+
+	return &pmapi.Auth{TwoFA: &pmapi.TwoFactorInfo{
+		Enabled: 2,
+		FIDO2:   pmapi.FIDO2Info{AuthenticationOptions: pmapi.FIDO2AuthenticationOptions{PublicKey: options}},
+	}}
+}
+
+func TestSoftwareAuthenticatorAssertion(t *testing.T) {
+	authenticator, err := NewSoftwareAuthenticator()
+	require.NoError(t, err)
+
+	auth := newAuth([]byte("challenge"), []byte("other"), authenticator.CredentialID())
+	options := auth.TwoFA.FIDO2.AuthenticationOptions.PublicKey
+
+	req, err := pmapi.NewFIDO2Req(authenticator, auth)
+	require.NoError(t, err)
+	require.Equal(t, authenticator.CredentialID(), req.CredentialID)
+	require.Equal(t, uint32(1), verify(t, authenticator.PublicKey(), options, req))
+
+	// Counter increases with each assertion.
+	req, err = pmapi.NewFIDO2Req(authenticator, auth)
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), verify(t, authenticator.PublicKey(), options, req))
+}
+
+func TestSoftwareAuthenticatorUnknownCredential(t *testing.T) {
+	authenticator, err := NewSoftwareAuthenticator()
+	require.NoError(t, err)
+
+	_, err = pmapi.NewFIDO2Req(authenticator, newAuth([]byte("challenge"), []byte("other")))
+	require.Equal(t, ErrNoCredentials, err)
+
+	_, err = pmapi.NewFIDO2Req(authenticator, newAuth([]byte("challenge")))
+	require.Equal(t, pmapi.ErrNoFIDO2Key, err)
+}
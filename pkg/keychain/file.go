@@ -0,0 +1,196 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package keychain
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/pkg/errors"
+)
+
+// HelperFile is the name of the built-in keychain stored in an encrypted file.
+const HelperFile = "file"
+
+var (
+	ErrEmptyPassphrase = errors.New("keychain passphrase is empty")
+	ErrWrongPassphrase = errors.New("keychain file cannot be decrypted, wrong passphrase?")
+)
+
+// FileHelper stores credentials in one file encrypted by a passphrase.
+// It is meant for headless servers and containers where neither pass nor
+// secret service is available.
+type FileHelper struct {
+	path       string
+	passphrase []byte
+	lock       sync.Mutex
+}
+
+type fileItem struct {
+	Username string
+	Secret   string
+}
+
+// NewFileHelper returns helper using keychain file at `path`. The file is
+// created with the first item. Existing file is checked to be decryptable
+// by `passphrase`, so a wrong passphrase is reported right away.
+func NewFileHelper(path string, passphrase []byte) (*FileHelper, error) {
+	if len(passphrase) == 0 {
+		return nil, ErrEmptyPassphrase
+	}
+
+	helper := &FileHelper{
+		path:       path,
+		passphrase: passphrase,
+	}
+
+	if _, err := helper.load(); err != nil {
+		return nil, err
+	}
+
+	return helper, nil
+}
+
+// Add appends credentials to the store; existing item with the same URL is replaced.
+func (h *FileHelper) Add(cred *credentials.Credentials) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	items, err := h.load()
+	if err != nil {
+		return err
+	}
+
+	items[cred.ServerURL] = fileItem{Username: cred.Username, Secret: cred.Secret}
+
+	return h.save(items)
+}
+
+// Delete removes credentials from the store.
+func (h *FileHelper) Delete(serverURL string) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	items, err := h.load()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := items[serverURL]; !ok {
+		return credentials.NewErrCredentialsNotFound()
+	}
+	delete(items, serverURL)
+
+	return h.save(items)
+}
+
+// Get retrieves credentials from the store.
+// It returns username and secret as strings.
+func (h *FileHelper) Get(serverURL string) (string, string, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	items, err := h.load()
+	if err != nil {
+		return "", "", err
+	}
+
+	item, ok := items[serverURL]
+	if !ok {
+		return "", "", credentials.NewErrCredentialsNotFound()
+	}
+
+	return item.Username, item.Secret, nil
+}
+
+// List returns the stored serverURLs and their associated usernames.
+func (h *FileHelper) List() (map[string]string, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	items, err := h.load()
+	if err != nil {
+		return nil, err
+	}
+
+	userIDByURL := make(map[string]string)
+	for serverURL, item := range items {
+		userIDByURL[serverURL] = item.Username
+	}
+
+	return userIDByURL, nil
+}
+
+func (h *FileHelper) load() (map[string]fileItem, error) {
+	items := make(map[string]fileItem)
+
+	armored, err := ioutil.ReadFile(h.path)
+	if os.IsNotExist(err) {
+		return items, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read keychain file")
+	}
+
+	enc, err := crypto.NewPGPMessageFromArmored(string(armored))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot parse keychain file")
+	}
+
+	dec, err := crypto.DecryptMessageWithPassword(enc, h.passphrase)
+	if err != nil {
+		log.WithError(err).Warn("Cannot decrypt keychain file")
+		return nil, ErrWrongPassphrase
+	}
+
+	if err := json.Unmarshal(dec.GetBinary(), &items); err != nil {
+		return nil, errors.Wrap(err, "cannot parse keychain file")
+	}
+
+	return items, nil
+}
+
+// save writes the file atomically to not lose all credentials when
+// the write is interrupted.
+func (h *FileHelper) save(items map[string]fileItem) error {
+	raw, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	enc, err := crypto.EncryptMessageWithPassword(crypto.NewPlainMessage(raw), h.passphrase)
+	if err != nil {
+		return errors.Wrap(err, "cannot encrypt keychain file")
+	}
+
+	armored, err := enc.GetArmored()
+	if err != nil {
+		return err
+	}
+
+	tmpPath := h.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, []byte(armored), 0600); err != nil {
+		return errors.Wrap(err, "cannot write keychain file")
+	}
+
+	return os.Rename(tmpPath, h.path)
+}
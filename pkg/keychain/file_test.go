@@ -0,0 +1,136 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package keychain
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFileHelper(t *testing.T, dir, passphrase string) *FileHelper {
+	helper, err := NewFileHelper(filepath.Join(dir, "keychain.asc"), []byte(passphrase))
+	require.NoError(t, err)
+	return helper
+}
+
+func TestFileHelper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keychain")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint[errcheck]
+
+	helper := newTestFileHelper(t, dir, "passphrase")
+
+	list, err := helper.List()
+	require.NoError(t, err)
+	require.Empty(t, list)
+
+	_, _, err = helper.Get("protonmail/bridge/users/user1")
+	require.True(t, credentials.IsErrCredentialsNotFound(err))
+
+	for id, secret := range testData {
+		require.NoError(t, helper.Add(&credentials.Credentials{
+			ServerURL: "protonmail/bridge/users/" + id,
+			Username:  id,
+			Secret:    secret,
+		}))
+	}
+
+	// Items are readable with the same passphrase only.
+	_, err = NewFileHelper(filepath.Join(dir, "keychain.asc"), []byte("wrong"))
+	require.Equal(t, ErrWrongPassphrase, err)
+
+	raw, err := ioutil.ReadFile(filepath.Join(dir, "keychain.asc"))
+	require.NoError(t, err)
+	require.False(t, strings.Contains(string(raw), testData["user1"]))
+
+	helper = newTestFileHelper(t, dir, "passphrase")
+
+	list, err = helper.List()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"protonmail/bridge/users/user1": "user1",
+		"protonmail/bridge/users/user2": "user2",
+	}, list)
+
+	userID, secret, err := helper.Get("protonmail/bridge/users/user2")
+	require.NoError(t, err)
+	require.Equal(t, "user2", userID)
+	require.Equal(t, testData["user2"], secret)
+
+	require.NoError(t, helper.Delete("protonmail/bridge/users/user2"))
+	require.True(t, credentials.IsErrCredentialsNotFound(helper.Delete("protonmail/bridge/users/user2")))
+
+	list, err = helper.List()
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+}
+
+func TestMigrateFrom(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keychain")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint[errcheck]
+
+	sourceDir := filepath.Join(dir, "source")
+	require.NoError(t, os.Mkdir(sourceDir, 0700))
+
+	source := NewAccessWithHelper("bridge", newTestFileHelper(t, sourceDir, "old"))
+	target := NewAccessWithHelper("bridge", newTestFileHelper(t, dir, "new"))
+
+	for id, secret := range testData {
+		require.NoError(t, source.Put(id, secret))
+	}
+
+	migrated, err := target.MigrateFrom(source)
+	require.NoError(t, err)
+	require.Equal(t, len(testData), migrated)
+
+	sourceIDs, err := source.List()
+	require.NoError(t, err)
+	require.Empty(t, sourceIDs)
+
+	for id, secret := range testData {
+		actualSecret, err := target.Get(id)
+		require.NoError(t, err)
+		require.Equal(t, secret, actualSecret)
+	}
+}
+
+func TestReadPassphrase(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	_, err = w.WriteString("from fd\r\nsecond line\n")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	passphrase, err := ReadPassphrase(int(r.Fd()), "")
+	require.NoError(t, err)
+	require.Equal(t, "from fd", string(passphrase))
+
+	require.NoError(t, os.Setenv("TEST_KEYCHAIN_PASSPHRASE", "from env"))
+	defer os.Unsetenv("TEST_KEYCHAIN_PASSPHRASE") //nolint[errcheck]
+
+	passphrase, err = ReadPassphrase(-1, "TEST_KEYCHAIN_PASSPHRASE")
+	require.NoError(t, err)
+	require.Equal(t, "from env", string(passphrase))
+}
@@ -20,6 +20,7 @@ package keychain
 
 import (
 	"errors"
+	"sort"
 	"strings"
 	"sync"
 
@@ -38,6 +39,7 @@ var (
 	ErrMacKeychainRebuild  = errors.New("keychain error -25293")
 	ErrMacKeychainList     = errors.New("function `osxkeychain.List()` is not valid function for mac keychain. Use `Access.ListKeychain()` instead")
 	ErrNoKeychainInstalled = errors.New("no keychain management installed on this system")
+	ErrUnknownHelper       = errors.New("unknown keychain helper")
 	accessLocker           = &sync.Mutex{} //nolint[gochecknoglobals]
 )
 
@@ -47,13 +49,42 @@ func NewAccess(appName string) (*Access, error) {
 	if err != nil {
 		return nil, err
 	}
+	return NewAccessWithHelper(appName, newHelper), nil
+}
+
+// NewAccessWithHelper creates a new keychain which stores items using `helper`.
+func NewAccessWithHelper(appName string, helper credentials.Helper) *Access {
 	return &Access{
-		helper:            newHelper,
+		helper:            helper,
 		KeychainURL:       "protonmail/" + appName + "/users",
 		KeychainOldURL:    "protonmail/users",
 		KeychainMacURL:    "ProtonMail" + strings.Title(appName) + "Service",
 		KeychainOldMacURL: "ProtonMailService",
-	}, nil
+	}
+}
+
+// NewHelper returns the system keychain helper called `name`; the first usable
+// one when `name` is empty. The file helper has to be created by NewFileHelper.
+func NewHelper(name string) (credentials.Helper, error) {
+	if name == "" {
+		return newKeychain()
+	}
+
+	newHelper, ok := getPlatformHelpers()[name]
+	if !ok {
+		return nil, ErrUnknownHelper
+	}
+
+	return newHelper()
+}
+
+// Helpers returns names of all keychain helpers available on this platform.
+func Helpers() (names []string) {
+	for name := range getPlatformHelpers() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append(names, HelperFile)
 }
 
 type Access struct {
@@ -119,6 +150,49 @@ func (s *Access) Put(userID, secret string) error {
 	return s.helper.Add(cred)
 }
 
+// CheckUnlocked returns an error when the keychain is locked. Only one item
+// is read; keychains which cannot be locked while running are not read at all.
+func (s *Access) CheckUnlocked() error {
+	if !isLockable(s.helper) {
+		return nil
+	}
+
+	userIDs, err := s.List()
+	if err != nil || len(userIDs) == 0 {
+		return err
+	}
+
+	_, err = s.Get(userIDs[0])
+	return err
+}
+
+// MigrateFrom moves all items from the `source` keychain to this one.
+// Items are removed from `source` only once they are stored here.
+func (s *Access) MigrateFrom(source *Access) (migrated int, err error) {
+	userIDs, err := source.List()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, userID := range userIDs {
+		secret, err := source.Get(userID)
+		if err != nil {
+			return migrated, err
+		}
+
+		if err := s.Put(userID, secret); err != nil {
+			return migrated, err
+		}
+		migrated++
+
+		if err := source.Delete(userID); err != nil {
+			log.WithError(err).WithField("userID", userID).Warn("Migrated item cannot be removed from the old keychain")
+		}
+	}
+
+	return migrated, nil
+}
+
 func splitServiceAndID(keychainName string) (serviceName string, userID string, err error) { //nolint[unused]
 	splitted := strings.FieldsFunc(keychainName, func(c rune) bool { return c == '/' })
 	n := len(splitted)
@@ -32,9 +32,17 @@ func (s *Access) KeychainOldName(userID string) string {
 	return s.KeychainOldMacURL + "/" + userID
 }
 
+const HelperMacKeychain = "macos-keychain"
+
 type osxkeychain struct {
 }
 
+func getPlatformHelpers() map[string]func() (credentials.Helper, error) {
+	return map[string]func() (credentials.Helper, error){
+		HelperMacKeychain: newKeychain,
+	}
+}
+
 func newKeychain() (credentials.Helper, error) {
 	log.Debug("Creating osckeychain")
 	return &osxkeychain{}, nil
@@ -108,6 +116,11 @@ func (s *osxkeychain) Get(serverURL string) (userID string, secret string, err e
 
 // ListKeychain lists items in our services.
 func (s *Access) ListKeychain() (userIDByURL map[string]string, err error) {
+	// Other helpers, such as the file one, can list items themselves.
+	if _, ok := s.helper.(*osxkeychain); !ok {
+		return s.helper.List()
+	}
+
 	// Pick up correct service name and trim '/'.
 	serviceName, _, err := splitServiceAndID(s.KeychainOldName("not-id"))
 	if err != nil {
@@ -138,3 +151,10 @@ func (s *osxkeychain) List() (userIDByURL map[string]string, err error) {
 	err = ErrMacKeychainList
 	return
 }
+
+// isLockable returns whether the keychain of `helper` can be locked while
+// bridge runs.
+func isLockable(helper credentials.Helper) bool {
+	_, isFile := helper.(*FileHelper)
+	return !isFile
+}
@@ -23,17 +23,25 @@ import (
 	"github.com/docker/docker-credential-helpers/secretservice"
 )
 
+const (
+	HelperPass          = "pass"
+	HelperSecretService = "secret-service"
+)
+
+func getPlatformHelpers() map[string]func() (credentials.Helper, error) {
+	return map[string]func() (credentials.Helper, error){
+		HelperPass:          newPassHelper,
+		HelperSecretService: newSecretServiceHelper,
+	}
+}
+
 func newKeychain() (credentials.Helper, error) {
-	log.Debug("Creating pass")
-	passHelper := &pass.Pass{}
-	passErr := checkPassIsUsable(passHelper)
+	passHelper, passErr := newPassHelper()
 	if passErr == nil {
 		return passHelper, nil
 	}
 
-	log.Debug("Creating secretservice")
-	sserviceHelper := &secretservice.Secretservice{}
-	_, sserviceErr := sserviceHelper.List()
+	sserviceHelper, sserviceErr := newSecretServiceHelper()
 	if sserviceErr == nil {
 		return sserviceHelper, nil
 	}
@@ -42,6 +50,24 @@ func newKeychain() (credentials.Helper, error) {
 	return nil, ErrNoKeychainInstalled
 }
 
+func newPassHelper() (credentials.Helper, error) {
+	log.Debug("Creating pass")
+	passHelper := &pass.Pass{}
+	if err := checkPassIsUsable(passHelper); err != nil {
+		return nil, err
+	}
+	return passHelper, nil
+}
+
+func newSecretServiceHelper() (credentials.Helper, error) {
+	log.Debug("Creating secretservice")
+	sserviceHelper := &secretservice.Secretservice{}
+	if _, err := sserviceHelper.List(); err != nil {
+		return nil, err
+	}
+	return sserviceHelper, nil
+}
+
 func checkPassIsUsable(passHelper *pass.Pass) (err error) {
 	creds := &credentials.Credentials{
 		ServerURL: "initCheck/pass",
@@ -71,3 +97,13 @@ func (s *Access) KeychainOldName(userID string) string {
 func (s *Access) ListKeychain() (map[string]string, error) {
 	return s.helper.List()
 }
+
+// isLockable returns whether the keychain of `helper` can be locked while
+// bridge runs. Pass relies on gpg-agent which would ask for the passphrase.
+func isLockable(helper credentials.Helper) bool {
+	switch helper.(type) {
+	case *pass.Pass, *FileHelper:
+		return false
+	}
+	return true
+}
@@ -22,6 +22,14 @@ import (
 	"github.com/docker/docker-credential-helpers/wincred"
 )
 
+const HelperWincred = "wincred"
+
+func getPlatformHelpers() map[string]func() (credentials.Helper, error) {
+	return map[string]func() (credentials.Helper, error){
+		HelperWincred: newKeychain,
+	}
+}
+
 func newKeychain() (credentials.Helper, error) {
 	log.Debug("Creating wincred")
 	return &wincred.Wincred{}, nil
@@ -38,3 +46,13 @@ func (s *Access) KeychainOldName(userID string) string {
 func (s *Access) ListKeychain() (map[string]string, error) {
 	return s.helper.List()
 }
+
+// isLockable returns whether the keychain of `helper` can be locked while
+// bridge runs. Windows credentials are available whenever the user is logged in.
+func isLockable(helper credentials.Helper) bool {
+	switch helper.(type) {
+	case *wincred.Wincred, *FileHelper:
+		return false
+	}
+	return true
+}
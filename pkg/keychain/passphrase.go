@@ -0,0 +1,76 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package keychain
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// ErrNoPassphrase is returned when there is no way to get the passphrase.
+var ErrNoPassphrase = errors.New("no keychain passphrase provided")
+
+// ReadPassphrase returns the passphrase of the file keychain. It is read
+// from the file descriptor `fd` when it is not negative, otherwise from the
+// environment variable `envName`. When neither is set, it is asked for on
+// the terminal.
+func ReadPassphrase(fd int, envName string) ([]byte, error) {
+	if fd >= 0 {
+		file := os.NewFile(uintptr(fd), "passphrase")
+		if file == nil {
+			return nil, errors.Errorf("invalid passphrase file descriptor %d", fd)
+		}
+		defer file.Close() //nolint[errcheck]
+
+		return readPassphraseLine(file)
+	}
+
+	if passphrase := os.Getenv(envName); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+
+	stdin := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(stdin) {
+		return nil, ErrNoPassphrase
+	}
+
+	fmt.Fprint(os.Stderr, "Keychain passphrase: ")
+	passphrase, err := terminal.ReadPassword(stdin)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read keychain passphrase")
+	}
+
+	return passphrase, nil
+}
+
+// readPassphraseLine returns the first line of `r` without the line ending.
+func readPassphraseLine(r io.Reader) ([]byte, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "cannot read keychain passphrase")
+	}
+
+	return []byte(strings.TrimRight(line, "\r\n")), nil
+}
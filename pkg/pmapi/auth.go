@@ -44,11 +44,17 @@ type U2FInfo struct {
 }
 
 type TwoFactorInfo struct {
-	Enabled int // 0 for disabled, 1 for OTP, 2 for U2F, 3 for both.
+	Enabled int // 0 for disabled, 1 for OTP, 2 for security key, 3 for both.
 	TOTP    int
 	U2F     U2FInfo
+	FIDO2   FIDO2Info
 }
 
+const (
+	twoFactorTOTP = 1 << iota
+	twoFactorFIDO2
+)
+
 func (twoFactor *TwoFactorInfo) hasTwoFactor() bool {
 	return twoFactor.Enabled > 0
 }
@@ -139,6 +145,17 @@ func (s *Auth) HasTwoFactor() bool {
 	return s.TwoFA.hasTwoFactor()
 }
 
+// HasTOTP returns whether the second factor can be a TOTP code.
+func (s *Auth) HasTOTP() bool {
+	return s.TwoFA != nil && s.TwoFA.Enabled&twoFactorTOTP != 0
+}
+
+// HasFIDO2 returns whether the second factor can be a security key.
+func (s *Auth) HasFIDO2() bool {
+	return s.TwoFA != nil && s.TwoFA.Enabled&twoFactorFIDO2 != 0 &&
+		len(s.TwoFA.FIDO2.AuthenticationOptions.PublicKey.AllowCredentials) > 0
+}
+
 func (s *Auth) HasMailboxPassword() bool {
 	return s.PasswordMode == 2
 }
@@ -168,10 +185,8 @@ func (res *AuthRes) getAuth() *Auth {
 }
 
 type Auth2FAReq struct {
-	TwoFactorCode string
-
-	// Prepared for U2F:
-	// U2F U2FRequest
+	TwoFactorCode string    `json:",omitempty"`
+	FIDO2         *FIDO2Req `json:",omitempty"`
 }
 
 type Auth2FA struct {
@@ -327,10 +342,12 @@ func (c *client) Auth(username, password string, info *AuthInfo) (auth *Auth, er
 // Auth2FA will authenticate a user into full scope.
 // `Auth` struct contains method `HasTwoFactor` deciding whether this has to be done.
 func (c *client) Auth2FA(twoFactorCode string, auth *Auth) (*Auth2FA, error) {
-	auth2FAReq := &Auth2FAReq{
+	return c.auth2FA(&Auth2FAReq{
 		TwoFactorCode: twoFactorCode,
-	}
+	})
+}
 
+func (c *client) auth2FA(auth2FAReq *Auth2FAReq) (*Auth2FA, error) {
 	req, err := c.NewJSONRequest("POST", "/auth/2fa", auth2FAReq)
 	if err != nil {
 		return nil, err
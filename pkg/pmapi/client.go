@@ -47,6 +47,8 @@ const (
 	ForceUpgradeInvalidAPI    = 5004
 	ForceUpgradeBadAppVersion = 5005
 	APIOffline                = 7001
+	InvalidID                 = 2061
+	NotExists                 = 2501
 	ImportMessageTooLong      = 36022
 	BansRequests              = 85131
 )
@@ -61,6 +63,9 @@ var (
 
 type ErrUnprocessableEntity struct {
 	error
+
+	// Code is the response code from the body JSON.
+	Code int
 }
 
 func (err *ErrUnprocessableEntity) Error() string {
@@ -128,7 +133,7 @@ type client struct {
 func newClient(cm *ClientManager, userID string) *client {
 	return &client{
 		cm:            cm,
-		hc:            getHTTPClient(cm.config, cm.roundTripper),
+		hc:            getHTTPClient(cm.config, &clientRoundTripper{cm: cm, userID: userID}),
 		userID:        userID,
 		requestLocker: &sync.Mutex{},
 		refreshLocker: &sync.Mutex{},
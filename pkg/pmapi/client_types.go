@@ -29,6 +29,7 @@ type Client interface {
 	AuthInfo(username string) (*AuthInfo, error)
 	AuthRefresh(token string) (*Auth, error)
 	Auth2FA(twoFactorCode string, auth *Auth) (*Auth2FA, error)
+	Auth2FAFIDO2(authenticator FIDO2Authenticator, auth *Auth) (*Auth2FA, error)
 	AuthSalt() (salt string, err error)
 	Logout()
 	DeleteAuth() error
@@ -39,12 +39,20 @@ type ClientManager struct {
 	config       *ClientConfig
 	roundTripper http.RoundTripper
 
+	hostSettings        map[string]HostSettings
+	roundTripperFactory func(HostSettings) http.RoundTripper
+	roundTrippers       map[HostSettings]http.RoundTripper
+	hostSettingsLocker  sync.RWMutex
+
 	clients       map[string]Client
 	clientsLocker sync.Locker
 
 	tokens       map[string]string
 	tokensLocker sync.Locker
 
+	autoReauth       map[string]bool
+	autoReauthLocker sync.RWMutex
+
 	expirations       map[string]*tokenExpiration
 	expiredTokens     chan string
 	expirationsLocker sync.Locker
@@ -88,12 +96,17 @@ func NewClientManager(config *ClientConfig) (cm *ClientManager) {
 		config:       config,
 		roundTripper: http.DefaultTransport,
 
+		hostSettings:  make(map[string]HostSettings),
+		roundTrippers: make(map[HostSettings]http.RoundTripper),
+
 		clients:       make(map[string]Client),
 		clientsLocker: &sync.Mutex{},
 
 		tokens:       make(map[string]string),
 		tokensLocker: &sync.Mutex{},
 
+		autoReauth: make(map[string]bool),
+
 		expirations:       make(map[string]*tokenExpiration),
 		expiredTokens:     make(chan string),
 		expirationsLocker: &sync.Mutex{},
@@ -152,7 +165,11 @@ func (cm *ClientManager) GetClient(userID string) Client {
 
 // GetAnonymousClient returns an anonymous client.
 func (cm *ClientManager) GetAnonymousClient() Client {
-	return cm.GetClient(fmt.Sprintf("anonymous-%v", cm.idGen.next()))
+	return cm.GetClient(cm.newAnonymousID())
+}
+
+func (cm *ClientManager) newAnonymousID() string {
+	return fmt.Sprintf("anonymous-%v", cm.idGen.next())
 }
 
 // LogoutClient logs out the client with the given userID and ensures its sensitive data is successfully cleared.
@@ -172,6 +189,7 @@ func (cm *ClientManager) LogoutClient(userID string) {
 		defer cm.clearToken(userID)
 
 		if strings.HasPrefix(userID, "anonymous-") {
+			cm.SetHostSettings(userID, HostSettings{})
 			return
 		}
 
@@ -422,6 +440,26 @@ func (cm *ClientManager) clearToken(userID string) {
 	delete(cm.tokens, userID)
 }
 
+// SetAutoReauth sets whether the user with the given userID can be authenticated
+// again automatically when its refresh token is not valid anymore.
+func (cm *ClientManager) SetAutoReauth(userID string, enabled bool) {
+	cm.autoReauthLocker.Lock()
+	defer cm.autoReauthLocker.Unlock()
+
+	if enabled {
+		cm.autoReauth[userID] = true
+	} else {
+		delete(cm.autoReauth, userID)
+	}
+}
+
+func (cm *ClientManager) hasAutoReauth(userID string) bool {
+	cm.autoReauthLocker.RLock()
+	defer cm.autoReauthLocker.RUnlock()
+
+	return cm.autoReauth[userID]
+}
+
 // HandleAuth updates or clears client authorisation based on auths received and then forwards the auth onwards.
 func (cm *ClientManager) HandleAuth(ca ClientAuth) {
 	cm.clientsLocker.Lock()
@@ -433,10 +471,16 @@ func (cm *ClientManager) HandleAuth(ca ClientAuth) {
 		return
 	}
 
-	// If the auth is nil, we should clear the token.
+	// If the auth is nil, we should clear the token. Client of the user with
+	// automatic re-authentication is kept to authenticate again and the nil
+	// auth is forwarded to do so; other clients are logged out.
 	if ca.Auth == nil {
 		cm.clearToken(ca.UserID)
-		go cm.LogoutClient(ca.UserID)
+		if cm.hasAutoReauth(ca.UserID) {
+			go func() { cm.authUpdates <- ca }()
+		} else {
+			go cm.LogoutClient(ca.UserID)
+		}
 		return
 	}
 
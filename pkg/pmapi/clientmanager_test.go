@@ -17,6 +17,13 @@
 
 package pmapi
 
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
 func newTestClientManager(cfg *ClientConfig) *ClientManager {
 	cm := NewClientManager(cfg)
 
@@ -27,3 +34,45 @@ func newTestClientManager(cfg *ClientConfig) *ClientManager {
 
 	return cm
 }
+
+func TestClientManager_HandleNilAuthLogsOutClient(t *testing.T) {
+	cm := NewClientManager(testClientConfig)
+	cm.GetClient("user")
+
+	cm.HandleAuth(ClientAuth{UserID: "user"})
+
+	assert.Eventually(t, func() bool {
+		cm.clientsLocker.Lock()
+		defer cm.clientsLocker.Unlock()
+
+		_, ok := cm.clients["user"]
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+
+	select {
+	case <-cm.GetAuthUpdateChannel():
+		t.Fatal("nil auth must not be forwarded without automatic re-authentication")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestClientManager_HandleNilAuthWithAutoReauth(t *testing.T) {
+	cm := NewClientManager(testClientConfig)
+	client := cm.GetClient("user")
+	cm.SetAutoReauth("user", true)
+
+	cm.HandleAuth(ClientAuth{UserID: "user"})
+
+	select {
+	case auth := <-cm.GetAuthUpdateChannel():
+		assert.Equal(t, ClientAuth{UserID: "user"}, auth)
+	case <-time.After(time.Second):
+		t.Fatal("nil auth was not forwarded")
+	}
+
+	// Client is kept to authenticate again.
+	assert.Equal(t, client, cm.GetClient("user"))
+
+	cm.SetAutoReauth("user", false)
+	assert.False(t, cm.hasAutoReauth("user"))
+}
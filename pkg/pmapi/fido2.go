@@ -0,0 +1,146 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package pmapi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+const fido2ClientDataTypeGet = "webauthn.get"
+
+var ErrNoFIDO2Key = errors.New("account has no security key registered")
+
+// FIDO2Info holds the WebAuthn options of the assertion requested by the server.
+type FIDO2Info struct {
+	AuthenticationOptions FIDO2AuthenticationOptions
+}
+
+type FIDO2AuthenticationOptions struct {
+	PublicKey FIDO2PublicKeyOptions `json:"publicKey"`
+}
+
+type FIDO2PublicKeyOptions struct {
+	Challenge        []byte            `json:"challenge"`
+	RpID             string            `json:"rpId"`
+	AllowCredentials []FIDO2Credential `json:"allowCredentials"`
+	UserVerification string            `json:"userVerification,omitempty"`
+	Timeout          int               `json:"timeout,omitempty"`
+}
+
+type FIDO2Credential struct {
+	Type string `json:"type"`
+	ID   []byte `json:"id"`
+}
+
+// FIDO2Assertion is the response of a security key to authenticatorGetAssertion.
+type FIDO2Assertion struct {
+	CredentialID      []byte
+	AuthenticatorData []byte
+	Signature         []byte
+}
+
+// FIDO2Authenticator gets assertions from a security key. Implementations
+// talk to a hardware key over CTAP2 or, in tests, keep the key in memory.
+type FIDO2Authenticator interface {
+	// GetAssertion signs the client data hash for the relying party with
+	// one of the allowed credentials.
+	GetAssertion(rpID string, clientDataHash []byte, allowCredentials [][]byte) (*FIDO2Assertion, error)
+}
+
+// FIDO2Req is the second factor made of the assertion and the client data
+// it was made for. ClientData, AuthenticatorData and Signature are base64.
+type FIDO2Req struct {
+	AuthenticationOptions FIDO2AuthenticationOptions
+	ClientData            string
+	AuthenticatorData     string
+	Signature             string
+	CredentialID          []byte
+}
+
+// fido2ClientData is the collected client data (clientDataJSON) of WebAuthn.
+type fido2ClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// NewFIDO2Req gets an assertion for the challenge of `auth` from the authenticator.
+func NewFIDO2Req(authenticator FIDO2Authenticator, auth *Auth) (*FIDO2Req, error) {
+	if !auth.HasFIDO2() {
+		return nil, ErrNoFIDO2Key
+	}
+
+	options := auth.TwoFA.FIDO2.AuthenticationOptions
+
+	clientData, err := json.Marshal(fido2ClientData{
+		Type:      fido2ClientDataTypeGet,
+		Challenge: base64.RawURLEncoding.EncodeToString(options.PublicKey.Challenge),
+		Origin:    "https://" + options.PublicKey.RpID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	allowCredentials := [][]byte{}
+	for _, credential := range options.PublicKey.AllowCredentials {
+		allowCredentials = append(allowCredentials, credential.ID)
+	}
+
+	clientDataHash := sha256.Sum256(clientData)
+
+	assertion, err := authenticator.GetAssertion(options.PublicKey.RpID, clientDataHash[:], allowCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isAllowedCredential(allowCredentials, assertion.CredentialID) {
+		return nil, errors.New("security key used credential which was not allowed")
+	}
+
+	return &FIDO2Req{
+		AuthenticationOptions: options,
+		ClientData:            base64.StdEncoding.EncodeToString(clientData),
+		AuthenticatorData:     base64.StdEncoding.EncodeToString(assertion.AuthenticatorData),
+		Signature:             base64.StdEncoding.EncodeToString(assertion.Signature),
+		CredentialID:          assertion.CredentialID,
+	}, nil
+}
+
+func isAllowedCredential(allowCredentials [][]byte, credentialID []byte) bool {
+	for _, allowed := range allowCredentials {
+		if bytes.Equal(allowed, credentialID) {
+			return true
+		}
+	}
+	return false
+}
+
+// Auth2FAFIDO2 authenticates a user into full scope with a security key.
+// Use it instead of Auth2FA when `Auth` has `HasFIDO2`.
+func (c *client) Auth2FAFIDO2(authenticator FIDO2Authenticator, auth *Auth) (*Auth2FA, error) {
+	fido2, err := NewFIDO2Req(authenticator, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.auth2FA(&Auth2FAReq{FIDO2: fido2})
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package pmapi
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// HostSettings selects the API host and the dialer used by clients of one
+// account. The zero value uses the default API host and round tripper.
+type HostSettings struct {
+	// URL is the API root URL including scheme, e.g. "https://api.example.com/api".
+	// Plain http is allowed only for loopback hosts, e.g. local test servers.
+	// Empty URL means the default API host which can be switched to a proxy.
+	URL string
+
+	// NoPinning disables checking TLS certificates against known fingerprints.
+	NoPinning bool
+
+	// NoProxy disables switching to alternative routing when the API is blocked.
+	NoProxy bool
+}
+
+// IsDefault returns whether the settings are the default ones.
+func (s HostSettings) IsDefault() bool {
+	return s == HostSettings{}
+}
+
+// Validate returns error when the URL is not usable API root URL.
+func (s HostSettings) Validate() error {
+	if s.URL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return errors.Wrap(err, "invalid API URL")
+	}
+
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.New("API URL must be in the form https://host[/path]")
+	}
+
+	// Tokens and mail must not go over the network in cleartext.
+	if u.Scheme == "http" && !isLoopbackHost(u.Hostname()) {
+		return errors.New("API URL must use https unless the host is loopback")
+	}
+
+	// Known certificate fingerprints belong to the default API host only.
+	if !s.NoPinning {
+		return errors.New("certificate pinning works only with the default API host, disable it to use a custom API URL")
+	}
+
+	return nil
+}
+
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// SetRoundTripperFactory sets the function creating round trippers for
+// accounts with non-default host settings. Without it, all clients use
+// the round tripper set by SetRoundTripper.
+func (cm *ClientManager) SetRoundTripperFactory(factory func(HostSettings) http.RoundTripper) {
+	cm.hostSettingsLocker.Lock()
+	defer cm.hostSettingsLocker.Unlock()
+
+	cm.roundTripperFactory = factory
+	cm.roundTrippers = make(map[HostSettings]http.RoundTripper)
+}
+
+// SetHostSettings sets the host settings used by the client of `userID`.
+// It can be changed any time; it is used from the next request.
+func (cm *ClientManager) SetHostSettings(userID string, settings HostSettings) {
+	cm.hostSettingsLocker.Lock()
+	defer cm.hostSettingsLocker.Unlock()
+
+	if settings.IsDefault() {
+		delete(cm.hostSettings, userID)
+		return
+	}
+
+	cm.hostSettings[userID] = settings
+}
+
+// GetHostSettings returns the host settings used by the client of `userID`.
+func (cm *ClientManager) GetHostSettings(userID string) HostSettings {
+	cm.hostSettingsLocker.RLock()
+	defer cm.hostSettingsLocker.RUnlock()
+
+	return cm.hostSettings[userID]
+}
+
+// GetAnonymousClientWithHost returns an anonymous client using `settings`.
+// It is used to log in to an account on a custom host.
+func (cm *ClientManager) GetAnonymousClientWithHost(settings HostSettings) Client {
+	userID := cm.newAnonymousID()
+	cm.SetHostSettings(userID, settings)
+	return cm.GetClient(userID)
+}
+
+// getRootURLFor returns the root URL (scheme+host) for the client of `userID`.
+func (cm *ClientManager) getRootURLFor(userID string) string {
+	settings := cm.GetHostSettings(userID)
+
+	switch {
+	case settings.URL != "":
+		return strings.TrimSuffix(settings.URL, "/")
+	case settings.NoProxy:
+		return rootScheme + "://" + rootURL
+	default:
+		return cm.GetRootURL()
+	}
+}
+
+// getRoundTripperFor returns the round tripper for the client of `userID`.
+func (cm *ClientManager) getRoundTripperFor(userID string) http.RoundTripper {
+	cm.hostSettingsLocker.Lock()
+	defer cm.hostSettingsLocker.Unlock()
+
+	settings, ok := cm.hostSettings[userID]
+	if !ok || cm.roundTripperFactory == nil {
+		return cm.roundTripper
+	}
+
+	if _, ok := cm.roundTrippers[settings]; !ok {
+		cm.roundTrippers[settings] = cm.roundTripperFactory(settings)
+	}
+
+	return cm.roundTrippers[settings]
+}
+
+// clientRoundTripper passes requests of a client to the round tripper
+// chosen by current host settings of the client's user.
+type clientRoundTripper struct {
+	cm     *ClientManager
+	userID string
+}
+
+func (rt *clientRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return rt.cm.getRoundTripperFor(rt.userID).RoundTrip(req)
+}
+
+// CloseIdleConnections is called by http.Client.CloseIdleConnections.
+func (rt *clientRoundTripper) CloseIdleConnections() {
+	type closeIdler interface {
+		CloseIdleConnections()
+	}
+
+	if tr, ok := rt.cm.getRoundTripperFor(rt.userID).(closeIdler); ok {
+		tr.CloseIdleConnections()
+	}
+}
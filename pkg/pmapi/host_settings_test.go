@@ -0,0 +1,149 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package pmapi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRoundTripper counts requests passed to the default transport.
+type countingRoundTripper struct {
+	requests int
+}
+
+func (rt *countingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestHostSettingsValidate(t *testing.T) {
+	testCases := []struct {
+		settings HostSettings
+		wantErr  bool
+	}{
+		{settings: HostSettings{}},
+		{settings: HostSettings{NoProxy: true}},
+		{settings: HostSettings{URL: "https://api.example.com/api", NoPinning: true}},
+		{settings: HostSettings{URL: "http://127.0.0.1:8080", NoPinning: true}},
+		{settings: HostSettings{URL: "http://localhost:8080/api", NoPinning: true}},
+		{settings: HostSettings{URL: "http://[::1]:8080", NoPinning: true}},
+		{settings: HostSettings{URL: "http://api.example.com", NoPinning: true}, wantErr: true},
+		{settings: HostSettings{URL: "http://192.168.1.10", NoPinning: true}, wantErr: true},
+		{settings: HostSettings{URL: "https://api.example.com"}, wantErr: true},
+		{settings: HostSettings{URL: "http://api.example.com"}, wantErr: true},
+		{settings: HostSettings{URL: "ftp://api.example.com", NoPinning: true}, wantErr: true},
+		{settings: HostSettings{URL: "api.example.com", NoPinning: true}, wantErr: true},
+		{settings: HostSettings{URL: "://", NoPinning: true}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		err := tc.settings.Validate()
+		if tc.wantErr {
+			assert.Error(t, err, "%+v", tc.settings)
+		} else {
+			assert.NoError(t, err, "%+v", tc.settings)
+		}
+	}
+}
+
+func TestClientManager_HostSettingsRootURL(t *testing.T) {
+	cm := newTestClientManager(testClientConfig)
+
+	assert.Equal(t, cm.GetRootURL(), cm.getRootURLFor("user"))
+
+	cm.SetHostSettings("user", HostSettings{NoProxy: true})
+	assert.Equal(t, rootScheme+"://"+rootURL, cm.getRootURLFor("user"))
+
+	cm.SetHostSettings("user", HostSettings{URL: "https://api.example.com/api/", NoPinning: true})
+	assert.Equal(t, "https://api.example.com/api", cm.getRootURLFor("user"))
+	assert.Equal(t, cm.GetRootURL(), cm.getRootURLFor("other"))
+
+	cm.SetHostSettings("user", HostSettings{})
+	assert.Equal(t, HostSettings{}, cm.GetHostSettings("user"))
+	assert.Equal(t, cm.GetRootURL(), cm.getRootURLFor("user"))
+}
+
+func TestClientManager_HostSettingsRoundTripper(t *testing.T) {
+	cm := newTestClientManager(testClientConfig)
+	defaultRoundTripper := &countingRoundTripper{}
+	cm.SetRoundTripper(defaultRoundTripper)
+
+	// Without factory, all clients use the default round tripper.
+	cm.SetHostSettings("user", HostSettings{NoProxy: true})
+	assert.Same(t, defaultRoundTripper, cm.getRoundTripperFor("user"))
+
+	created := map[HostSettings]int{}
+	cm.SetRoundTripperFactory(func(settings HostSettings) http.RoundTripper {
+		created[settings]++
+		return &countingRoundTripper{}
+	})
+
+	settings := HostSettings{URL: "https://api.example.com", NoPinning: true}
+	cm.SetHostSettings("user", settings)
+	cm.SetHostSettings("other", settings)
+
+	// Round tripper is created once for the same settings.
+	roundTripper := cm.getRoundTripperFor("user")
+	assert.NotSame(t, defaultRoundTripper, roundTripper)
+	assert.Same(t, roundTripper, cm.getRoundTripperFor("other"))
+	assert.Equal(t, map[HostSettings]int{settings: 1}, created)
+
+	assert.Same(t, defaultRoundTripper, cm.getRoundTripperFor("default"))
+}
+
+func TestClient_CustomHost(t *testing.T) {
+	var path string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		fmt.Fprint(w, "Hello World!")
+	}))
+	defer s.Close()
+
+	cm := newTestClientManager(testClientConfig)
+	defaultRoundTripper := &countingRoundTripper{}
+	cm.SetRoundTripper(defaultRoundTripper)
+
+	hostRoundTripper := &countingRoundTripper{}
+	cm.SetRoundTripperFactory(func(HostSettings) http.RoundTripper {
+		return hostRoundTripper
+	})
+
+	c := cm.GetAnonymousClientWithHost(HostSettings{URL: s.URL + "/api", NoPinning: true}).(*client)
+
+	req, err := c.NewRequest("GET", "/tests/ping", nil)
+	require.NoError(t, err)
+
+	res, err := c.Do(req, true)
+	require.NoError(t, err)
+
+	b, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	assert.Equal(t, "Hello World!", string(b))
+	assert.Equal(t, "/api/tests/ping", path)
+	assert.Equal(t, 1, hostRoundTripper.requests)
+	assert.Equal(t, 0, defaultRoundTripper.requests)
+}
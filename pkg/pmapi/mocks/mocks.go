@@ -80,6 +80,21 @@ func (mr *MockClientMockRecorder) Auth2FA(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Auth2FA", reflect.TypeOf((*MockClient)(nil).Auth2FA), arg0, arg1)
 }
 
+// Auth2FAFIDO2 mocks base method
+func (m *MockClient) Auth2FAFIDO2(arg0 pmapi.FIDO2Authenticator, arg1 *pmapi.Auth) (*pmapi.Auth2FA, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Auth2FAFIDO2", arg0, arg1)
+	ret0, _ := ret[0].(*pmapi.Auth2FA)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Auth2FAFIDO2 indicates an expected call of Auth2FAFIDO2
+func (mr *MockClientMockRecorder) Auth2FAFIDO2(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Auth2FAFIDO2", reflect.TypeOf((*MockClient)(nil).Auth2FAFIDO2), arg0, arg1)
+}
+
 // AuthInfo mocks base method
 func (m *MockClient) AuthInfo(arg0 string) (*pmapi.AuthInfo, error) {
 	m.ctrl.T.Helper()
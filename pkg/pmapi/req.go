@@ -27,7 +27,7 @@ import (
 
 // NewRequest creates a new request.
 func (c *client) NewRequest(method, path string, body io.Reader) (*http.Request, error) {
-	return http.NewRequest(method, c.cm.GetRootURL()+path, body)
+	return http.NewRequest(method, c.cm.getRootURLFor(c.userID)+path, body)
 }
 
 // NewJSONRequest create a new JSON request.
@@ -42,7 +42,7 @@ type Res struct {
 // Err returns error if the response is an error. Otherwise, returns nil.
 func (res Res) Err() error {
 	if res.StatusCode == http.StatusUnprocessableEntity {
-		return &ErrUnprocessableEntity{errors.New(res.Error)}
+		return &ErrUnprocessableEntity{error: errors.New(res.Error), Code: res.Code}
 	}
 
 	if res.ResError == nil {
@@ -0,0 +1,90 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package totp generates time-based one-time passwords (RFC 6238) as used
+// by authenticator apps for two factor authentication.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" //nolint[gosec]
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	period = 30 * time.Second
+	digits = 6
+)
+
+var ErrInvalidSecret = errors.New("invalid TOTP secret")
+
+// ParseSecret returns the key from a base32 secret or from an otpauth URI
+// shown as QR code when two factor authentication is enabled.
+func ParseSecret(secret string) ([]byte, error) {
+	secret = strings.TrimSpace(secret)
+
+	if strings.HasPrefix(secret, "otpauth://") {
+		uri, err := url.Parse(secret)
+		if err != nil {
+			return nil, ErrInvalidSecret
+		}
+		secret = uri.Query().Get("secret")
+	}
+
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	secret = strings.TrimRight(secret, "=")
+	if secret == "" {
+		return nil, ErrInvalidSecret
+	}
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return nil, ErrInvalidSecret
+	}
+
+	return key, nil
+}
+
+// Generate returns the code valid at time t.
+func Generate(secret string, t time.Time) (string, error) {
+	key, err := ParseSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return generate(key, uint64(t.Unix())/uint64(period/time.Second)), nil
+}
+
+func generate(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+
+	mac := hmac.New(sha1.New, key)
+	_, _ = mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", digits, code%1000000)
+}
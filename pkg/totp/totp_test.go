@@ -0,0 +1,63 @@
+// Copyright (c) 2020 Proton Technologies AG
+//
+// This file is part of ProtonMail Bridge.
+//
+// ProtonMail Bridge is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ProtonMail Bridge is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ProtonMail Bridge.  If not, see <https://www.gnu.org/licenses/>.
+
+package totp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Base32 of "12345678901234567890" from RFC 6238 test vectors.
+const testSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerate(t *testing.T) {
+	for unix, want := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		code, err := Generate(testSecret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		require.Equal(t, want, code, unix)
+	}
+}
+
+func TestParseSecret(t *testing.T) {
+	want, err := ParseSecret(testSecret)
+	require.NoError(t, err)
+	require.Equal(t, []byte("12345678901234567890"), want)
+
+	for _, secret := range []string{
+		"gezd gnbv gy3t qojq gezd gnbv gy3t qojq",
+		"otpauth://totp/ProtonMail:user?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=ProtonMail",
+	} {
+		key, err := ParseSecret(secret)
+		require.NoError(t, err)
+		require.Equal(t, want, key)
+	}
+
+	for _, secret := range []string{"", "not base32!", "otpauth://totp/x?issuer=y"} {
+		_, err := ParseSecret(secret)
+		require.Equal(t, ErrInvalidSecret, err)
+	}
+}
@@ -74,6 +74,9 @@ func (c *fakeConfig) GetTLSCertPath() string {
 func (c *fakeConfig) GetTLSKeyPath() string {
 	return filepath.Join(c.dir, "key.pem")
 }
+func (c *fakeConfig) GetOutgoingPolicyPath() string {
+	return filepath.Join(c.dir, "outgoing_policy.json")
+}
 func (c *fakeConfig) GetEventsPath() string {
 	return filepath.Join(c.dir, "events.json")
 }
@@ -89,3 +92,6 @@ func (c *fakeConfig) GetDefaultIMAPPort() int {
 func (c *fakeConfig) GetDefaultSMTPPort() int {
 	return 21200 + rand.Intn(100)
 }
+func (c *fakeConfig) GetDefaultSMTPSSLPort() int {
+	return 21300 + rand.Intn(100)
+}
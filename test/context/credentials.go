@@ -99,9 +99,29 @@ func (c *fakeCredStore) UpdateToken(userID, apiToken string) error {
 	return nil
 }
 
+func (c *fakeCredStore) SetAutoReauth(userID, loginPassword, totpSecret string) error {
+	creds, err := c.Get(userID)
+	if err != nil {
+		return err
+	}
+	creds.LoginPassword = loginPassword
+	creds.TOTPSecret = totpSecret
+	return nil
+}
+
+func (c *fakeCredStore) SetAPIHost(userID, apiURL string, noPinning, noProxy bool) error {
+	creds, err := c.Get(userID)
+	if err != nil {
+		return err
+	}
+	creds.APIURL = apiURL
+	creds.APINoPinning = noPinning
+	creds.APINoProxy = noProxy
+	return nil
+}
+
 func (c *fakeCredStore) Logout(userID string) error {
-	c.credentials[userID].APIToken = ""
-	c.credentials[userID].MailboxPassword = ""
+	c.credentials[userID].Logout()
 	return nil
 }
 
@@ -109,3 +129,34 @@ func (c *fakeCredStore) Delete(userID string) error {
 	delete(c.credentials, userID)
 	return nil
 }
+
+func (c *fakeCredStore) AddAppPassword(userID, name string) (string, error) {
+	password := "app-" + name
+	c.credentials[userID].AppPasswords = append(c.credentials[userID].AppPasswords, credentials.AppPassword{
+		Name:     name,
+		Password: password,
+	})
+	return password, nil
+}
+
+func (c *fakeCredStore) RevokeAppPassword(userID, name string) error {
+	for i := range c.credentials[userID].AppPasswords {
+		if c.credentials[userID].AppPasswords[i].Name == name {
+			c.credentials[userID].AppPasswords[i].Password = ""
+			c.credentials[userID].AppPasswords[i].Revoked = 1
+		}
+	}
+	return nil
+}
+
+func (c *fakeCredStore) UpdateAppPasswordLastUsed(userID, name string, lastUsed int64) error {
+	return nil
+}
+
+func (c *fakeCredStore) RotateBridgePassword(userID, address string) error {
+	return nil
+}
+
+func (c *fakeCredStore) Reconnect() error {
+	return nil
+}
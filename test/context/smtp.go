@@ -57,10 +57,9 @@ func (ctx *TestContext) withSMTPServer() {
 	pref := preferences.New(ctx.cfg)
 	tls, _ := config.GetTLSConfig(ctx.cfg)
 	port := pref.GetInt(preferences.SMTPPortKey)
-	useSSL := pref.GetBool(preferences.SMTPSSLKey)
 
-	backend := smtp.NewSMTPBackend(ph, ctx.listener, pref, ctx.bridge)
-	server := smtp.NewSMTPServer(true, port, useSSL, tls, backend, ctx.listener)
+	backend := smtp.NewSMTPBackend(ph, ctx.listener, ctx.cfg, pref, ctx.bridge)
+	server := smtp.NewSMTPServer(true, port, false, tls, backend, ctx.listener)
 
 	go server.ListenAndServe()
 	require.NoError(ctx.t, waitForPort(port, 5*time.Second))
@@ -81,6 +81,10 @@ func (api *FakePMAPI) Auth2FA(twoFactorCode string, auth *pmapi.Auth) (*pmapi.Au
 		return nil, err
 	}
 
+	return api.setFullScope()
+}
+
+func (api *FakePMAPI) setFullScope() (*pmapi.Auth2FA, error) {
 	if api.uid == "" {
 		return nil, pmapi.ErrInvalidToken
 	}
@@ -97,6 +101,21 @@ func (api *FakePMAPI) Auth2FA(twoFactorCode string, auth *pmapi.Auth) (*pmapi.Au
 	}, nil
 }
 
+func (api *FakePMAPI) Auth2FAFIDO2(authenticator pmapi.FIDO2Authenticator, auth *pmapi.Auth) (*pmapi.Auth2FA, error) {
+	fido2, err := pmapi.NewFIDO2Req(authenticator, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := api.checkInternetAndRecordCall(POST, "/auth/2fa", &pmapi.Auth2FAReq{
+		FIDO2: fido2,
+	}); err != nil {
+		return nil, err
+	}
+
+	return api.setFullScope()
+}
+
 func (api *FakePMAPI) AuthRefresh(token string) (*pmapi.Auth, error) {
 	if api.lastToken == "" {
 		api.lastToken = token
@@ -0,0 +1,50 @@
+package smtp
+
+import (
+	"io"
+)
+
+// A SMTP server backend.
+type Backend interface {
+	// Authenticate a user.
+	Login(username, password string) (User, error)
+}
+
+// An authenticated user.
+type User interface {
+	// Send an e-mail.
+	Send(from string, to []string, r io.Reader) error
+	// Logout is called when this User will no longer be used.
+	Logout() error
+}
+
+// The sender, recipients and ESMTP parameters of a message.
+type Envelope struct {
+	// The sender e-mail address.
+	From string
+	// The ESMTP parameters of the MAIL command, keys are uppercased.
+	// Parameters without a value have an empty value.
+	MailParams map[string]string
+	// The recipients e-mail addresses.
+	To []string
+	// The ESMTP parameters of the RCPT commands, one for each recipient.
+	RcptParams []map[string]string
+}
+
+// An authenticated user which needs the ESMTP parameters of a message.
+// The server calls SendEnvelope instead of Send for such users.
+type EnvelopeUser interface {
+	User
+	// Send an e-mail with its envelope.
+	SendEnvelope(envelope *Envelope, r io.Reader) error
+}
+
+// An authenticated user with its own message size limit. The server rejects
+// MAIL commands declaring larger SIZE than the smaller of this limit and
+// Server.MaxMessageBytes.
+type SizeLimitUser interface {
+	User
+	// MaxMessageBytes returns the largest message the user can send.
+	// Zero means there is no limit.
+	MaxMessageBytes() int
+}
@@ -0,0 +1,5 @@
+module github.com/emersion/go-smtp
+
+go 1.13
+
+require github.com/emersion/go-sasl v0.0.0-20191210011802-430746ea8b9b